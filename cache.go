@@ -0,0 +1,200 @@
+package redisdb
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CacheStats is returned by CacheStats() of a key created with WithLocalCache
+type CacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	Invalidations uint64
+	Size          int
+	// Tracking is false if entries only expire by ttl, because the data source doesn't support CLIENT TRACKING
+	Tracking bool
+}
+
+type cacheEntry struct {
+	rdsKey  string
+	field   string
+	raw     []byte
+	expires time.Time
+}
+
+// localCache is a size and ttl bounded LRU of values as stored in redis; they are decoded on every read,
+// so callers never share a value. entries are grouped by data source + redis key,
+// so a hash is invalidated as a whole, the way CLIENT TRACKING reports it
+type localCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]map[string]*list.Element
+	// seq is bumped by every invalidation. a value read from redis is only stored if seq didn't change meanwhile,
+	// so an invalidation that arrives during the read is never lost
+	seq      uint64
+	trackers map[string]*tracker
+
+	hits, misses, evictions, invalidations atomic.Uint64
+}
+
+func newLocalCache(size int, ttl time.Duration) *localCache {
+	return &localCache{size: size, ttl: ttl, lru: list.New(), entries: map[string]map[string]*list.Element{}, trackers: map[string]*tracker{}}
+}
+
+func cacheRdsKey(rdsName, key string) string {
+	return rdsName + "\x00" + key
+}
+
+func (c *localCache) get(rdsName, key, field string) (raw []byte, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[cacheRdsKey(rdsName, key)][field]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if c.ttl > 0 && time.Now().After(entry.expires) {
+		c.remove(elem)
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	c.hits.Add(1)
+	return entry.raw, true
+}
+
+// begin returns the seq to pass to put, and makes sure the key's prefix is tracked on rdsName
+func (c *localCache) begin(rdsName, prefix string) (seq uint64) {
+	c.mu.Lock()
+	t := c.trackers[rdsName]
+	c.mu.Unlock()
+	if t == nil || !t.tracks(prefix) {
+		t = trackPrefix(rdsName, prefix, c)
+		c.mu.Lock()
+		c.trackers[rdsName] = t
+		c.mu.Unlock()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.seq
+}
+
+// put stores raw unless an invalidation happened since begin returned seq. without a ttl, entries are only dropped
+// by CLIENT TRACKING, so nothing is stored while it isn't active on rdsName
+func (c *localCache) put(rdsName, key, field string, raw []byte, seq uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if seq != c.seq {
+		return
+	}
+	if t := c.trackers[rdsName]; c.ttl <= 0 && (t == nil || !t.active.Load()) {
+		return
+	}
+	rdsKey := cacheRdsKey(rdsName, key)
+	if elem, ok := c.entries[rdsKey][field]; ok {
+		c.remove(elem)
+	}
+	fields := c.entries[rdsKey]
+	if fields == nil {
+		fields = map[string]*list.Element{}
+		c.entries[rdsKey] = fields
+	}
+	fields[field] = c.lru.PushFront(&cacheEntry{rdsKey: rdsKey, field: field, raw: raw, expires: time.Now().Add(c.ttl)})
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// remove drops elem, c.mu must be held
+func (c *localCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	if fields := c.entries[entry.rdsKey]; fields != nil {
+		if delete(fields, entry.field); len(fields) == 0 {
+			delete(c.entries, entry.rdsKey)
+		}
+	}
+}
+
+// invalidate drops every field cached for the redis key
+func (c *localCache) invalidate(rdsName, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	for _, elem := range c.entries[cacheRdsKey(rdsName, key)] {
+		c.remove(elem)
+		c.invalidations.Add(1)
+	}
+}
+
+// flush drops everything cached from rdsName
+func (c *localCache) flush(rdsName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	prefix := cacheRdsKey(rdsName, "")
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if entry := elem.Value.(*cacheEntry); len(entry.rdsKey) >= len(prefix) && entry.rdsKey[:len(prefix)] == prefix {
+			c.remove(elem)
+			c.invalidations.Add(1)
+		}
+		elem = next
+	}
+}
+
+func (c *localCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := CacheStats{
+		Hits: c.hits.Load(), Misses: c.misses.Load(), Evictions: c.evictions.Load(), Invalidations: c.invalidations.Load(),
+		Size: c.lru.Len(), Tracking: len(c.trackers) > 0,
+	}
+	for _, t := range c.trackers {
+		stats.Tracking = stats.Tracking && t.active.Load()
+	}
+	return stats
+}
+
+// cached reads the raw value of key / field through the local cache of WithLocalCache, load reads it from rds on a miss.
+// with a cache, rds is the primary: invalidations come from the primary, a lagging replica could cache a value already overwritten
+func (ctx *RedisKey[k, v]) cached(key, field string, load func(rds redis.UniversalClient) ([]byte, error)) (raw []byte, err error) {
+	if ctx.cache == nil {
+		return load(ctx.reader())
+	}
+	if raw, ok := ctx.cache.get(ctx.RdsName, key, field); ok {
+		return raw, nil
+	}
+	seq := ctx.cache.begin(ctx.RdsName, ctx.Key)
+	if raw, err = load(ctx.rds()); err == nil {
+		ctx.cache.put(ctx.RdsName, key, field, raw, seq)
+	}
+	return raw, err
+}
+
+// uncache drops the locally cached values of the redis keys after a write through this process
+func (ctx *RedisKey[k, v]) uncache(keys ...string) {
+	if ctx.cache == nil {
+		return
+	}
+	for _, key := range keys {
+		ctx.cache.invalidate(ctx.RdsName, key)
+	}
+}
+
+// CacheStats reports the local cache of WithLocalCache; zero if the key has none
+func (ctx *RedisKey[k, v]) CacheStats() CacheStats {
+	if ctx.cache == nil {
+		return CacheStats{}
+	}
+	return ctx.cache.stats()
+}
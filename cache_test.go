@@ -0,0 +1,122 @@
+package redisdb_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/doptime/redisdb"
+)
+
+func TestLocalCacheHashKey(t *testing.T) {
+	srv, rds := newServer(t)
+	profiles := redisdb.NewHashKey[string, string](rds.Key("profile").LocalCache(2, time.Minute))
+	profiles.HSet("a", "1", "b", "2", "c", "3")
+
+	if v, err := profiles.HGet("a"); err != nil || v != "1" {
+		t.Fatalf("HGet = %q, %v", v, err)
+	}
+	// served from the local cache: the server has no CLIENT TRACKING, so a change made behind the key's back isn't seen
+	srv.HSet("profile", "a", "changed")
+	if v, _ := profiles.HGet("a"); v != "1" {
+		t.Fatalf("HGet = %q, want the cached value", v)
+	}
+	stats := profiles.CacheStats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Size != 1 || stats.Tracking {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	// a write through the key drops the cached hash
+	profiles.HSet("b", "20")
+	if v, _ := profiles.HGet("a"); v != "changed" {
+		t.Fatalf("HGet after write = %q, want %q", v, "changed")
+	}
+
+	// size bound
+	profiles.HGet("b")
+	profiles.HGet("c")
+	if stats = profiles.CacheStats(); stats.Size != 2 || stats.Evictions != 1 {
+		t.Fatalf("stats = %+v, want size 2 and 1 eviction", stats)
+	}
+
+	// a missing field is not cached
+	if _, err := profiles.HGet("none"); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("HGet missing = %v", err)
+	}
+	srv.HSet("profile", "none", "now")
+	if v, _ := profiles.HGet("none"); v != "now" {
+		t.Fatalf("HGet = %q, want %q", v, "now")
+	}
+}
+
+func TestLocalCacheTTL(t *testing.T) {
+	srv, rds := newServer(t)
+	config := redisdb.NewStringKey[string, string](rds.Key("cfg").LocalCache(10, 20*time.Millisecond))
+	config.Set("mode", "a", 0)
+	config.Get("mode")
+	srv.Set("cfg:mode", "b")
+	if v, _ := config.Get("mode"); v != "a" {
+		t.Fatalf("Get = %q, want the cached value", v)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if v, _ := config.Get("mode"); v != "b" {
+		t.Fatalf("Get after ttl = %q, want %q", v, "b")
+	}
+}
+
+func TestLocalCacheInvalidatedByWrites(t *testing.T) {
+	srv, rds := newServer(t)
+	config := redisdb.NewStringKey[string, string](rds.Key("cfg").LocalCache(10, time.Minute))
+	config.Set("mode", "a", 0)
+	config.Get("mode")
+	if err := config.Del("mode"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.Get("mode"); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("Get after Del = %v, want ErrNotFound", err)
+	}
+
+	config.Set("mode", "a", 0)
+	config.Get("mode")
+	err := redisdb.Tx(srv.Name, func(tx *redisdb.TxCtx) error {
+		config.In(tx).Set("mode", "tx", 0)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := config.Get("mode"); v != "tx" {
+		t.Fatalf("Get after Tx = %q, want %q", v, "tx")
+	}
+}
+
+func TestLocalCacheReturnsCopies(t *testing.T) {
+	_, rds := newServer(t)
+	users := redisdb.NewHashKey[string, *Address](rds.Key("addr").LocalCache(10, time.Minute))
+	users.HSet("u1", &Address{City: "Paris"})
+	got, err := users.HGet("u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.City = "Rome"
+	if again, _ := users.HGet("u1"); again.City != "Paris" {
+		t.Fatalf("HGet = %+v, a caller's change leaked into the cache", again)
+	}
+	if stats := users.CacheStats(); stats.Hits != 1 {
+		t.Fatalf("stats = %+v, want 1 hit", stats)
+	}
+}
+
+func TestLocalCacheWithoutTTLOrTracking(t *testing.T) {
+	srv, rds := newServer(t)
+	config := redisdb.NewStringKey[string, string](rds.Key("cfg").LocalCache(10, 0))
+	config.Set("mode", "a", 0)
+	config.Get("mode")
+	srv.Set("cfg:mode", "b")
+	if v, _ := config.Get("mode"); v != "b" {
+		t.Fatalf("Get = %q, want %q: nothing may be cached without a ttl or CLIENT TRACKING", v, "b")
+	}
+	if stats := config.CacheStats(); stats.Size != 0 {
+		t.Fatalf("stats = %+v, want an empty cache", stats)
+	}
+}
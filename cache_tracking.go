@@ -0,0 +1,278 @@
+package redisdb
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/doptime/logger"
+	cmap "github.com/orcaman/concurrent-map/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+const invalidateChannel = "__redis__:invalidate"
+
+var (
+	// TrackingTimeout bounds the setup of CLIENT TRACKING for a data source
+	TrackingTimeout = 2 * time.Second
+	// TrackingKeepAlive is how often the tracking connection is checked; if it was lost, it is re-enabled and the caches are flushed
+	TrackingKeepAlive = 5 * time.Second
+)
+
+type cacheInvalidator interface {
+	invalidate(rdsName, key string)
+	flush(rdsName string)
+}
+
+// trackers holds one tracker per data source, shared by all the keys with a local cache on it
+var (
+	trackers   = cmap.New[*tracker]()
+	trackersMu sync.Mutex
+)
+
+// tracker subscribes to __redis__:invalidate and keeps a connection with CLIENT TRACKING BCAST redirected to that subscription,
+// for the key prefixes of the local caches on a data source. if the data source doesn't support it (cluster, ring, no CLIENT TRACKING)
+// the tracker stays inactive and cached values only expire by ttl
+type tracker struct {
+	rdsName string
+	source  redis.UniversalClient
+	active  atomic.Bool
+
+	mu       sync.Mutex
+	prefixes map[string]bool
+	caches   map[cacheInvalidator]bool
+	redirect int64
+
+	sub    *redis.Client
+	pubsub *redis.PubSub
+	conn   *redis.Client
+	done   chan struct{}
+}
+
+// trackPrefix makes sure prefix is tracked on rdsName and c is told about invalidations. a tracker built for a client
+// that has since been replaced by RegisterDataSource is rebuilt
+func trackPrefix(rdsName, prefix string, c cacheInvalidator) *tracker {
+	trackersMu.Lock()
+	defer trackersMu.Unlock()
+	source, _ := getDataSource(rdsName)
+	t, ok := trackers.Get(rdsName)
+	if !ok || t.source != source {
+		old := t
+		t = &tracker{rdsName: rdsName, source: source, prefixes: map[string]bool{prefix: true}, caches: map[cacheInvalidator]bool{c: true}, done: make(chan struct{})}
+		if old != nil {
+			old.close()
+			for p := range old.prefixes {
+				t.prefixes[p] = true
+			}
+			for cache := range old.caches {
+				t.caches[cache] = true
+			}
+		}
+		t.start()
+		t.flushAll()
+		trackers.Set(rdsName, t)
+		return t
+	}
+	t.mu.Lock()
+	added := !t.prefixes[prefix]
+	t.prefixes[prefix], t.caches[c] = true, true
+	t.mu.Unlock()
+	if added && t.active.Load() {
+		t.reenable()
+	}
+	return t
+}
+
+// tracks reports whether prefix is tracked for the current client of the data source
+func (t *tracker) tracks(prefix string) bool {
+	if source, _ := getDataSource(t.rdsName); source != t.source {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.prefixes[prefix]
+}
+
+func (t *tracker) start() {
+	if t.source == nil {
+		// not registered yet, tracks() asks for a new tracker once it is
+		return
+	}
+	client, ok := t.source.(*redis.Client)
+	if !ok {
+		logger.Info().Str("rds", t.rdsName).Msg("redisdb: local cache without CLIENT TRACKING on a non single node data source, entries expire by ttl")
+		return
+	}
+	c, cancel := context.WithTimeout(context.Background(), TrackingTimeout)
+	defer cancel()
+	if err := client.Do(c, "CLIENT", "TRACKINGINFO").Err(); err != nil {
+		logger.Info().Err(err).Str("rds", t.rdsName).Msg("redisdb: CLIENT TRACKING unavailable, local cache entries expire by ttl")
+		return
+	}
+
+	opt := client.Options()
+	onConnect := opt.OnConnect
+	subOpt, connOpt := *opt, *opt
+	subOpt.OnConnect = func(c context.Context, cn *redis.Conn) error {
+		if onConnect != nil {
+			if err := onConnect(c, cn); err != nil {
+				return err
+			}
+		}
+		id, err := cn.ClientID(c).Result()
+		if err == nil {
+			t.setRedirect(id)
+		}
+		return err
+	}
+	connOpt.PoolSize, connOpt.MinIdleConns = 1, 0
+	connOpt.OnConnect = func(c context.Context, cn *redis.Conn) error {
+		if onConnect != nil {
+			if err := onConnect(c, cn); err != nil {
+				return err
+			}
+		}
+		return t.enable(c, cn.Do)
+	}
+
+	t.sub = redis.NewClient(&subOpt)
+	t.pubsub = t.sub.Subscribe(c, invalidateChannel)
+	if _, err := t.pubsub.Receive(c); err != nil {
+		logger.Info().Err(err).Str("rds", t.rdsName).Msg("redisdb: subscribe to " + invalidateChannel + " failed, local cache entries expire by ttl")
+		t.close()
+		return
+	}
+	t.conn = redis.NewClient(&connOpt)
+	if err := t.conn.Ping(c).Err(); err != nil {
+		logger.Info().Err(err).Str("rds", t.rdsName).Msg("redisdb: CLIENT TRACKING failed, local cache entries expire by ttl")
+		t.close()
+		return
+	}
+	t.active.Store(true)
+	go t.listen()
+	go t.keepAlive()
+}
+
+// setRedirect records the client id of the subscription. a new id means the subscription reconnected,
+// so tracking must be redirected to it
+func (t *tracker) setRedirect(id int64) {
+	t.mu.Lock()
+	changed := t.redirect != 0 && t.redirect != id
+	t.redirect = id
+	t.mu.Unlock()
+	if changed {
+		go t.reenable()
+	}
+}
+
+// enable turns BCAST tracking on for the prefixes, on the connection do runs on; the caches are flushed,
+// since invalidations may have been missed while tracking was off
+func (t *tracker) enable(c context.Context, do func(c context.Context, args ...interface{}) *redis.Cmd) error {
+	t.mu.Lock()
+	args := []interface{}{"CLIENT", "TRACKING", "on", "REDIRECT", t.redirect, "BCAST"}
+	for _, prefix := range disjointPrefixes(t.prefixes) {
+		args = append(args, "PREFIX", prefix)
+	}
+	t.mu.Unlock()
+	err := do(c, args...).Err()
+	t.flushAll()
+	return err
+}
+
+func (t *tracker) reenable() {
+	c, cancel := context.WithTimeout(context.Background(), TrackingTimeout)
+	defer cancel()
+	if err := t.conn.Do(c, "CLIENT", "TRACKING", "off").Err(); err != nil {
+		logger.Info().Err(err).Str("rds", t.rdsName).Msg("redisdb: CLIENT TRACKING off failed")
+	}
+	if err := t.enable(c, t.conn.Do); err != nil {
+		logger.Info().Err(err).Str("rds", t.rdsName).Msg("redisdb: CLIENT TRACKING on failed")
+	}
+}
+
+// disjointPrefixes drops the prefixes covered by a shorter one, redis rejects overlapping BCAST prefixes
+func disjointPrefixes(prefixes map[string]bool) (ret []string) {
+	all := make([]string, 0, len(prefixes))
+	for prefix := range prefixes {
+		all = append(all, prefix)
+	}
+	sort.Strings(all)
+	for _, prefix := range all {
+		if len(ret) > 0 && strings.HasPrefix(prefix, ret[len(ret)-1]) {
+			continue
+		}
+		ret = append(ret, prefix)
+	}
+	return ret
+}
+
+func (t *tracker) listen() {
+	for msg := range t.pubsub.Channel() {
+		keys := msg.PayloadSlice
+		if len(keys) == 0 && msg.Payload != "" {
+			keys = []string{msg.Payload}
+		}
+		// a nil payload is sent on FLUSHDB / FLUSHALL
+		if len(keys) == 0 {
+			t.flushAll()
+			continue
+		}
+		for _, cache := range t.cacheList() {
+			for _, key := range keys {
+				cache.invalidate(t.rdsName, key)
+			}
+		}
+	}
+}
+
+// keepAlive pings the tracking connection; a reconnect runs OnConnect, which re-enables tracking
+func (t *tracker) keepAlive() {
+	ticker := time.NewTicker(TrackingKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			c, cancel := context.WithTimeout(context.Background(), TrackingTimeout)
+			t.conn.Ping(c)
+			cancel()
+		}
+	}
+}
+
+func (t *tracker) cacheList() (caches []cacheInvalidator) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for cache := range t.caches {
+		caches = append(caches, cache)
+	}
+	return caches
+}
+
+func (t *tracker) flushAll() {
+	for _, cache := range t.cacheList() {
+		cache.flush(t.rdsName)
+	}
+}
+
+func (t *tracker) close() {
+	t.active.Store(false)
+	select {
+	case <-t.done:
+	default:
+		close(t.done)
+	}
+	if t.pubsub != nil {
+		t.pubsub.Close()
+	}
+	if t.sub != nil {
+		t.sub.Close()
+	}
+	if t.conn != nil {
+		t.conn.Close()
+	}
+}
@@ -0,0 +1,97 @@
+package redisdb
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec encodes non-primitive values before they are written to redis and decodes them on read.
+// primitive values (string, int*, uint*, float*, bool) are always stored as plain text, regardless of codec.
+type Codec interface {
+	Name() string
+	Marshal(value interface{}) ([]byte, error)
+	Unmarshal(data []byte, ptr interface{}) error
+}
+
+var (
+	// MsgpackCodec is the default codec
+	MsgpackCodec Codec = msgpackCodec{}
+	// JSONCodec stores values as json, readable by any language without schema knowledge
+	JSONCodec Codec = jsonCodec{}
+	// CBORCodec stores values as cbor (RFC 8949)
+	CBORCodec Codec = cborCodec{}
+	// ProtobufCodec stores proto.Message values in protobuf wire format. v must be a pointer to a generated message
+	ProtobufCodec Codec = protobufCodec{}
+	// RawCodec stores []byte / string values as is
+	RawCodec Codec = rawCodec{}
+)
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string                              { return "msgpack" }
+func (msgpackCodec) Marshal(value interface{}) ([]byte, error) { return msgpack.Marshal(value) }
+func (msgpackCodec) Unmarshal(data []byte, ptr interface{}) error {
+	return msgpack.Unmarshal(data, ptr)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                                 { return "json" }
+func (jsonCodec) Marshal(value interface{}) ([]byte, error)    { return json.Marshal(value) }
+func (jsonCodec) Unmarshal(data []byte, ptr interface{}) error { return json.Unmarshal(data, ptr) }
+
+type cborCodec struct{}
+
+func (cborCodec) Name() string                                 { return "cbor" }
+func (cborCodec) Marshal(value interface{}) ([]byte, error)    { return cbor.Marshal(value) }
+func (cborCodec) Unmarshal(data []byte, ptr interface{}) error { return cbor.Unmarshal(data, ptr) }
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string { return "protobuf" }
+func (protobufCodec) Marshal(value interface{}) ([]byte, error) {
+	msg, ok := value.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf codec: %T is not a proto.Message", value)
+	}
+	return proto.Marshal(msg)
+}
+func (protobufCodec) Unmarshal(data []byte, ptr interface{}) error {
+	msg, ok := ptr.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf codec: %T is not a proto.Message", ptr)
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+type rawCodec struct{}
+
+func (rawCodec) Name() string { return "raw" }
+func (rawCodec) Marshal(value interface{}) ([]byte, error) {
+	switch val := value.(type) {
+	case []byte:
+		return val, nil
+	case string:
+		return []byte(val), nil
+	case nil:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("raw codec: unsupported type %T", value)
+}
+func (rawCodec) Unmarshal(data []byte, ptr interface{}) error {
+	switch p := ptr.(type) {
+	case *[]byte:
+		*p = append((*p)[:0], data...)
+	case *string:
+		*p = string(data)
+	case *interface{}:
+		*p = append([]byte(nil), data...)
+	default:
+		return fmt.Errorf("raw codec: unsupported type %T", ptr)
+	}
+	return nil
+}
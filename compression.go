@@ -0,0 +1,153 @@
+package redisdb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression selects the algorithm used to compress serialized values above the threshold.
+type Compression byte
+
+const (
+	CompressionNone Compression = iota
+	CompressionZstd
+	CompressionSnappy
+	CompressionGzip
+)
+
+// DefaultCompressThreshold is used when WithCompression is given a threshold <= 0
+const DefaultCompressThreshold = 1024
+
+// compressed values are stored as [compressedMagic, algorithm, payload...].
+// 0xc1 is never used by msgpack and is never the first byte of valid utf-8, but other codecs (protobuf, RawCodec) may start a value with it:
+// the header is only looked for on keys with compression configured, and a payload that doesn't decompress is taken as a plain value
+const compressedMagic byte = 0xc1
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+func zstdCodec() (*zstd.Encoder, *zstd.Decoder) {
+	zstdOnce.Do(func() {
+		zstdEncoder, _ = zstd.NewWriter(nil)
+		zstdDecoder, _ = zstd.NewReader(nil)
+	})
+	return zstdEncoder, zstdDecoder
+}
+
+func compressBytes(algo Compression, threshold int, data []byte) ([]byte, error) {
+	if algo == CompressionNone || len(data) < threshold {
+		return data, nil
+	}
+	var out []byte
+	switch algo {
+	case CompressionZstd:
+		enc, _ := zstdCodec()
+		out = enc.EncodeAll(data, []byte{compressedMagic, byte(algo)})
+	case CompressionSnappy:
+		out = append([]byte{compressedMagic, byte(algo)}, snappy.Encode(nil, data)...)
+	case CompressionGzip:
+		var buf bytes.Buffer
+		buf.Write([]byte{compressedMagic, byte(algo)})
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		out = buf.Bytes()
+	default:
+		return nil, fmt.Errorf("unsupported compression: %d", algo)
+	}
+	// keep the original if compression doesn't pay off
+	if len(out) >= len(data) {
+		return data, nil
+	}
+	return out, nil
+}
+
+// decompressBytes returns data unchanged when it carries no compression header, so values written before compression was enabled stay readable.
+// data with the header that doesn't decompress is a plain value starting with the header bytes, also returned unchanged
+func decompressBytes(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != compressedMagic {
+		return data, nil
+	}
+	var plain []byte
+	var err error
+	switch Compression(data[1]) {
+	case CompressionZstd:
+		_, dec := zstdCodec()
+		plain, err = dec.DecodeAll(data[2:], nil)
+	case CompressionSnappy:
+		plain, err = snappy.Decode(nil, data[2:])
+	case CompressionGzip:
+		var r *gzip.Reader
+		if r, err = gzip.NewReader(bytes.NewReader(data[2:])); err == nil {
+			defer r.Close()
+			plain, err = io.ReadAll(r)
+		}
+	default:
+		return data, nil
+	}
+	if err != nil {
+		return data, nil
+	}
+	return plain, nil
+}
+
+func (ctx *RedisKey[k, v]) compress(data []byte) ([]byte, error) {
+	return compressBytes(ctx.Compression, ctx.CompressThreshold, data)
+}
+
+// decompress undoes compress. without compression configured data is never taken as compressed:
+// turning compression off leaves the values written compressed unreadable
+func (ctx *RedisKey[k, v]) decompress(data []byte) ([]byte, error) {
+	if ctx.Compression == CompressionNone {
+		return data, nil
+	}
+	return decompressBytes(data)
+}
+
+// wrapCompression makes SerializeValue compress its output and the deserializers decompress their input.
+// keys without compression are left as they are
+func (ctx *RedisKey[k, v]) wrapCompression() {
+	if ctx.Compression == CompressionNone {
+		return
+	}
+	serialize, algo, threshold := ctx.SerializeValue, ctx.Compression, ctx.CompressThreshold
+	ctx.SerializeValue = func(value interface{}) (string, error) {
+		str, err := serialize(value)
+		if err != nil {
+			return str, err
+		}
+		data, err := compressBytes(algo, threshold, []byte(str))
+		return string(data), err
+	}
+	deserialize, deserializes := ctx.DeserializeToValue, ctx.DeserializeToValues
+	ctx.DeserializeToValue = func(data []byte) (value v, err error) {
+		if data, err = decompressBytes(data); err != nil {
+			return value, err
+		}
+		return deserialize(data)
+	}
+	ctx.DeserializeToValues = func(strs []string) (values []v, err error) {
+		plain := make([]string, len(strs))
+		for i, str := range strs {
+			data, err := decompressBytes([]byte(str))
+			if err != nil {
+				return nil, err
+			}
+			plain[i] = string(data)
+		}
+		return deserializes(plain)
+	}
+}
@@ -0,0 +1,36 @@
+package redisdb_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/doptime/redisdb"
+)
+
+func TestCompression(t *testing.T) {
+	srv, rds := newServer(t)
+	docs := redisdb.NewHashKey[string, []byte](rds.Key("docs").Codec(redisdb.RawCodec).Compress(redisdb.CompressionZstd, 64))
+	large := []byte(strings.Repeat("redis ", 100))
+	if _, err := docs.HSet("large", large); err != nil {
+		t.Fatal(err)
+	}
+	if raw := srv.HGet("docs", "large"); len(raw) >= len(large) || raw[0] != 0xc1 {
+		t.Fatalf("stored %d bytes, want a compressed value", len(raw))
+	}
+	// a plain value that starts like a compressed one
+	header := []byte{0xc1, 0x01, 'x'}
+	docs.HSet("header", header)
+	for field, want := range map[string][]byte{"large": large, "header": header} {
+		if got, err := docs.HGet(field); err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("HGet %s = %q, %v", field, got, err)
+		}
+	}
+
+	// without compression the header means nothing
+	raw := redisdb.NewHashKey[string, []byte](rds.Key("raw").Codec(redisdb.RawCodec))
+	raw.HSet("header", header)
+	if got, err := raw.HGet("header"); err != nil || !bytes.Equal(got, header) {
+		t.Fatalf("HGet = %q, %v", got, err)
+	}
+}
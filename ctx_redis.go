@@ -4,21 +4,27 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sync/atomic"
 	"time"
 
-	"github.com/doptime/config/cfgredis"
 	"github.com/doptime/logger"
 	"github.com/redis/go-redis/v9"
-	"github.com/vmihailenco/msgpack/v5"
 )
 
 type RedisKey[k comparable, v any] struct {
 	Context context.Context
 	RdsName string
-	Rds     *redis.Client
+	// Rds is the client RdsName resolved to when the key was created, nil if it wasn't available then.
+	// commands don't use it: they resolve RdsName each time, see RegisterDataSource
+	Rds redis.UniversalClient
 
 	Key     string
 	KeyType KeyType
+	Codec   Codec
+
+	Compression       Compression
+	CompressThreshold int
+	KeyProvider       KeyProvider
 
 	SerializeKey         func(value interface{}) (msgpack string, err error)
 	SerializeValue       func(value interface{}) (msgpack string, err error)
@@ -27,7 +33,29 @@ type RedisKey[k comparable, v any] struct {
 	timestampFiller      func(in v) error
 	Validator            func(in v) error
 	UseModer             bool
+	UseEncryptor         bool
 	PrimaryKeyFieldIndex int
+	VersionFieldIndex    int
+
+	// ReadRdsNames are the replicas read commands go to, see WithReadRds
+	ReadRdsNames []string
+	readNext     *atomic.Uint32
+	cache        *localCache
+	// NegativeTTL, TTLJitter and StaleTTL tune GetOrLoad / HGetOrLoad, see WithNegativeCache
+	NegativeTTL time.Duration
+	TTLJitter   float64
+	StaleTTL    time.Duration
+	// DefaultTTL is applied on writes, and restarted on reads if SlidingTTL, see WithTTL
+	DefaultTTL time.Duration
+	SlidingTTL bool
+	touched    *touchThrottle
+	// StreamMaxLen and StreamRetention trim a stream on XAdd, see WithMaxLen and WithRetention
+	StreamMaxLen    int64
+	StreamRetention time.Duration
+
+	// initErr is the configuration error the key was created with, errRds fails every command with it
+	initErr error
+	errRds  redis.UniversalClient
 }
 
 func (ctx *RedisKey[k, v]) GetKeyType() KeyType {
@@ -42,17 +70,78 @@ func (ctx *RedisKey[k, v]) V(value v) (ret v) {
 }
 
 func (ctx *RedisKey[k, v]) Duplicate(newKey, RdsSourceName string) (newCtx RedisKey[k, v]) {
-	return RedisKey[k, v]{ctx.Context, RdsSourceName, ctx.Rds, newKey, ctx.KeyType,
-		ctx.SerializeKey, ctx.SerializeValue, ctx.DeserializeToValue, ctx.DeserializeToValues, ctx.timestampFiller, ctx.Validator,
-		ctx.UseModer, ctx.PrimaryKeyFieldIndex}
+	newCtx = *ctx
+	newCtx.Key, newCtx.RdsName = newKey, RdsSourceName
+	if RdsSourceName != ctx.RdsName {
+		newCtx.Rds, _ = getDataSource(RdsSourceName)
+	}
+	return newCtx
+}
+
+// rds resolves the data source of the key. it is looked up on every command, so keys created before
+// RegisterDataSource / config loading work once the data source is there, and fail with ErrDataSourceUnavailable until then
+func (ctx *RedisKey[k, v]) rds() redis.UniversalClient {
+	if ctx.errRds != nil {
+		return ctx.errRds
+	}
+	return dataSource(ctx.RdsName)
+}
+
+// Err returns the configuration error the key was created with, e.g. no key name.
+// constructors don't return nil on such errors: the key is returned and every command fails with Err()
+func (ctx *RedisKey[k, v]) Err() error {
+	return ctx.initErr
+}
+
+// invalidate records a constructor failure, see Err
+func (ctx *RedisKey[k, v]) invalidate(constructor string, err error) {
+	logger.Error().Err(err).Msg("redisdb." + constructor + " failed")
+	ctx.initErr = fmt.Errorf("redisdb.%s: %w", constructor, err)
+	ctx.errRds = newFailingClient(ctx.initErr)
+}
+
+// mustBeUsable panics if the key was created with an error or its data source is not available yet.
+// used by the MustNew* constructors for strict startup
+func (ctx *RedisKey[k, v]) mustBeUsable(constructor string) {
+	err := ctx.initErr
+	if _, ok := getDataSource(ctx.RdsName); err == nil && !ok {
+		err = fmt.Errorf("%w: %s", ErrDataSourceUnavailable, ctx.RdsName)
+	}
+	if err != nil {
+		panic(fmt.Errorf("redisdb.%s[%s, %s](key %q, rds %q): %w", constructor,
+			reflect.TypeOf((*k)(nil)).Elem(), reflect.TypeOf((*v)(nil)).Elem(), ctx.Key, ctx.RdsName, err))
+	}
+}
+
+// withCtx returns a copy of ctx whose redis commands run under c, so cancellation, deadlines and tracing spans reach redis
+func (ctx *RedisKey[k, v]) withCtx(c context.Context) (newCtx RedisKey[k, v]) {
+	if c == nil {
+		c = context.Background()
+	}
+	newCtx = *ctx
+	newCtx.Context = c
+	return newCtx
+}
+
+// WithCtx is withCtx for the generic key of NewRedisKey, the typed keys have their own
+func (ctx *RedisKey[k, v]) WithCtx(c context.Context) *RedisKey[k, v] {
+	newCtx := ctx.withCtx(c)
+	return &newCtx
 }
 
 func (ctx *RedisKey[k, v]) InitFunc() {
 	ctx.Context = context.Background()
-	ctx.SerializeKey = ctx.getSerializeFun(reflect.TypeOf((*k)(nil)).Elem().Kind())
-	ctx.SerializeValue = ctx.getSerializeFun(reflect.TypeOf((*v)(nil)).Elem().Kind())
+	if ctx.Codec == nil {
+		ctx.Codec = MsgpackCodec
+	}
+	ctx.SerializeKey = ctx.getSerializeFun(reflect.TypeOf((*k)(nil)).Elem().Kind(), MsgpackCodec)
+	ctx.SerializeValue = ctx.getSerializeFun(reflect.TypeOf((*v)(nil)).Elem().Kind(), ctx.Codec)
 	ctx.DeserializeToValue = ctx.getDeserializetoValueFunc()
 	ctx.DeserializeToValues = ctx.toValuesFunc()
+	//encrypt -> codec -> compress on write, the reverse on read
+	ctx.wrapEncryption()
+	ctx.wrapCompression()
+	ctx.VersionFieldIndex = versionFieldIndex(reflect.TypeOf((*v)(nil)).Elem())
 	ctx.timestampFiller = ctx.NewTimestampFiller()
 	ctx.Validator = ctx.NewValidator()
 }
@@ -60,42 +149,47 @@ func (ctx *RedisKey[k, v]) InitFunc() {
 func NewRedisKey[k comparable, v any](ops ...Option) *RedisKey[k, v] {
 	ctx := &RedisKey[k, v]{}
 	if err := ctx.applyOptionsAndCheck(KeyTypeNon, ops...); err != nil {
-		logger.Error().Err(err).Msg("redisdb.NewRedisKey failed")
-		return nil
+		ctx.invalidate("NewRedisKey", err)
 	}
 
 	ctx.InitFunc()
 	return ctx
 }
 func (ctx *RedisKey[k, v]) Time() (tm time.Time, err error) {
-	cmd := ctx.Rds.Time(ctx.Context)
+	cmd := ctx.rds().Time(ctx.Context)
 	return cmd.Result()
 }
 func (ctx *RedisKey[k, v]) GetUseModer() bool {
 	return ctx.UseModer
 }
 
-// sacn key by pattern
+// sacn key by pattern. on a cluster every master is scanned
 func (ctx *RedisKey[k, v]) Scan(cursorOld uint64, match string, count int64) (keys []string, cursorNew uint64, err error) {
-	var (
-		cmd   *redis.ScanCmd
-		_keys []string
-	)
-	//scan all keys
-	for {
-
-		if cmd = ctx.Rds.Scan(ctx.Context, cursorOld, match, count); cmd.Err() != nil {
-			return nil, 0, cmd.Err()
-		}
-		if _keys, cursorNew, err = cmd.Result(); err != nil {
-			return nil, 0, err
-		}
-		keys = append(keys, _keys...)
-		if cursorNew == 0 {
-			break
+	keys, err = collectFromMasters(ctx.Context, ctx.reader(), func(c context.Context, node redis.UniversalClient) (keys []string, err error) {
+		var (
+			cmd    *redis.ScanCmd
+			_keys  []string
+			cursor = cursorOld
+		)
+		//scan all keys
+		for {
+			if cmd = node.Scan(c, cursor, match, count); cmd.Err() != nil {
+				return nil, cmd.Err()
+			}
+			if _keys, cursor, err = cmd.Result(); err != nil {
+				return nil, err
+			}
+			keys = append(keys, _keys...)
+			if cursor == 0 {
+				break
+			}
 		}
+		return keys, nil
+	})
+	if err != nil {
+		return nil, 0, err
 	}
-	return keys, cursorNew, nil
+	return keys, 0, nil
 }
 
 type KeyType string
@@ -128,8 +222,10 @@ func (ctx *RedisKey[k, v]) applyOptionsAndCheck(keyType KeyType, opts ...Option)
 		RedisDataSource: "default",
 		Modifiers:       map[string]ModifierFunc{},
 	}
-	OptionDefault.RedisKey, _ = GetValidDataKeyName((*v)(nil))
+	var keyNameErr error
+	OptionDefault.RedisKey, keyNameErr = GetValidDataKeyName((*v)(nil))
 	opts = append([]Option{OptionDefault}, opts...)
+	modifiers := map[string]ModifierFunc{}
 	for _, opt := range opts {
 		if len(opt.KeyType) > 0 {
 			ctx.KeyType = opt.KeyType
@@ -140,19 +236,65 @@ func (ctx *RedisKey[k, v]) applyOptionsAndCheck(keyType KeyType, opts ...Option)
 		if len(opt.RedisDataSource) > 0 {
 			ctx.RdsName = opt.RedisDataSource
 		}
-		if len(opt.Modifiers) > 0 {
-			ctx.UseModer = RegisterStructModifiers(opt.Modifiers, reflect.TypeOf((*v)(nil)).Elem())
+		for name, modifier := range opt.Modifiers {
+			modifiers[name] = modifier
+		}
+		if opt.ValueCodec != nil {
+			ctx.Codec = opt.ValueCodec
+		}
+		if opt.Compression != CompressionNone {
+			ctx.Compression, ctx.CompressThreshold = opt.Compression, opt.CompressThreshold
+			if ctx.CompressThreshold <= 0 {
+				ctx.CompressThreshold = DefaultCompressThreshold
+			}
+		}
+		if opt.KeyRing != nil {
+			ctx.KeyProvider = opt.KeyRing
+		}
+		if len(opt.ReadDataSources) > 0 {
+			ctx.ReadRdsNames, ctx.readNext = opt.ReadDataSources, new(atomic.Uint32)
+		}
+		if opt.CacheSize > 0 {
+			ctx.cache = newLocalCache(opt.CacheSize, opt.CacheTTL)
+		}
+		if opt.NegativeTTL > 0 {
+			ctx.NegativeTTL = opt.NegativeTTL
+		}
+		if opt.TTLJitter > 0 {
+			ctx.TTLJitter = opt.TTLJitter
+		}
+		if opt.StaleTTL > 0 {
+			ctx.StaleTTL = opt.StaleTTL
+		}
+		if opt.DefaultTTL > 0 {
+			ctx.DefaultTTL, ctx.SlidingTTL = opt.DefaultTTL, opt.SlidingExpiry
+			if ctx.SlidingTTL {
+				ctx.touched = newTouchThrottle()
+			}
+		}
+		if opt.StreamMaxLen > 0 {
+			ctx.StreamMaxLen = opt.StreamMaxLen
+		}
+		if opt.StreamRetention > 0 {
+			ctx.StreamRetention = opt.StreamRetention
 		}
 
 	}
+	if len(modifiers) > 0 {
+		ctx.UseModer = RegisterStructModifiers(modifiers, reflect.TypeOf((*v)(nil)).Elem())
+	}
+	if ctx.UseEncryptor, err = RegisterStructEncryptors(reflect.TypeOf((*v)(nil)).Elem()); err != nil {
+		return err
+	}
 	//check if  options are valid
 	if len(ctx.Key) == 0 {
-		return fmt.Errorf("invalid data.Ctx Key name")
-	}
-	var exists bool
-	if ctx.Rds, exists = cfgredis.Servers.Get(ctx.RdsName); !exists {
-		return fmt.Errorf("rds item unconfigured: " + ctx.RdsName)
+		if keyNameErr != nil {
+			return fmt.Errorf("no key name, use WithKey: %w", keyNameErr)
+		}
+		return fmt.Errorf("no key name, use WithKey")
 	}
+	// a data source registered later is picked up by the commands, see rds()
+	ctx.Rds, _ = getDataSource(ctx.RdsName)
 
 	return nil
 }
@@ -200,14 +342,24 @@ func (ctx *RedisKey[k, v]) toKeyValueStrs(keyValue ...interface{}) (keyValStrs [
 	}
 	return keyValStrs, nil
 }
+
+// unmarshalPayload decodes a value sent over HTTP with the codec of the key
+func (ctx *RedisKey[k, v]) unmarshalPayload(data []byte) (value v, err error) {
+	if vType := reflect.TypeOf((*v)(nil)).Elem(); vType.Kind() == reflect.Ptr {
+		value = reflect.New(vType.Elem()).Interface().(v)
+		return value, ctx.Codec.Unmarshal(data, value)
+	}
+	return value, ctx.Codec.Unmarshal(data, &value)
+}
+
 func (ctx *RedisKey[k, v]) DeserializeToInterface(msgpackBytes []byte) (rets interface{}, err error) {
 
 	if len(msgpackBytes) == 0 {
 		return nil, fmt.Errorf("msgpackBytes is empty")
 	}
 
-	var vInstance v
-	if err = msgpack.Unmarshal(msgpackBytes, &vInstance); err != nil {
+	vInstance, err := ctx.unmarshalPayload(msgpackBytes)
+	if err != nil {
 		return nil, err
 	}
 
@@ -234,8 +386,8 @@ func (ctx *RedisKey[k, v]) DeserializeToInterfaceSlice(msgpacks []string) (rets
 			return nil, fmt.Errorf("msgpackBytes is empty")
 		}
 
-		var vInstance v
-		if err = msgpack.Unmarshal([]byte(mp), &vInstance); err != nil {
+		vInstance, err := ctx.unmarshalPayload([]byte(mp))
+		if err != nil {
 			return nil, err
 		}
 
@@ -264,13 +416,15 @@ func (ctx *RedisKey[k, v]) MsgpackUnmarshalKeyValues(msgpack []byte) (rets inter
 	return nil, nil
 }
 
+// Keys returns every key under "ctx.Key:*" at once, with KEYS on each master, which blocks the server on large key spaces.
+//
+// Deprecated: use KeysIter, which scans page by page. Keys is kept for existing callers.
 func (ctx *RedisKey[k, v]) Keys() (out []k, err error) {
-	var (
-		cmd  *redis.StringSliceCmd
-		keys []string
-	)
-	cmd = ctx.Rds.Keys(ctx.Context, ctx.Key+":*")
-	if keys, err = cmd.Result(); err != nil {
+	var keys []string
+	keys, err = collectFromMasters(ctx.Context, ctx.reader(), func(c context.Context, node redis.UniversalClient) ([]string, error) {
+		return node.Keys(c, ctx.Key+":*").Result()
+	})
+	if err != nil {
 		return nil, err
 	}
 	return ctx.toKeys(keys)
@@ -288,7 +442,7 @@ func (ctx *RedisKey[k, v]) Set(key k, param v, expiration time.Duration) (err er
 	if valStr, err = ctx.SerializeValue(param); err != nil {
 		return err
 	} else {
-		status := ctx.Rds.Set(ctx.Context, ctx.Key+":"+keyStr, valStr, expiration)
+		status := ctx.rds().Set(ctx.Context, ctx.Key+":"+keyStr, valStr, expiration)
 		return status.Err()
 	}
 }
@@ -301,6 +455,6 @@ func (ctx *RedisKey[k, v]) Del(key k) (err error) {
 	if keyStr, err = ctx.SerializeKey(key); err != nil {
 		return err
 	}
-	status := ctx.Rds.Del(ctx.Context, ctx.Key+":"+keyStr)
+	status := ctx.rds().Del(ctx.Context, ctx.Key+":"+keyStr)
 	return status.Err()
 }
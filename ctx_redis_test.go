@@ -0,0 +1,63 @@
+package redisdb_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/doptime/redisdb"
+)
+
+func TestConstructorErrorIsDeferred(t *testing.T) {
+	_, rds := newServer(t)
+	// no WithKey, and "string" can't be used as a key name
+	names := redisdb.NewStringKey[string, string](rds)
+	if names == nil {
+		t.Fatal("NewStringKey returned nil")
+	}
+	if names.Err() == nil {
+		t.Fatal("Err() = nil for a key without a name")
+	}
+	if err := names.Set("a", "b", 0); !errors.Is(err, names.Err()) {
+		t.Fatalf("Set = %v, want %v", err, names.Err())
+	}
+	if _, err := names.Get("a"); !errors.Is(err, names.Err()) {
+		t.Fatalf("Get = %v, want %v", err, names.Err())
+	}
+}
+
+func TestMustNewPanics(t *testing.T) {
+	srv, rds := newServer(t)
+	mustPanic := func(name string, want error, fn func()) {
+		t.Helper()
+		defer func() {
+			r := recover()
+			err, ok := r.(error)
+			if !ok || !strings.Contains(err.Error(), name) || (want != nil && !errors.Is(err, want)) {
+				t.Fatalf("%s: recovered %v", name, r)
+			}
+		}()
+		fn()
+	}
+	mustPanic("MustNewHashKey", nil, func() { redisdb.MustNewHashKey[string, string](rds) })
+	mustPanic("MustNewListKey", redisdb.ErrDataSourceUnavailable, func() {
+		redisdb.MustNewListKey[string](redisdb.WithKey("jobs"), redisdb.WithRds(srv.Name+"-missing"))
+	})
+	if key := redisdb.MustNewZSetKey[string, string](rds.Key("rank")); key.Err() != nil {
+		t.Fatal(key.Err())
+	}
+}
+
+func TestRedisKeyWithCtx(t *testing.T) {
+	_, rds := newServer(t)
+	key := redisdb.NewRedisKey[string, string](rds.Key("generic"))
+	c, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := key.WithCtx(c).Time(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Time under a canceled context = %v, want context.Canceled", err)
+	}
+	if _, err := key.Time(); err != nil {
+		t.Fatalf("Time on the original key = %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package redisdb
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type readPrimaryKey struct{}
+
+// ReadFromPrimary returns a context under which keys read from their primary data source even if WithReadRds is set,
+// for read-your-writes. pass it to WithCtx, or to GetHttpXxxKeyWithCtx for the http read paths
+func ReadFromPrimary(c context.Context) context.Context {
+	if c == nil {
+		c = context.Background()
+	}
+	return context.WithValue(c, readPrimaryKey{}, true)
+}
+
+// reader resolves the data source of a read command: the next available replica of WithReadRds, or the primary
+func (ctx *RedisKey[k, v]) reader() redis.UniversalClient {
+	if len(ctx.ReadRdsNames) == 0 || ctx.errRds != nil {
+		return ctx.rds()
+	}
+	if primary, _ := ctx.Context.Value(readPrimaryKey{}).(bool); primary {
+		return ctx.rds()
+	}
+	start := ctx.readNext.Add(1)
+	for i := range uint32(len(ctx.ReadRdsNames)) {
+		name := ctx.ReadRdsNames[(start+i)%uint32(len(ctx.ReadRdsNames))]
+		if rds, ok := getDataSource(name); ok {
+			return rds
+		}
+	}
+	return ctx.rds()
+}
@@ -0,0 +1,64 @@
+package redisdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/doptime/redisdb"
+	"github.com/doptime/redisdb/redistest"
+)
+
+func TestReadRdsRouting(t *testing.T) {
+	primary, rds := newServer(t)
+	replica := redistest.Run(t, t.Name()+"-replica")
+	tags := redisdb.NewSetKey[string, string](rds.Key("tags").ReadRds(replica.Name))
+	if err := tags.SAdd("primary"); err != nil {
+		t.Fatal(err)
+	}
+	if !primary.Exists("tags") || replica.Exists("tags") {
+		t.Fatal("write did not go to the primary")
+	}
+	// nothing replicates between the two servers, so what a read returns tells where it went
+	replica.SAdd("tags", "replica")
+	if members, _ := tags.SMembers(); len(members) != 1 || members[0] != "replica" {
+		t.Fatalf("SMembers = %v, want the replica's members", members)
+	}
+	if members, _ := tags.Primary().SMembers(); len(members) != 1 || members[0] != "primary" {
+		t.Fatalf("Primary().SMembers = %v, want the primary's members", members)
+	}
+	ok, err := tags.WithCtx(redisdb.ReadFromPrimary(nil)).SIsMember("primary")
+	if err != nil || !ok {
+		t.Fatalf("SIsMember under ReadFromPrimary = %v, %v", ok, err)
+	}
+}
+
+func TestReadRdsRoundRobinAndFallback(t *testing.T) {
+	primary, rds := newServer(t)
+	r1 := redistest.Run(t, t.Name()+"-r1")
+	r2 := redistest.Run(t, t.Name()+"-r2")
+	names := redisdb.NewStringKey[string, string](rds.Key("name").ReadRds(r1.Name, r2.Name, t.Name()+"-missing"))
+	primary.Set("name:a", "primary")
+	r1.Set("name:a", "r1")
+	r2.Set("name:a", "r2")
+	seen := map[string]int{}
+	for i := 0; i < 6; i++ {
+		v, err := names.Get("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[v]++
+	}
+	if seen["r1"] == 0 || seen["r2"] == 0 || seen["primary"] != 0 {
+		t.Fatalf("reads = %v, want both replicas and never the primary", seen)
+	}
+
+	// with no replica available reads fall back to the primary
+	r1.Close()
+	r2.Close()
+	if v, err := names.Get("a"); err != nil || v != "primary" {
+		t.Fatalf("Get = %q, %v, want the primary's value", v, err)
+	}
+	if _, err := names.Get("b"); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("Get missing = %v", err)
+	}
+}
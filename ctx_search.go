@@ -1,6 +1,7 @@
 package redisdb
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"math"
@@ -22,9 +23,6 @@ func NewSearchKey[k comparable, v any](indexName string, ops ...Option) *SearchK
 
 	// 初始化基础 HashKey
 	baseKey := NewHashKey[k, v](ops...)
-	if baseKey == nil {
-		return nil
-	}
 
 	// IndexName 默认为 Key 的前缀，或者用户指定
 	if indexName == "" {
@@ -39,6 +37,9 @@ func NewSearchKey[k comparable, v any](indexName string, ops ...Option) *SearchK
 
 	// 自动检查并创建索引 (Schema 自省)
 	// 注意：在 AI 场景下自动处理更友好，防止因为忘记建索引导致无法搜索
+	if baseKey.Err() != nil {
+		return sk
+	}
 	if err := sk.EnsureIndex(); err != nil {
 		// 这里记录错误但不中断，因为可能是连接问题
 		fmt.Printf("Warning: Failed to ensure index %s: %v\n", indexName, err)
@@ -47,6 +48,23 @@ func NewSearchKey[k comparable, v any](indexName string, ops ...Option) *SearchK
 	return sk
 }
 
+// MustNewSearchKey 同 NewSearchKey,配置错误或数据源不可用时 panic
+func MustNewSearchKey[k comparable, v any](indexName string, ops ...Option) *SearchKey[k, v] {
+	ctx := NewSearchKey[k, v](indexName, ops...)
+	ctx.mustBeUsable("MustNewSearchKey")
+	return ctx
+}
+
+// WithCtx 返回绑定到 c 的副本,索引信息不变
+func (ctx *SearchKey[k, v]) WithCtx(c context.Context) *SearchKey[k, v] {
+	return &SearchKey[k, v]{HashKey: ctx.HashKey.WithCtx(c), IndexName: ctx.IndexName, Prefix: ctx.Prefix}
+}
+
+// Primary 返回从主库读取的副本(WithReadRds 时用于读己之写)
+func (ctx *SearchKey[k, v]) Primary() *SearchKey[k, v] {
+	return ctx.WithCtx(ReadFromPrimary(ctx.Context))
+}
+
 // Put 这是一个对 AI 友好的别名，本质是 HSet，但会自动将 Struct 拆解为 Flat Hash
 func (ctx *SearchKey[k, v]) Put(id k, doc v) error {
 	// 将结构体转换为 map[string]interface{} 以便存储为独立的 Hash 字段
@@ -59,7 +77,7 @@ func (ctx *SearchKey[k, v]) Put(id k, doc v) error {
 	keyStr, _ := ctx.SerializeKey(id)
 	fullKey := ctx.Key + ":" + keyStr
 
-	return ctx.Rds.HSet(ctx.Context, fullKey, flatFields).Err()
+	return ctx.rds().HSet(ctx.Context, fullKey, flatFields).Err()
 }
 
 // Search 执行文本搜索
@@ -78,7 +96,7 @@ func (ctx *SearchKey[k, v]) Search(query string, options ...SearchOption) ([]v,
 	// DIALECT 2 必须开启以获得更规范的 JSON/Array 响应
 	args = append(args, "DIALECT", 2)
 
-	cmd := ctx.Rds.Do(ctx.Context, args...)
+	cmd := ctx.reader().Do(ctx.Context, args...)
 	if cmd.Err() != nil {
 		return nil, 0, cmd.Err()
 	}
@@ -105,7 +123,7 @@ func (ctx *SearchKey[k, v]) VectorSearch(vectorField string, vector []float32, t
 		"DIALECT", 2,
 	}
 
-	cmd := ctx.Rds.Do(ctx.Context, args...)
+	cmd := ctx.reader().Do(ctx.Context, args...)
 	if cmd.Err() != nil {
 		return nil, nil, cmd.Err()
 	}
@@ -120,7 +138,7 @@ func (ctx *SearchKey[k, v]) VectorSearch(vectorField string, vector []float32, t
 // 实现了幂等性：如果索引已存在则跳过，如果 Tag 变更目前不会自动更新（需要人工 Drop）
 func (ctx *SearchKey[k, v]) EnsureIndex() error {
 	// 1. 检查索引是否存在 (使用 FT.INFO)
-	_, err := ctx.Rds.Do(ctx.Context, "FT.INFO", ctx.IndexName).Result()
+	_, err := ctx.rds().Do(ctx.Context, "FT.INFO", ctx.IndexName).Result()
 	if err == nil {
 		return nil // 索引已存在，无需操作
 	}
@@ -141,7 +159,7 @@ func (ctx *SearchKey[k, v]) EnsureIndex() error {
 	}
 	args = append(args, schemaArgs...)
 
-	err = ctx.Rds.Do(ctx.Context, args...).Err()
+	err = ctx.rds().Do(ctx.Context, args...).Err()
 	if err != nil {
 		// 如果是因为并发导致索引已经存在，我们忽略这个错误
 		if strings.Contains(err.Error(), "BUSYGROUP") || strings.Contains(err.Error(), "Index already exists") {
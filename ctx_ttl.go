@@ -0,0 +1,163 @@
+package redisdb
+
+import (
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// writeWithTTL runs the write of key, in a MULTI with the PEXPIRE of WithTTL if set:
+// the key never stays without its ttl, and a write that is rejected before it is sent doesn't restart it
+func writeWithTTL[k comparable, v any, C redis.Cmder](ctx *RedisKey[k, v], key string, write func(rds redis.Cmdable) C) C {
+	if ctx.DefaultTTL <= 0 {
+		return write(ctx.rds())
+	}
+	var cmd C
+	ctx.rds().TxPipelined(ctx.Context, func(pipe redis.Pipeliner) error {
+		cmd = write(pipe)
+		pipe.PExpire(ctx.Context, key, ctx.DefaultTTL)
+		return nil
+	})
+	return cmd
+}
+
+// expiration is the expiration of a SET: the default ttl of WithTTL if none is given
+func (ctx *RedisKey[k, v]) expiration(expiration time.Duration) time.Duration {
+	if expiration == 0 && ctx.DefaultTTL > 0 {
+		return ctx.DefaultTTL
+	}
+	return expiration
+}
+
+// touch restarts the ttl of key after a read, with WithSlidingTTL.
+// a key is touched at most once per DefaultTTL/touchEvery, so busy keys and cache hits don't cost a PEXPIRE each
+func (ctx *RedisKey[k, v]) touch(key string) {
+	if ctx.SlidingTTL && ctx.DefaultTTL > 0 && ctx.touched.due(key, ctx.DefaultTTL/touchEvery) {
+		ctx.rds().PExpire(ctx.Context, key, ctx.DefaultTTL)
+	}
+}
+
+// touchEvery is how many times per ttl a key is touched at most; a sliding ttl may end up to DefaultTTL/touchEvery early
+const touchEvery = 10
+
+// maxTouched bounds the keys remembered by a touchThrottle
+const maxTouched = 10000
+
+// touchThrottle remembers when keys were last touched
+type touchThrottle struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func newTouchThrottle() *touchThrottle {
+	return &touchThrottle{last: map[string]time.Time{}}
+}
+
+// due reports whether key wasn't touched within interval, and records it as touched now
+func (t *touchThrottle) due(key string, interval time.Duration) bool {
+	if t == nil {
+		return true
+	}
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if last, ok := t.last[key]; ok && now.Sub(last) < interval {
+		return false
+	}
+	if len(t.last) >= maxTouched {
+		for key, last := range t.last {
+			if now.Sub(last) >= interval {
+				delete(t.last, key)
+			}
+		}
+		if len(t.last) >= maxTouched {
+			clear(t.last)
+		}
+	}
+	t.last[key] = now
+	return true
+}
+
+// expireResult maps the reply of EXPIRE / PEXPIREAT, false when the key doesn't exist
+func expireResult(ok bool, err error) error {
+	if err == nil && !ok {
+		return ErrNotFound
+	}
+	return err
+}
+
+// ttlResult maps the reply of PTTL: ErrNotFound when the key doesn't exist, -1 when it has no ttl
+func ttlResult(ttl time.Duration, err error) (time.Duration, error) {
+	if err != nil {
+		return 0, err
+	}
+	if ttl == -2 {
+		return 0, ErrNotFound
+	}
+	return ttl, nil
+}
+
+// Expire sets the ttl of the key. returns ErrNotFound if the key doesn't exist
+func (ctx *RedisKey[k, v]) Expire(ttl time.Duration) error {
+	return expireResult(ctx.rds().PExpire(ctx.Context, ctx.Key, ttl).Result())
+}
+
+// ExpireAt makes the key expire at tm. returns ErrNotFound if the key doesn't exist
+func (ctx *RedisKey[k, v]) ExpireAt(tm time.Time) error {
+	return expireResult(ctx.rds().PExpireAt(ctx.Context, ctx.Key, tm).Result())
+}
+
+// TTL returns the remaining time to live of the key, -1 if it doesn't expire. returns ErrNotFound if the key doesn't exist
+func (ctx *RedisKey[k, v]) TTL() (time.Duration, error) {
+	return ttlResult(ctx.reader().PTTL(ctx.Context, ctx.Key).Result())
+}
+
+// Persist removes the ttl of the key
+func (ctx *RedisKey[k, v]) Persist() error {
+	return ctx.rds().Persist(ctx.Context, ctx.Key).Err()
+}
+
+func (ctx *StringKey[k, v]) fullKey(key k) (string, error) {
+	keyStr, err := ctx.SerializeKey(key)
+	if err != nil {
+		return "", err
+	}
+	return ctx.Key + ":" + keyStr, nil
+}
+
+// Expire sets the ttl of key. returns ErrNotFound if key doesn't exist
+func (ctx *StringKey[k, v]) Expire(key k, ttl time.Duration) error {
+	fullKey, err := ctx.fullKey(key)
+	if err != nil {
+		return err
+	}
+	return expireResult(ctx.rds().PExpire(ctx.Context, fullKey, ttl).Result())
+}
+
+// ExpireAt makes key expire at tm. returns ErrNotFound if key doesn't exist
+func (ctx *StringKey[k, v]) ExpireAt(key k, tm time.Time) error {
+	fullKey, err := ctx.fullKey(key)
+	if err != nil {
+		return err
+	}
+	return expireResult(ctx.rds().PExpireAt(ctx.Context, fullKey, tm).Result())
+}
+
+// TTL returns the remaining time to live of key, -1 if it doesn't expire. returns ErrNotFound if key doesn't exist
+func (ctx *StringKey[k, v]) TTL(key k) (time.Duration, error) {
+	fullKey, err := ctx.fullKey(key)
+	if err != nil {
+		return 0, err
+	}
+	return ttlResult(ctx.reader().PTTL(ctx.Context, fullKey).Result())
+}
+
+// Persist removes the ttl of key
+func (ctx *StringKey[k, v]) Persist(key k) error {
+	fullKey, err := ctx.fullKey(key)
+	if err != nil {
+		return err
+	}
+	return ctx.rds().Persist(ctx.Context, fullKey).Err()
+}
@@ -0,0 +1,187 @@
+package redisdb_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/doptime/redisdb"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestKeyTTLMethods(t *testing.T) {
+	srv, rds := newServer(t)
+	users := redisdb.NewHashKey[string, *User](rds.Key("users"))
+	if err := users.Expire(time.Minute); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("Expire missing key = %v, want ErrNotFound", err)
+	}
+	if _, err := users.TTL(); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("TTL missing key = %v, want ErrNotFound", err)
+	}
+	users.HSet("u1", &User{ID: "u1"})
+	if ttl, err := users.TTL(); err != nil || ttl >= 0 {
+		t.Fatalf("TTL = %v, %v, want < 0 for no expiry", ttl, err)
+	}
+	if err := users.Expire(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, _ := users.TTL(); ttl != time.Minute {
+		t.Fatalf("TTL = %v", ttl)
+	}
+	if err := users.Persist(); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := srv.TTL("users"); ttl != 0 {
+		t.Fatalf("ttl after Persist = %v", ttl)
+	}
+	if err := users.ExpireAt(time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := srv.TTL("users"); ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Fatalf("ttl after ExpireAt = %v", ttl)
+	}
+
+	tokens := redisdb.NewStringKey[string, string](rds.Key("token"))
+	tokens.Set("t1", "secret", 0)
+	if err := tokens.Expire("t1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, err := tokens.TTL("t1"); err != nil || ttl != time.Minute {
+		t.Fatalf("TTL = %v, %v", ttl, err)
+	}
+	if err := tokens.Persist("t1"); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := srv.TTL("token:t1"); ttl != 0 {
+		t.Fatalf("ttl after Persist = %v", ttl)
+	}
+}
+
+func TestDefaultTTL(t *testing.T) {
+	srv, rds := newServer(t)
+	ttl := rds.TTL(time.Minute)
+	redisdb.NewHashKey[string, string](ttl.Key("h")).HSet("a", "1")
+	redisdb.NewListKey[string](ttl.Key("l")).RPush("a")
+	redisdb.NewSetKey[string, string](ttl.Key("s")).SAdd("a")
+	redisdb.NewZSetKey[string, string](ttl.Key("z")).ZAdd(redis.Z{Score: 1, Member: "a"})
+	redisdb.NewStreamKey[string, string](ttl.Key("x")).XAdd(&redis.XAddArgs{Values: map[string]interface{}{"a": "1"}})
+	strs := redisdb.NewStringKey[string, string](ttl.Key("str"))
+	strs.Set("a", "1", 0)
+	strs.Set("b", "1", time.Hour)
+	for _, key := range []string{"h", "l", "s", "z", "x", "str:a"} {
+		if got := srv.TTL(key); got != time.Minute {
+			t.Fatalf("ttl of %s = %v, want 1m", key, got)
+		}
+	}
+	if got := srv.TTL("str:b"); got != time.Hour {
+		t.Fatalf("ttl of an explicit expiration = %v, want 1h", got)
+	}
+
+	// writes in a transaction get it too
+	inTx := redisdb.NewHashKey[string, string](ttl.Key("tx"))
+	err := redisdb.Tx(srv.Name, func(tx *redisdb.TxCtx) error {
+		inTx.In(tx).HSet("a", "1")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.TTL("tx"); got != time.Minute {
+		t.Fatalf("ttl after Tx = %v, want 1m", got)
+	}
+}
+
+func TestSlidingTTL(t *testing.T) {
+	srv, rds := newServer(t)
+	sessions := redisdb.NewHashKey[string, string](rds.Key("session").SlidingTTL(time.Minute))
+	fixed := redisdb.NewHashKey[string, string](rds.Key("fixed").TTL(time.Minute))
+	sessions.HSet("a", "1")
+	fixed.HSet("a", "1")
+	srv.FastForward(40 * time.Second)
+	sessions.HGet("a")
+	fixed.HGet("a")
+	if got := srv.TTL("session"); got != time.Minute {
+		t.Fatalf("ttl after a read = %v, want it restarted", got)
+	}
+	if got := srv.TTL("fixed"); got != 20*time.Second {
+		t.Fatalf("ttl without sliding = %v, want 20s", got)
+	}
+}
+
+func TestSlidingTTLThrottled(t *testing.T) {
+	srv, rds := newServer(t)
+	sessions := redisdb.NewHashKey[string, string](rds.Key("session").SlidingTTL(time.Minute))
+	sessions.HSet("a", "1")
+	sessions.HGet("a")
+	srv.FastForward(40 * time.Second)
+	// touched a moment ago by this process: no PEXPIRE until a tenth of the ttl has passed
+	sessions.HGet("a")
+	if got := srv.TTL("session"); got != 20*time.Second {
+		t.Fatalf("ttl after a second read = %v, want 20s", got)
+	}
+}
+
+func TestTTLNotRestartedByRejectedWrites(t *testing.T) {
+	srv, rds := newServer(t)
+	ttl := rds.TTL(time.Minute)
+	hash := redisdb.NewHashKey[string, string](ttl.Key("h"))
+	hash.HSet("a", "1")
+	srv.FastForward(40 * time.Second)
+	if _, err := hash.HSet("a"); err == nil {
+		t.Fatal("HSet of a field without a value succeeded")
+	}
+	if got := srv.TTL("h"); got != 20*time.Second {
+		t.Fatalf("ttl after a rejected HSet = %v, want 20s", got)
+	}
+
+	strs := redisdb.NewStringKey[string, string](ttl.Key("str"))
+	strs.Set("a", "1", 0)
+	srv.FastForward(40 * time.Second)
+	if _, err := strs.Update("a", func(old string) (string, error) { return old + "2", nil }); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.TTL("str:a"); got != time.Minute {
+		t.Fatalf("ttl after Update = %v, want 1m", got)
+	}
+}
+
+func TestHttpTTLKey(t *testing.T) {
+	srv, rds := newServer(t)
+	redisdb.NewHashKey[string, string](rds.Key("httpttl")).HttpOn(redisdb.HashOp(redisdb.HashAll))
+	hkey, err := redisdb.GetHttpHashKey("httpttl", srv.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hkey.HSet("a", "1")
+	if !redisdb.IsAllowedCommon("httpttl", redisdb.Expire) {
+		t.Fatal("Expire not allowed by HashAll")
+	}
+	if err = hkey.Expire(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, err := hkey.TTL(); err != nil || ttl != time.Minute {
+		t.Fatalf("TTL = %v, %v", ttl, err)
+	}
+}
+
+func TestHttpTTLKeyPermission(t *testing.T) {
+	srv, rds := newServer(t)
+	redisdb.NewHashKey[string, string](rds.Key("httpro")).HttpOn(redisdb.HashOp(redisdb.HashRead))
+	srv.HSet("httpro", "a", "1")
+	hkey, err := redisdb.GetHttpHashKey("httpro", srv.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = hkey.Expire(time.Minute); !errors.Is(err, redisdb.ErrPermissionDenied) {
+		t.Fatalf("Expire without the Expire bit = %v, want ErrPermissionDenied", err)
+	}
+	if err = hkey.Persist(); !errors.Is(err, redisdb.ErrPermissionDenied) {
+		t.Fatalf("Persist without the Persist bit = %v, want ErrPermissionDenied", err)
+	}
+	if ttl, err := hkey.TTL(); err != nil || ttl != -1 {
+		t.Fatalf("TTL = %v, %v", ttl, err)
+	}
+	if srv.TTL("httpro") != 0 {
+		t.Fatal("denied Expire set a ttl")
+	}
+}
@@ -28,7 +28,7 @@ func (ctx *RedisKey[k, v]) NewValidator() func(in v) (err error) {
 		if !hasValidTag {
 			return nil
 		}
-		return validate.Struct(in)
+		return newValidationError(validate.Struct(in))
 	}
 }
 func (ctx *RedisKey[k, v]) Validate(in interface{}) (err error) {
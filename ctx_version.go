@@ -0,0 +1,142 @@
+package redisdb
+
+import (
+	"reflect"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// MaxUpdateRetries bounds the WATCH/MULTI/EXEC retries of HashKey.Update / StringKey.Update
+var MaxUpdateRetries = 16
+
+// a value is swapped only if the stored bytes are still the ones the version was read from.
+// comparing raw bytes keeps the script independent of codec / compression / encryption.
+// ARGV[1] = "1" if the value existed when read, ARGV[2] = the bytes read.
+// casHSetScript: ARGV[5] = ttl of the key, ARGV[6] = ttl of the field, in ms, 0 for none
+var casHSetScript = redis.NewScript(`
+local cur = redis.call('HGET', KEYS[1], ARGV[3])
+if ARGV[1] == '1' then
+	if cur ~= ARGV[2] then return 0 end
+elseif cur then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[3], ARGV[4])
+if tonumber(ARGV[5]) > 0 then redis.call('PEXPIRE', KEYS[1], ARGV[5]) end
+if tonumber(ARGV[6]) > 0 then redis.call('HPEXPIRE', KEYS[1], ARGV[6], 'FIELDS', 1, ARGV[3]) end
+return 1
+`)
+
+var casSetScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if ARGV[1] == '1' then
+	if cur ~= ARGV[2] then return 0 end
+elseif cur then
+	return 0
+end
+if tonumber(ARGV[4]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[3], 'PX', ARGV[4])
+else
+	redis.call('SET', KEYS[1], ARGV[3])
+end
+return 1
+`)
+
+// versionFieldIndex returns the index of the integer field tagged `version:""`, or -1
+func versionFieldIndex(vType reflect.Type) int {
+	for vType.Kind() == reflect.Ptr {
+		vType = vType.Elem()
+	}
+	if vType.Kind() != reflect.Struct {
+		return -1
+	}
+	for i := 0; i < vType.NumField(); i++ {
+		field := vType.Field(i)
+		if _, ok := field.Tag.Lookup("version"); !ok {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return i
+		}
+	}
+	return -1
+}
+
+// HasVersion reports whether v has a `version` tagged field
+func (ctx *RedisKey[k, v]) HasVersion() bool {
+	return ctx.VersionFieldIndex >= 0
+}
+
+func (ctx *RedisKey[k, v]) getVersion(value v) int64 {
+	rv := reflect.ValueOf(&value).Elem()
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return 0
+		}
+		rv = rv.Elem()
+	}
+	if ctx.VersionFieldIndex < 0 || rv.Kind() != reflect.Struct {
+		return 0
+	}
+	field := rv.Field(ctx.VersionFieldIndex)
+	if field.CanInt() {
+		return field.Int()
+	}
+	return int64(field.Uint())
+}
+
+func (ctx *RedisKey[k, v]) setVersion(value *v, version int64) {
+	rv := reflect.ValueOf(value).Elem()
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return
+		}
+		rv = rv.Elem()
+	}
+	if ctx.VersionFieldIndex < 0 || rv.Kind() != reflect.Struct {
+		return
+	}
+	field := rv.Field(ctx.VersionFieldIndex)
+	if field.CanInt() {
+		field.SetInt(version)
+	} else {
+		field.SetUint(uint64(version))
+	}
+}
+
+// withVersion returns a copy of value carrying version; for pointer v the struct is copied, value is left untouched
+func (ctx *RedisKey[k, v]) withVersion(value v, version int64) v {
+	if rv := reflect.ValueOf(&value).Elem(); rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		cp := reflect.New(rv.Type().Elem())
+		cp.Elem().Set(rv.Elem())
+		rv.Set(cp)
+	}
+	ctx.setVersion(&value, version)
+	return value
+}
+
+// checkVersion compares the version carried by value with the stored one and returns a copy with the version bumped.
+// raw is the stored bytes, nil if the value doesn't exist
+func (ctx *RedisKey[k, v]) checkVersion(field string, raw []byte, value v) (next v, err error) {
+	var current int64
+	if raw != nil {
+		old, err := ctx.decodeValue(field, raw)
+		if err != nil {
+			return value, err
+		}
+		current = ctx.getVersion(old)
+	}
+	if ctx.getVersion(value) != current {
+		return value, ErrVersionConflict
+	}
+	return ctx.withVersion(value, current+1), nil
+}
+
+// casArgs builds ARGV[1], ARGV[2] of the cas scripts
+func casArgs(raw []byte) (existed string, read string) {
+	if raw == nil {
+		return "0", ""
+	}
+	return "1", string(raw)
+}
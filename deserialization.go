@@ -42,11 +42,12 @@ func (ctx *RedisKey[k, v]) toKeys(valStr []string) (keys []k, err error) {
 	return keys, nil
 }
 
-// unmarhsal using msgpack
+// unmarhsal using ctx.Codec
 func (ctx *RedisKey[k, v]) toValuesFunc() func(valStrs []string) (value []v, err error) {
 	valueStruct := reflect.TypeOf((*v)(nil)).Elem()
 	var typeofv = valueStruct.Kind()
 	isElemPtr := valueStruct.Kind() == reflect.Ptr
+	codec := ctx.Codec
 
 	switch typeofv {
 	case reflect.Uint64:
@@ -216,7 +217,7 @@ func (ctx *RedisKey[k, v]) toValuesFunc() func(valStrs []string) (value []v, err
 			return values, err
 		}
 	default:
-		//continue with msgpack unmarshal
+		//continue with codec unmarshal
 		if isElemPtr {
 			return func(valStrs []string) (values []v, err error) {
 				values = make([]v, len(valStrs))
@@ -226,7 +227,7 @@ func (ctx *RedisKey[k, v]) toValuesFunc() func(valStrs []string) (value []v, err
 						continue
 					}
 					_val := reflect.New(valueStruct.Elem()).Interface().(v)
-					if err = msgpack.Unmarshal([]byte(val), _val); err != nil {
+					if err = codec.Unmarshal([]byte(val), _val); err != nil {
 						continue
 					}
 					values[i] = _val
@@ -241,7 +242,7 @@ func (ctx *RedisKey[k, v]) toValuesFunc() func(valStrs []string) (value []v, err
 						continue
 					}
 					var _val v
-					if err = msgpack.Unmarshal([]byte(val), &_val); err != nil {
+					if err = codec.Unmarshal([]byte(val), &_val); err != nil {
 						continue
 					}
 					values[i] = _val
@@ -254,6 +255,7 @@ func (ctx *RedisKey[k, v]) toValuesFunc() func(valStrs []string) (value []v, err
 
 func (ctx *RedisKey[k, v]) getDeserializetoValueFunc() func(valbytes []byte) (value v, err error) {
 	vTypeKind := reflect.TypeOf((*v)(nil)).Elem().Kind()
+	codec := ctx.Codec
 	switch vTypeKind {
 	case reflect.Int64:
 		return func(valbytes []byte) (value v, err error) {
@@ -330,14 +332,14 @@ func (ctx *RedisKey[k, v]) getDeserializetoValueFunc() func(valbytes []byte) (va
 		if isElemPtr {
 			return func(valbytes []byte) (value v, err error) {
 				value = reflect.New(valueStruct.Elem()).Interface().(v)
-				if err = msgpack.Unmarshal(valbytes, value); err == nil {
+				if err = codec.Unmarshal(valbytes, value); err == nil {
 					return value, nil
 				}
 				return interface{}(string(valbytes)).(v), nil
 			}
 		} else {
 			return func(valbytes []byte) (value v, err error) {
-				if err = msgpack.Unmarshal(valbytes, &value); err == nil {
+				if err = codec.Unmarshal(valbytes, &value); err == nil {
 					return value, nil
 				}
 				return interface{}(string(valbytes)).(v), nil
@@ -349,14 +351,14 @@ func (ctx *RedisKey[k, v]) getDeserializetoValueFunc() func(valbytes []byte) (va
 		if isElemPtr {
 			return func(valbytes []byte) (value v, err error) {
 				value = reflect.New(valueStruct.Elem()).Interface().(v)
-				if err = msgpack.Unmarshal(valbytes, value); err == nil {
+				if err = codec.Unmarshal(valbytes, value); err == nil {
 					return value, nil
 				}
 				return value, fmt.Errorf("fail convert redis data to value")
 			}
 		} else {
 			return func(valbytes []byte) (value v, err error) {
-				if err = msgpack.Unmarshal(valbytes, &value); err == nil {
+				if err = codec.Unmarshal(valbytes, &value); err == nil {
 					return value, nil
 				}
 				return value, fmt.Errorf("fail convert redis data to value")
@@ -0,0 +1,143 @@
+package redisdb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrNotFound is returned when the key / field / member doesn't exist.
+	// errors.Is(err, redis.Nil) still holds for it, so existing checks keep working
+	ErrNotFound error = notFoundError{}
+	// ErrSchemaNotRegistered is returned by the GetHttp*Key factories when no key was registered by HttpOn for the scope
+	ErrSchemaNotRegistered = errors.New("redisdb: key schema not registered")
+	// ErrPermissionDenied is returned by CheckHttpPermission when the op is not allowed by HttpOn
+	ErrPermissionDenied = errors.New("redisdb: permission denied")
+	// ErrNoPrimaryKey is returned by HashKey.Save when v has no field of type k
+	ErrNoPrimaryKey = errors.New("redisdb: no field of type k found in value")
+	// ErrVersionConflict is returned when the version sent with a value is not the stored one,
+	// or when Update keeps losing the race after MaxUpdateRetries
+	ErrVersionConflict = errors.New("redisdb: version conflict")
+	// ErrResultPending is held by a transaction Result until the transaction is executed
+	ErrResultPending = errors.New("redisdb: result read before the transaction was executed")
+	// ErrDataSourceUnavailable is returned by commands on a key whose data source is neither registered nor in config.toml
+	ErrDataSourceUnavailable = errors.New("redisdb: data source unavailable, register it with RegisterDataSource or declare it in config.toml")
+	// ErrFieldTTLUnsupported is returned by the hash field ttl commands (HExpire, HSetEx...) on a server older than redis 7.4
+	ErrFieldTTLUnsupported = errors.New("redisdb: hash field ttl needs redis 7.4+")
+	// ErrKeyTypeMismatch is returned by RenameKey when the key isn't of the type of the HTTP key
+	ErrKeyTypeMismatch = errors.New("redisdb: key type mismatch")
+	// ErrKeyExists is returned by RenameKey instead of overwriting an existing key
+	ErrKeyExists = errors.New("redisdb: key exists")
+	// ErrCrossSlot is returned by RenameKey on a cluster when the two keys are in different hash slots
+	ErrCrossSlot = errors.New("redisdb: keys in different hash slots")
+	// ErrUnknownField is returned by DocumentKey.Patch for a name that isn't a field of the document
+	ErrUnknownField = errors.New("redisdb: unknown document field")
+	// ErrEncryptedMember is returned by SetKey / ListKey / ZSetKey created with a V that has `encrypt` tagged fields:
+	// their members are matched by their encoding, which a random nonce would make differ on every write
+	ErrEncryptedMember = errors.New("redisdb: encrypt tags are not supported on set, list and zset values")
+	// ErrInvalidEncryptTag is the construction error of a key whose V has an `encrypt` tag that can't be honoured:
+	// an unknown algorithm, or a field that isn't a string or []byte
+	ErrInvalidEncryptTag = errors.New("redisdb: invalid encrypt tag")
+)
+
+type notFoundError struct{}
+
+func (notFoundError) Error() string        { return "redisdb: not found" }
+func (notFoundError) Is(target error) bool { return target == redis.Nil }
+
+// asNotFound maps redis.Nil to ErrNotFound, other errors are returned as is
+func asNotFound(err error) error {
+	if err == redis.Nil {
+		return ErrNotFound
+	}
+	return err
+}
+
+// DecodeError is returned when a value read from redis can't be decoded into v
+type DecodeError struct {
+	// Key is the redis key, Field the hash field / string key suffix if any
+	Key   string
+	Field string
+	Raw   []byte
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("redisdb: decode %s %s: %v", e.Key, e.Field, e.Err)
+	}
+	return fmt.Sprintf("redisdb: decode %s: %v", e.Key, e.Err)
+}
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// decodeValue is DeserializeToValue with the error wrapped in *DecodeError
+func (ctx *RedisKey[k, v]) decodeValue(field string, data []byte) (value v, err error) {
+	if value, err = ctx.DeserializeToValue(data); err != nil {
+		return value, &DecodeError{Key: ctx.Key, Field: field, Raw: data, Err: err}
+	}
+	return value, nil
+}
+
+// FieldError describes a single failed `validate` rule
+type FieldError struct {
+	// Field is the struct field name, Namespace the full path such as User.Address.City
+	Field     string
+	Namespace string
+	Tag       string
+	Param     string
+	Value     interface{}
+}
+
+// ValidationError is returned when a value fails its `validate` tags
+type ValidationError struct {
+	Fields []FieldError
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Fields) == 0 {
+		return "redisdb: validation failed: " + e.Err.Error()
+	}
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msg := f.Namespace + ": " + f.Tag
+		if f.Param != "" {
+			msg += "=" + f.Param
+		}
+		msgs = append(msgs, msg)
+	}
+	return "redisdb: validation failed: " + strings.Join(msgs, "; ")
+}
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+func newValidationError(err error) error {
+	if err == nil {
+		return nil
+	}
+	ve := &ValidationError{Err: err}
+	var fieldErrs validator.ValidationErrors
+	if errors.As(err, &fieldErrs) {
+		for _, fe := range fieldErrs {
+			ve.Fields = append(ve.Fields, FieldError{
+				Field:     fe.Field(),
+				Namespace: fe.Namespace(),
+				Tag:       fe.Tag(),
+				Param:     fe.Param(),
+				Value:     fe.Value(),
+			})
+		}
+	}
+	return ve
+}
+
+// CheckHttpPermission returns an error wrapping ErrPermissionDenied if op is not allowed on key
+func CheckHttpPermission(key string, op uint64) error {
+	if !isHttpOpAllowed(key, op) {
+		return fmt.Errorf("%w: op 0x%X on %s", ErrPermissionDenied, op, key)
+	}
+	return nil
+}
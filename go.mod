@@ -5,13 +5,18 @@ go 1.23.0
 toolchain go1.24.1
 
 require (
+	github.com/alicebob/miniredis/v2 v2.37.0
 	github.com/doptime/config v0.0.0-20260612022958-8080233fc46d
 	github.com/doptime/logger v0.0.0-20241013090925-4b12ee9d0b17
+	github.com/fxamacker/cbor/v2 v2.9.0
 	github.com/go-playground/validator/v10 v10.26.0
+	github.com/klauspost/compress v1.18.0
 	github.com/orcaman/concurrent-map/v2 v2.0.1
 	github.com/redis/go-redis/v9 v9.8.0
 	github.com/vmihailenco/msgpack/v5 v5.4.1
+	golang.org/x/sync v0.14.0
 	golang.org/x/text v0.25.0
+	google.golang.org/protobuf v1.36.9
 )
 
 require (
@@ -29,8 +34,9 @@ require (
 	github.com/rs/zerolog v1.34.0 // indirect
 	github.com/stretchr/testify v1.9.0 // indirect
 	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
 	golang.org/x/crypto v0.38.0 // indirect
 	golang.org/x/net v0.40.0 // indirect
-	golang.org/x/sync v0.14.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
 )
@@ -0,0 +1,23 @@
+package redisdb_test
+
+import (
+	"testing"
+
+	"github.com/doptime/redisdb"
+	"github.com/doptime/redisdb/redistest"
+)
+
+type User struct {
+	ID   string `msgpack:"id"`
+	Name string `msgpack:"name" mod:"trim,force"`
+	Age  int    `msgpack:"age"`
+}
+
+// trimmed turns on the `mod` tags: they take effect on keys created with at least one modifier (WithModifier)
+var trimmed = map[string]redisdb.ModifierFunc{"trim": redisdb.TrimSpaces}
+
+// newServer starts an in-memory server registered under the test name, and returns the option selecting it
+func newServer(t *testing.T) (*redistest.Server, redisdb.Option) {
+	srv := redistest.Run(t, t.Name())
+	return srv, redisdb.WithRds(srv.Name)
+}
@@ -0,0 +1,172 @@
+package redisdb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// IHttpTTLKey is the ttl part of IHttpCommonKey
+type IHttpTTLKey interface {
+	Expire(ttl time.Duration) error
+	ExpireAt(tm time.Time) error
+	TTL() (time.Duration, error)
+	Persist() error
+}
+
+// IHttpCommonKey is implemented by the HTTP keys of whole-key types (hash, list, set, zset, stream).
+// the operations act on the concrete key the HTTP key was created for, and return ErrPermissionDenied
+// unless HttpOn allowed the matching Del / Exists / Type / Rename / Expire / Persist / TTL bit.
+// IHttpStringKey has the same operations taking the string key's field.
+// IHttpVectorSetKey doesn't implement it: a vector set key names a RediSearch index, not a redis key
+type IHttpCommonKey interface {
+	IHttpTTLKey
+	DelKey() error
+	ExistsKey() (bool, error)
+	TypeOfKey() (string, error)
+	// RenameKey renames the key to newKey, which needs the Rename bit too. it fails with ErrKeyTypeMismatch
+	// if the key isn't of the HTTP key's type, and with ErrKeyExists rather than overwrite newKey.
+	// on a cluster both keys must share a hash slot (a hash tag, e.g. "{cart}:1" to "{cart}:2"), else it fails with ErrCrossSlot
+	RenameKey(newKey string) error
+}
+
+// renameScript renames KEYS[1] to KEYS[2] if KEYS[1] is of type ARGV[1] and KEYS[2] doesn't exist.
+// returns 1 if renamed, 0 if KEYS[2] exists, the type of KEYS[1] otherwise ('none' if it doesn't exist)
+var renameScript = redis.NewScript(`
+local t = redis.call('TYPE', KEYS[1]).ok
+if t ~= ARGV[1] then return t end
+return redis.call('RENAMENX', KEYS[1], KEYS[2])
+`)
+
+func (ctx *RedisKey[k, v]) httpDel(key string) error {
+	if err := CheckHttpPermission(key, Del); err != nil {
+		return err
+	}
+	defer ctx.uncache(key)
+	return ctx.rds().Del(ctx.Context, key).Err()
+}
+
+func (ctx *RedisKey[k, v]) httpExists(key string) (bool, error) {
+	if err := CheckHttpPermission(key, Exists); err != nil {
+		return false, err
+	}
+	n, err := ctx.reader().Exists(ctx.Context, key).Result()
+	return n > 0, err
+}
+
+// httpType returns the redis type of key, "none" if it doesn't exist
+func (ctx *RedisKey[k, v]) httpType(key string) (string, error) {
+	if err := CheckHttpPermission(key, Type); err != nil {
+		return "", err
+	}
+	return ctx.reader().Type(ctx.Context, key).Result()
+}
+
+func (ctx *RedisKey[k, v]) httpRename(key, newKey string) error {
+	if err := CheckHttpPermission(key, Rename); err != nil {
+		return err
+	}
+	if err := CheckHttpPermission(newKey, Rename); err != nil {
+		return err
+	}
+	// the script takes both keys, which a cluster only accepts in one slot
+	if _, cluster := ctx.rds().(*redis.ClusterClient); cluster && keySlot(key) != keySlot(newKey) {
+		return fmt.Errorf("%w: %s and %s, use a hash tag", ErrCrossSlot, key, newKey)
+	}
+	defer ctx.uncache(key, newKey)
+	reply, err := renameScript.Run(ctx.Context, ctx.rds(), []string{key, newKey}, string(ctx.KeyType)).Result()
+	if err != nil {
+		return err
+	}
+	switch reply := reply.(type) {
+	case int64:
+		if reply == 0 {
+			return fmt.Errorf("%w: %s", ErrKeyExists, newKey)
+		}
+		return nil
+	case string:
+		if reply == "none" {
+			return ErrNotFound
+		}
+		return fmt.Errorf("%w: %s is a %s, not a %s", ErrKeyTypeMismatch, key, reply, ctx.KeyType)
+	}
+	return fmt.Errorf("redisdb: rename %s: unexpected reply %v", key, reply)
+}
+
+func (ctx *RedisKey[k, v]) httpExpire(key string, ttl time.Duration) error {
+	if err := CheckHttpPermission(key, Expire); err != nil {
+		return err
+	}
+	return expireResult(ctx.rds().PExpire(ctx.Context, key, ttl).Result())
+}
+
+func (ctx *RedisKey[k, v]) httpExpireAt(key string, tm time.Time) error {
+	if err := CheckHttpPermission(key, Expire); err != nil {
+		return err
+	}
+	return expireResult(ctx.rds().PExpireAt(ctx.Context, key, tm).Result())
+}
+
+func (ctx *RedisKey[k, v]) httpTTL(key string) (time.Duration, error) {
+	if err := CheckHttpPermission(key, TTL); err != nil {
+		return 0, err
+	}
+	return ttlResult(ctx.reader().PTTL(ctx.Context, key).Result())
+}
+
+func (ctx *RedisKey[k, v]) httpPersist(key string) error {
+	if err := CheckHttpPermission(key, Persist); err != nil {
+		return err
+	}
+	return ctx.rds().Persist(ctx.Context, key).Err()
+}
+
+// --- IHttpCommonKey of the whole-key HTTP keys ---
+
+func (ctx *HttpHashKey[k, v]) DelKey() error                  { return ctx.httpDel(ctx.Key) }
+func (ctx *HttpHashKey[k, v]) ExistsKey() (bool, error)       { return ctx.httpExists(ctx.Key) }
+func (ctx *HttpHashKey[k, v]) TypeOfKey() (string, error)     { return ctx.httpType(ctx.Key) }
+func (ctx *HttpHashKey[k, v]) RenameKey(newKey string) error  { return ctx.httpRename(ctx.Key, newKey) }
+func (ctx *HttpHashKey[k, v]) Expire(ttl time.Duration) error { return ctx.httpExpire(ctx.Key, ttl) }
+func (ctx *HttpHashKey[k, v]) ExpireAt(tm time.Time) error    { return ctx.httpExpireAt(ctx.Key, tm) }
+func (ctx *HttpHashKey[k, v]) TTL() (time.Duration, error)    { return ctx.httpTTL(ctx.Key) }
+func (ctx *HttpHashKey[k, v]) Persist() error                 { return ctx.httpPersist(ctx.Key) }
+
+func (ctx *HttpListKey[v]) DelKey() error                  { return ctx.httpDel(ctx.Key) }
+func (ctx *HttpListKey[v]) ExistsKey() (bool, error)       { return ctx.httpExists(ctx.Key) }
+func (ctx *HttpListKey[v]) TypeOfKey() (string, error)     { return ctx.httpType(ctx.Key) }
+func (ctx *HttpListKey[v]) RenameKey(newKey string) error  { return ctx.httpRename(ctx.Key, newKey) }
+func (ctx *HttpListKey[v]) Expire(ttl time.Duration) error { return ctx.httpExpire(ctx.Key, ttl) }
+func (ctx *HttpListKey[v]) ExpireAt(tm time.Time) error    { return ctx.httpExpireAt(ctx.Key, tm) }
+func (ctx *HttpListKey[v]) TTL() (time.Duration, error)    { return ctx.httpTTL(ctx.Key) }
+func (ctx *HttpListKey[v]) Persist() error                 { return ctx.httpPersist(ctx.Key) }
+
+func (ctx *HttpSetKey[k, v]) DelKey() error                  { return ctx.httpDel(ctx.Key) }
+func (ctx *HttpSetKey[k, v]) ExistsKey() (bool, error)       { return ctx.httpExists(ctx.Key) }
+func (ctx *HttpSetKey[k, v]) TypeOfKey() (string, error)     { return ctx.httpType(ctx.Key) }
+func (ctx *HttpSetKey[k, v]) RenameKey(newKey string) error  { return ctx.httpRename(ctx.Key, newKey) }
+func (ctx *HttpSetKey[k, v]) Expire(ttl time.Duration) error { return ctx.httpExpire(ctx.Key, ttl) }
+func (ctx *HttpSetKey[k, v]) ExpireAt(tm time.Time) error    { return ctx.httpExpireAt(ctx.Key, tm) }
+func (ctx *HttpSetKey[k, v]) TTL() (time.Duration, error)    { return ctx.httpTTL(ctx.Key) }
+func (ctx *HttpSetKey[k, v]) Persist() error                 { return ctx.httpPersist(ctx.Key) }
+
+func (ctx *HttpZSetKey[k, v]) DelKey() error                  { return ctx.httpDel(ctx.Key) }
+func (ctx *HttpZSetKey[k, v]) ExistsKey() (bool, error)       { return ctx.httpExists(ctx.Key) }
+func (ctx *HttpZSetKey[k, v]) TypeOfKey() (string, error)     { return ctx.httpType(ctx.Key) }
+func (ctx *HttpZSetKey[k, v]) RenameKey(newKey string) error  { return ctx.httpRename(ctx.Key, newKey) }
+func (ctx *HttpZSetKey[k, v]) Expire(ttl time.Duration) error { return ctx.httpExpire(ctx.Key, ttl) }
+func (ctx *HttpZSetKey[k, v]) ExpireAt(tm time.Time) error    { return ctx.httpExpireAt(ctx.Key, tm) }
+func (ctx *HttpZSetKey[k, v]) TTL() (time.Duration, error)    { return ctx.httpTTL(ctx.Key) }
+func (ctx *HttpZSetKey[k, v]) Persist() error                 { return ctx.httpPersist(ctx.Key) }
+
+func (ctx *HttpStreamKey[k, v]) DelKey() error              { return ctx.httpDel(ctx.Key) }
+func (ctx *HttpStreamKey[k, v]) ExistsKey() (bool, error)   { return ctx.httpExists(ctx.Key) }
+func (ctx *HttpStreamKey[k, v]) TypeOfKey() (string, error) { return ctx.httpType(ctx.Key) }
+func (ctx *HttpStreamKey[k, v]) RenameKey(newKey string) error {
+	return ctx.httpRename(ctx.Key, newKey)
+}
+func (ctx *HttpStreamKey[k, v]) Expire(ttl time.Duration) error { return ctx.httpExpire(ctx.Key, ttl) }
+func (ctx *HttpStreamKey[k, v]) ExpireAt(tm time.Time) error    { return ctx.httpExpireAt(ctx.Key, tm) }
+func (ctx *HttpStreamKey[k, v]) TTL() (time.Duration, error)    { return ctx.httpTTL(ctx.Key) }
+func (ctx *HttpStreamKey[k, v]) Persist() error                 { return ctx.httpPersist(ctx.Key) }
@@ -0,0 +1,153 @@
+package redisdb_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/doptime/redisdb"
+)
+
+func TestHttpCommonKey(t *testing.T) {
+	srv, rds := newServer(t)
+	redisdb.NewHashKey[string, string](rds.Key("cart")).HttpOn(redisdb.HashOp(redisdb.HashAll))
+	carts := redisdb.NewHashKey[string, string](rds.Key("cart:1"))
+	carts.HSet("apple", "2")
+
+	cart, err := redisdb.GetHttpHashKey("cart:1", srv.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := cart.ExistsKey(); err != nil || !ok {
+		t.Fatalf("ExistsKey = %v, %v", ok, err)
+	}
+	if typ, err := cart.TypeOfKey(); err != nil || typ != "hash" {
+		t.Fatalf("TypeOfKey = %q, %v", typ, err)
+	}
+	if err = cart.Expire(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, err := cart.TTL(); err != nil || ttl != time.Minute {
+		t.Fatalf("TTL = %v, %v", ttl, err)
+	}
+	if err = cart.Persist(); err != nil {
+		t.Fatal(err)
+	}
+
+	// rename never overwrites, and only moves keys of the HTTP key's type
+	srv.HSet("cart:2", "pear", "1")
+	if err = cart.RenameKey("cart:2"); !errors.Is(err, redisdb.ErrKeyExists) {
+		t.Fatalf("RenameKey onto an existing key = %v, want ErrKeyExists", err)
+	}
+	if err = cart.RenameKey("cart:3"); err != nil {
+		t.Fatal(err)
+	}
+	if srv.Exists("cart:1") || srv.HGet("cart:3", "apple") == "" {
+		t.Fatalf("keys after RenameKey: %v", srv.Keys())
+	}
+	if err = cart.RenameKey("cart:4"); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("RenameKey of a missing key = %v, want ErrNotFound", err)
+	}
+	srv.Set("cart:5", "not a hash")
+	odd, _ := redisdb.GetHttpHashKey("cart:5", srv.Name)
+	if err = odd.RenameKey("cart:6"); !errors.Is(err, redisdb.ErrKeyTypeMismatch) {
+		t.Fatalf("RenameKey of a string = %v, want ErrKeyTypeMismatch", err)
+	}
+	if err = odd.DelKey(); err != nil {
+		t.Fatal(err)
+	}
+	if srv.Exists("cart:5") {
+		t.Fatal("DelKey left the key")
+	}
+}
+
+func TestHttpCommonKeyPermissions(t *testing.T) {
+	srv, rds := newServer(t)
+	redisdb.NewHashKey[string, string](rds.Key("ro")).HttpOn(redisdb.HGet | redisdb.HSet)
+	redisdb.NewHashKey[string, string](rds.Key("ro:1")).HSet("a", "1")
+	ro, err := redisdb.GetHttpHashKey("ro:1", srv.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ro.DelKey(); !errors.Is(err, redisdb.ErrPermissionDenied) {
+		t.Fatalf("DelKey = %v, want ErrPermissionDenied", err)
+	}
+	if _, err = ro.TTL(); !errors.Is(err, redisdb.ErrPermissionDenied) {
+		t.Fatalf("TTL = %v, want ErrPermissionDenied", err)
+	}
+	if !srv.Exists("ro:1") {
+		t.Fatal("key deleted without permission")
+	}
+
+	// renaming needs the Rename bit on the destination too
+	redisdb.NewHashKey[string, string](rds.Key("rw")).HttpOn(redisdb.HashOp(redisdb.HashAll))
+	redisdb.NewHashKey[string, string](rds.Key("rw:1")).HSet("a", "1")
+	rw, _ := redisdb.GetHttpHashKey("rw:1", srv.Name)
+	if err = rw.RenameKey("ro:2"); !errors.Is(err, redisdb.ErrPermissionDenied) {
+		t.Fatalf("RenameKey to a read only scope = %v, want ErrPermissionDenied", err)
+	}
+}
+
+func TestHttpStringKeyCommon(t *testing.T) {
+	srv, rds := newServer(t)
+	redisdb.NewStringKey[string, string](rds.Key("otp")).HttpOn(redisdb.StringOp(redisdb.StringAll))
+	redisdb.NewStringKey[string, string](rds.Key("otp")).Set("u1", "1234", 0)
+	otp, err := redisdb.GetHttpStringKey("otp", srv.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ, err := otp.TypeOfKey("u1"); err != nil || typ != "string" {
+		t.Fatalf("TypeOfKey = %q, %v", typ, err)
+	}
+	if err = otp.Expire("u1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err = otp.RenameKey("u1", "u2"); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := srv.TTL("otp:u2"); ttl != time.Minute {
+		t.Fatalf("ttl after RenameKey = %v, want it kept", ttl)
+	}
+	if err = otp.DelKey("u2"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := otp.ExistsKey("u2"); ok {
+		t.Fatal("ExistsKey after DelKey")
+	}
+}
+
+func TestHttpHashKeyVersion(t *testing.T) {
+	srv, rds := newServer(t)
+	type Doc struct {
+		Body string `msgpack:"body"`
+		Ver  int64  `msgpack:"ver" version:""`
+	}
+	redisdb.NewHashKey[string, *Doc](rds.Key("vdocs")).HttpOn(redisdb.HashOp(redisdb.HashAll))
+	docs, err := redisdb.GetHttpHashKey("vdocs", srv.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := docs.HSet("d1", &Doc{Body: "a"}); err != nil || n != 1 {
+		t.Fatalf("HSet new = %d, %v", n, err)
+	}
+	if n, err := docs.HSet("d1", &Doc{Body: "b", Ver: 1}); err != nil || n != 0 {
+		t.Fatalf("HSet existing = %d, %v", n, err)
+	}
+	// HSetEx goes through the same version check
+	if err = docs.HSetEx("d1", &Doc{Body: "c", Ver: 1}, time.Minute); !errors.Is(err, redisdb.ErrVersionConflict) {
+		t.Fatalf("stale HSetEx = %v, want ErrVersionConflict", err)
+	}
+}
+
+func TestHttpPayloadCodec(t *testing.T) {
+	_, rds := newServer(t)
+	users := redisdb.NewHashKey[string, *User](rds.Key("jusers").Codec(redisdb.JSONCodec))
+	u, err := users.DeserializeToInterface([]byte(`{"ID":"u1","Name":"alice"}`))
+	if user, ok := u.(*User); err != nil || !ok || user.Name != "alice" {
+		t.Fatalf("DeserializeToInterface = %#v, %v", u, err)
+	}
+	list, err := users.DeserializeToInterfaceSlice([]string{`{"ID":"u2","Name":"bob"}`})
+	if err != nil || len(list) != 1 || list[0].(*User).Name != "bob" {
+		t.Fatalf("DeserializeToInterfaceSlice = %#v, %v", list, err)
+	}
+}
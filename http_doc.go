@@ -21,7 +21,9 @@ type WebDataSchema struct {
 	CreateFromLocal bool `msgpack:"-"`
 }
 
-var KeyWebDataSchema = NewHashKey[string, *WebDataSchema](Opt.Key("Docs:WebDataSchema"))
+// KeyWebDataSchema is created in init(): package level maps it depends on (e.g. RdsSources) must be initialized first.
+// it is never nil; until the default data source is available its commands fail and the sync just retries later
+var KeyWebDataSchema *HashKey[string, *WebDataSchema]
 
 var WebDataSchemaMap = cmap.New[*WebDataSchema]()
 
@@ -67,6 +69,7 @@ func (ctx *RedisKey[k, v]) RegisterWebDataSchemaDocForWebVisit() {
 }
 
 func init() {
+	KeyWebDataSchema = NewHashKey[string, *WebDataSchema](Opt.Key("Docs:WebDataSchema"))
 	go syncWebDataToRedis()
 }
 
@@ -1,7 +1,9 @@
 package redisdb
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/doptime/redisdb/utils"
 	cmap "github.com/orcaman/concurrent-map/v2"
@@ -13,8 +15,10 @@ type IHttpHashKey interface {
 	ValidDataKey() error
 	GetValue() interface{}
 	TimestampFiller(in interface{}) (err error)
+	// Del / Exists / Type / Rename / Expire / TTL / Persist of the key, checked against IsAllowedCommon
+	IHttpCommonKey
 
-	WithContext(key string, RedisDataSource string) IHttpHashKey
+	WithContext(c context.Context, key string, RedisDataSource string) IHttpHashKey
 
 	HScanNoValues(cursor uint64, match string, count int64) (keys []string, cursorRet uint64, err error)
 	HScan(cursor uint64, match string, count int64) (keys []string, values []interface{}, cursorRet uint64, err error)
@@ -27,6 +31,15 @@ type IHttpHashKey interface {
 	HExists(field string) (exists bool, err error)
 	HRandField(count int) (keys []string, err error)
 	HRandFieldWithValues(count int) (keyvalueMap map[string]interface{}, err error)
+
+	// 字段级 TTL,behind the HSetEx / HExpire / HPExpire / HTTL / HPersist / HGetEx / HGetDel bits
+	HSetEx(field string, val interface{}, ttl time.Duration) error
+	HExpire(ttl time.Duration, fields ...string) ([]int64, error)
+	HPExpire(ttl time.Duration, fields ...string) ([]int64, error)
+	HTTL(fields ...string) ([]time.Duration, error)
+	HPersist(fields ...string) ([]int64, error)
+	HGetEx(field string, ttl time.Duration) (interface{}, error)
+	HGetDel(field string) (interface{}, error)
 }
 
 var HttpHashKeyMap cmap.ConcurrentMap[string, IHttpHashKey] = cmap.New[IHttpHashKey]()
@@ -54,9 +67,10 @@ func (ctx *HttpHashKey[k, v]) native() *HashKey[k, v] {
 }
 
 // WithContext 实现：克隆自己，修改 Key 和 DS，返回接口
-func (ctx *HttpHashKey[k, v]) WithContext(key string, RedisDataSource string) IHttpHashKey {
+func (ctx *HttpHashKey[k, v]) WithContext(c context.Context, key string, RedisDataSource string) IHttpHashKey {
 	// 1. 获取原始对象的副本 (浅拷贝结构体)
 	newObj := ctx.native().Duplicate(key, RedisDataSource)
+	newObj = newObj.withCtx(c)
 	newCtx := HttpHashKey[k, v]{RedisKey: newObj}
 	return &newCtx
 }
@@ -122,6 +136,11 @@ func (ctx *HttpHashKey[k, v]) HSet(field string, val interface{}) (int64, error)
 	if err != nil {
 		return 0, err
 	}
+	// values with a version field must carry the version the client read
+	if _v, ok := val.(v); ok && hkey.HasVersion() {
+		_, added, err := hkey.hsetWithVersion(key, _v, 0)
+		return added, err
+	}
 	return hkey.HSet(key, val)
 }
 
@@ -204,11 +223,95 @@ func (ctx *HttpHashKey[k, v]) HRandFieldWithValues(count int) (keyvalueMap map[s
 	return keyvalueMap, nil
 }
 
+func (ctx *HttpHashKey[k, v]) toKeys(fields []string) (keys []k, err error) {
+	hkey := (*HashKey[k, v])(ctx)
+	keys = make([]k, len(fields))
+	for i, field := range fields {
+		if keys[i], err = hkey.toKey([]byte(field)); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+func (ctx *HttpHashKey[k, v]) HSetEx(field string, val interface{}, ttl time.Duration) error {
+	hkey := (*HashKey[k, v])(ctx)
+	key, err := hkey.toKey([]byte(field))
+	if err != nil {
+		return err
+	}
+	_v, ok := val.(v)
+	if !ok {
+		return fmt.Errorf("value type assertion failed: expected %T, got %T", *new(v), val)
+	}
+	// values with a version field must carry the version the client read, as in HSet
+	if hkey.HasVersion() {
+		_, _, err = hkey.hsetWithVersion(key, _v, ttl)
+		return err
+	}
+	return hkey.HSetEx(key, _v, ttl)
+}
+
+func (ctx *HttpHashKey[k, v]) HExpire(ttl time.Duration, fields ...string) ([]int64, error) {
+	keys, err := ctx.toKeys(fields)
+	if err != nil {
+		return nil, err
+	}
+	return (*HashKey[k, v])(ctx).HExpire(ttl, keys...)
+}
+
+func (ctx *HttpHashKey[k, v]) HPExpire(ttl time.Duration, fields ...string) ([]int64, error) {
+	keys, err := ctx.toKeys(fields)
+	if err != nil {
+		return nil, err
+	}
+	return (*HashKey[k, v])(ctx).HPExpire(ttl, keys...)
+}
+
+func (ctx *HttpHashKey[k, v]) HTTL(fields ...string) ([]time.Duration, error) {
+	keys, err := ctx.toKeys(fields)
+	if err != nil {
+		return nil, err
+	}
+	return (*HashKey[k, v])(ctx).HTTL(keys...)
+}
+
+func (ctx *HttpHashKey[k, v]) HPersist(fields ...string) ([]int64, error) {
+	keys, err := ctx.toKeys(fields)
+	if err != nil {
+		return nil, err
+	}
+	return (*HashKey[k, v])(ctx).HPersist(keys...)
+}
+
+func (ctx *HttpHashKey[k, v]) HGetEx(field string, ttl time.Duration) (interface{}, error) {
+	hkey := (*HashKey[k, v])(ctx)
+	key, err := hkey.toKey([]byte(field))
+	if err != nil {
+		return nil, err
+	}
+	return hkey.HGetEx(key, ttl)
+}
+
+func (ctx *HttpHashKey[k, v]) HGetDel(field string) (interface{}, error) {
+	hkey := (*HashKey[k, v])(ctx)
+	key, err := hkey.toKey([]byte(field))
+	if err != nil {
+		return nil, err
+	}
+	return hkey.HGetDel(key)
+}
+
 func GetHttpHashKey(Key string, rdsName string) (IHttpHashKey, error) {
+	return GetHttpHashKeyWithCtx(context.Background(), Key, rdsName)
+}
+
+// GetHttpHashKeyWithCtx 同 GetHttpHashKey,返回的 key 上的命令都在 c 下执行(取消、超时、tracing)
+func GetHttpHashKeyWithCtx(c context.Context, Key string, rdsName string) (IHttpHashKey, error) {
 	_keyscope := KeyScope(Key)
 	ikey, ok := HttpHashKeyMap.Get(_keyscope + ":" + rdsName)
 	if !ok {
-		return nil, fmt.Errorf("key schema not found")
+		return nil, fmt.Errorf("%w: %s", ErrSchemaNotRegistered, _keyscope)
 	}
-	return ikey.WithContext(Key, rdsName), nil
+	return ikey.WithContext(c, Key, rdsName), nil
 }
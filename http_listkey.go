@@ -1,6 +1,7 @@
 package redisdb
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/doptime/redisdb/utils"
@@ -15,9 +16,11 @@ type IHttpListKey interface {
 	GetValue() interface{}
 	ValidDataKey() error
 	TimestampFiller(in interface{}) (err error)
+	// Del / Exists / Type / Rename / Expire / TTL / Persist of the key, checked against IsAllowedCommon
+	IHttpCommonKey
 
 	// --- 上下文注入 (核心) ---
-	WithContext(key string, ds string) IHttpListKey
+	WithContext(c context.Context, key string, ds string) IHttpListKey
 
 	// --- 数据操作 ---
 	// 读操作：返回 interface{} (底层是 v 或 []v)
@@ -68,10 +71,11 @@ func (ctx *HttpListKey[v]) TimestampFiller(in interface{}) (err error) {
 }
 
 // WithContext 实现：克隆并注入上下文
-func (ctx *HttpListKey[v]) WithContext(key string, ds string) IHttpListKey {
+func (ctx *HttpListKey[v]) WithContext(c context.Context, key string, ds string) IHttpListKey {
 	// 1. 获取底层 RedisKey 的副本 (ListKey Embed 了 RedisKey[string, v])
 	// 注意 ListKey 的 Key 类型固定为 string
 	newObj := ctx.native().Duplicate(key, ds)
+	newObj = newObj.withCtx(c)
 
 	// 2. 重新包装
 	newList := ListKey[v]{RedisKey: newObj}
@@ -175,11 +179,16 @@ func (ctx *HttpListKey[v]) LPushX(val interface{}) (err error) {
 
 // 工厂方法
 func GetHttpListKey(Key string, rdsName string) (IHttpListKey, error) {
+	return GetHttpListKeyWithCtx(context.Background(), Key, rdsName)
+}
+
+// GetHttpListKeyWithCtx 同 GetHttpListKey,返回的 key 上的命令都在 c 下执行(取消、超时、tracing)
+func GetHttpListKeyWithCtx(c context.Context, Key string, rdsName string) (IHttpListKey, error) {
 	_keyscope := KeyScope(Key)
 	ikey, ok := HttpListKeyMap.Get(_keyscope + ":" + rdsName)
 	if !ok {
-		return nil, fmt.Errorf("key schema not found for: %s", _keyscope)
+		return nil, fmt.Errorf("%w: %s", ErrSchemaNotRegistered, _keyscope)
 	}
 	// 核心：调用 WithContext
-	return ikey.WithContext(Key, rdsName), nil
+	return ikey.WithContext(c, Key, rdsName), nil
 }
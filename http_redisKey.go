@@ -2,8 +2,6 @@ package redisdb
 
 import (
 	"fmt"
-
-	"github.com/doptime/config/cfgredis"
 )
 
 type CtxInterface interface {
@@ -20,8 +18,8 @@ func (ctx *RedisKey[k, v]) ValidDataKey() error {
 	if disallowed, found := DisAllowedDataKeyNames[_keyscope]; found && disallowed {
 		return fmt.Errorf("key name is disallowed: " + ctx.Key)
 	}
-	if _, ok := cfgredis.Servers.Get(ctx.RdsName); !ok {
-		return fmt.Errorf("rds item unconfigured: " + ctx.RdsName)
+	if _, ok := getDataSource(ctx.RdsName); !ok {
+		return fmt.Errorf("%w: %s", ErrDataSourceUnavailable, ctx.RdsName)
 	}
 	return nil
 }
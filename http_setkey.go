@@ -1,6 +1,7 @@
 package redisdb
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/doptime/redisdb/utils"
@@ -15,9 +16,11 @@ type IHttpSetKey interface {
 	GetValue() interface{}
 	ValidDataKey() error
 	TimestampFiller(in interface{}) (err error)
+	// Del / Exists / Type / Rename / Expire / TTL / Persist of the key, checked against IsAllowedCommon
+	IHttpCommonKey
 
 	// --- 上下文注入 (核心) ---
-	WithContext(key string, ds string) IHttpSetKey
+	WithContext(c context.Context, key string, ds string) IHttpSetKey
 
 	// --- 数据操作 ---
 	// 读操作: 返回 interface{} (底层是 v 或 []v)
@@ -62,9 +65,10 @@ func (ctx *HttpSetKey[k, v]) TimestampFiller(in interface{}) (err error) {
 }
 
 // WithContext 实现：克隆并注入上下文
-func (ctx *HttpSetKey[k, v]) WithContext(key string, ds string) IHttpSetKey {
+func (ctx *HttpSetKey[k, v]) WithContext(c context.Context, key string, ds string) IHttpSetKey {
 	// 1. 获取底层 RedisKey 的副本
 	newObj := ctx.native().Duplicate(key, ds)
+	newObj = newObj.withCtx(c)
 
 	// 2. 重新包装
 	newSet := SetKey[k, v]{RedisKey: newObj}
@@ -139,11 +143,16 @@ func (ctx *HttpSetKey[k, v]) SScan(cursor uint64, match string, count int64) ([]
 
 // 工厂方法
 func GetHttpSetKey(Key string, rdsName string) (IHttpSetKey, error) {
+	return GetHttpSetKeyWithCtx(context.Background(), Key, rdsName)
+}
+
+// GetHttpSetKeyWithCtx 同 GetHttpSetKey,返回的 key 上的命令都在 c 下执行(取消、超时、tracing)
+func GetHttpSetKeyWithCtx(c context.Context, Key string, rdsName string) (IHttpSetKey, error) {
 	_keyscope := KeyScope(Key)
 	ikey, ok := HttpSetKeyMap.Get(_keyscope + ":" + rdsName)
 	if !ok {
-		return nil, fmt.Errorf("key schema not found for: %s", _keyscope)
+		return nil, fmt.Errorf("%w: %s", ErrSchemaNotRegistered, _keyscope)
 	}
 	// 核心：调用 WithContext
-	return ikey.WithContext(Key, rdsName), nil
+	return ikey.WithContext(c, Key, rdsName), nil
 }
@@ -1,6 +1,7 @@
 package redisdb
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -17,22 +18,32 @@ type IHttpStreamKey interface {
 	GetValue() interface{}
 	ValidDataKey() error
 	TimestampFiller(in interface{}) (err error)
+	// Del / Exists / Type / Rename / Expire / TTL / Persist of the key, checked against IsAllowedCommon
+	IHttpCommonKey
 
 	// --- 上下文注入 (核心) ---
-	WithContext(key string, ds string) IHttpStreamKey
+	WithContext(c context.Context, key string, ds string) IHttpStreamKey
 
 	// --- 数据操作 ---
 	XLen() (int64, error)
-	// XAdd 参数简化：HTTP 层传 ID 和 Values (通常是 map 或 slice)
+	// XAdd 参数简化：HTTP 层传 ID 和 Values; a value of type v is stored like XAddValue, a map or slice as raw fields
 	XAdd(id string, values interface{}) (string, error)
 	XDel(ids ...string) error
 
-	// Range 类：统一返回 interface{} (实际是 []redis.XMessage)
+	// Range 类：返回 interface{} (实际是 []StreamEntry[v], 值已解码; 没有 "data" 字段的条目返回原始 Fields)
 	XRange(start, stop string, count int64) (interface{}, error)
 	XRevRange(start, stop string, count int64) (interface{}, error)
 
-	// Read 类
+	// Read 类：返回 map[stream][]StreamEntry[v]
 	XRead(streams []string, count int64, block time.Duration) (interface{}, error)
+
+	// 裁剪 / 信息类 (XTrim / XInfo 权限位)
+	XTrimMaxLen(maxLen int64, approx bool) (int64, error)
+	XTrimMinID(minID string, approx bool) (int64, error)
+	// XInfoStream 返回 *StreamInfo[v]
+	XInfoStream() (interface{}, error)
+	XInfoGroups() ([]redis.XInfoGroup, error)
+	XInfoConsumers(group string) ([]redis.XInfoConsumer, error)
 }
 
 // 全局注册表
@@ -66,9 +77,10 @@ func (ctx *HttpStreamKey[k, v]) TimestampFiller(in interface{}) (err error) {
 }
 
 // WithContext: 克隆对象并注入上下文
-func (ctx *HttpStreamKey[k, v]) WithContext(key string, ds string) IHttpStreamKey {
+func (ctx *HttpStreamKey[k, v]) WithContext(c context.Context, key string, ds string) IHttpStreamKey {
 	// 1. 获取原始对象的副本 (浅拷贝 RedisKey)
 	newObj := ctx.native().Duplicate(key, ds)
+	newObj = newObj.withCtx(c)
 	// 2. 包装并返回
 	newCtx := HttpStreamKey[k, v]{RedisKey: newObj}
 	return &newCtx
@@ -81,6 +93,12 @@ func (ctx *HttpStreamKey[k, v]) XLen() (int64, error) {
 }
 
 func (ctx *HttpStreamKey[k, v]) XAdd(id string, values interface{}) (string, error) {
+	if value, ok := values.(v); ok {
+		if id == "" {
+			id = "*"
+		}
+		return ctx.native().xAddValue(id, value)
+	}
 	// 构造 XAddArgs
 	args := &redis.XAddArgs{
 		ID:     id,
@@ -95,19 +113,49 @@ func (ctx *HttpStreamKey[k, v]) XDel(ids ...string) error {
 	return err
 }
 
+// entries decodes msgs like toEntries, but an entry without the "data" field, written as raw fields by XAdd, keeps its Fields
+func (ctx *HttpStreamKey[k, v]) entries(msgs []redis.XMessage) ([]StreamEntry[v], error) {
+	entries := make([]StreamEntry[v], 0, len(msgs))
+	for _, msg := range msgs {
+		if _, ok := msg.Values[StreamValueField].(string); !ok {
+			entries = append(entries, StreamEntry[v]{ID: msg.ID, Fields: msg.Values})
+			continue
+		}
+		entry, err := ctx.native().toEntry(msg)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
 func (ctx *HttpStreamKey[k, v]) XRange(start, stop string, count int64) (interface{}, error) {
-	// 根据 count 判断调用哪个底层方法
+	var msgs []redis.XMessage
+	var err error
 	if count > 0 {
-		return ctx.native().XRangeN(start, stop, count)
+		msgs, err = ctx.native().XRangeN(start, stop, count)
+	} else {
+		msgs, err = ctx.native().XRange(start, stop)
+	}
+	if err != nil {
+		return nil, err
 	}
-	return ctx.native().XRange(start, stop)
+	return ctx.entries(msgs)
 }
 
 func (ctx *HttpStreamKey[k, v]) XRevRange(start, stop string, count int64) (interface{}, error) {
+	var msgs []redis.XMessage
+	var err error
 	if count > 0 {
-		return ctx.native().XRevRangeN(start, stop, count)
+		msgs, err = ctx.native().XRevRangeN(start, stop, count)
+	} else {
+		msgs, err = ctx.native().XRevRange(start, stop)
 	}
-	return ctx.native().XRevRange(start, stop)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.entries(msgs)
 }
 
 func (ctx *HttpStreamKey[k, v]) XRead(streams []string, count int64, block time.Duration) (interface{}, error) {
@@ -116,16 +164,55 @@ func (ctx *HttpStreamKey[k, v]) XRead(streams []string, count int64, block time.
 		Count:   count,
 		Block:   block,
 	}
-	return ctx.native().XRead(args)
+	read, err := ctx.native().XRead(args)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string][]StreamEntry[v], len(read))
+	for _, stream := range read {
+		if values[stream.Stream], err = ctx.entries(stream.Messages); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func (ctx *HttpStreamKey[k, v]) XTrimMaxLen(maxLen int64, approx bool) (int64, error) {
+	return ctx.native().XTrimMaxLen(maxLen, approx)
+}
+
+func (ctx *HttpStreamKey[k, v]) XTrimMinID(minID string, approx bool) (int64, error) {
+	return ctx.native().XTrimMinID(minID, approx)
+}
+
+func (ctx *HttpStreamKey[k, v]) XInfoStream() (interface{}, error) {
+	info, err := ctx.native().XInfoStream()
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (ctx *HttpStreamKey[k, v]) XInfoGroups() ([]redis.XInfoGroup, error) {
+	return ctx.native().XInfoGroups()
+}
+
+func (ctx *HttpStreamKey[k, v]) XInfoConsumers(group string) ([]redis.XInfoConsumer, error) {
+	return ctx.native().XInfoConsumers(group)
 }
 
 // 工厂方法
 func GetHttpStreamKey(Key string, rdsName string) (IHttpStreamKey, error) {
+	return GetHttpStreamKeyWithCtx(context.Background(), Key, rdsName)
+}
+
+// GetHttpStreamKeyWithCtx 同 GetHttpStreamKey,返回的 key 上的命令都在 c 下执行(取消、超时、tracing)
+func GetHttpStreamKeyWithCtx(c context.Context, Key string, rdsName string) (IHttpStreamKey, error) {
 	_keyscope := KeyScope(Key)
 	ikey, ok := HttpStreamKeyMap.Get(_keyscope + ":" + rdsName)
 	if !ok {
-		return nil, fmt.Errorf("key schema not found for: %s", _keyscope)
+		return nil, fmt.Errorf("%w: %s", ErrSchemaNotRegistered, _keyscope)
 	}
 	// 核心：调用 WithContext
-	return ikey.WithContext(Key, rdsName), nil
+	return ikey.WithContext(c, Key, rdsName), nil
 }
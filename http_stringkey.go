@@ -1,6 +1,7 @@
 package redisdb
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -16,10 +17,19 @@ type IHttpStringKey interface {
 	TimestampFiller(in interface{}) (err error)
 
 	// Context 注入 (核心：用于多租户/Key变换)
-	WithContext(key string, RedisDataSource string) IHttpStringKey
+	WithContext(c context.Context, key string, RedisDataSource string) IHttpStringKey
 
 	Set(field string, val interface{}, expiration time.Duration) error
 	Get(field string) (interface{}, error)
+	// IHttpCommonKey of the string key of field, checked against IsAllowedCommon
+	Expire(field string, ttl time.Duration) error
+	ExpireAt(field string, tm time.Time) error
+	TTL(field string) (time.Duration, error)
+	Persist(field string) error
+	DelKey(field string) error
+	ExistsKey(field string) (bool, error)
+	TypeOfKey(field string) (string, error)
+	RenameKey(field string, newField string) error
 }
 
 var HttpStringKeyMap cmap.ConcurrentMap[string, IHttpStringKey] = cmap.New[IHttpStringKey]()
@@ -51,9 +61,10 @@ func (ctx *HttpStringKey[k, v]) TimestampFiller(in interface{}) (err error) {
 }
 
 // WithContext 实现：克隆自己，修改 Key 和 DS，返回接口
-func (ctx *HttpStringKey[k, v]) WithContext(key string, RedisDataSource string) IHttpStringKey {
+func (ctx *HttpStringKey[k, v]) WithContext(c context.Context, key string, RedisDataSource string) IHttpStringKey {
 	// 1. 获取原始对象的副本 (RedisKey)
 	newObj := ctx.native().Duplicate(key, RedisDataSource)
+	newObj = newObj.withCtx(c)
 	// 2. 包装回 HttpStringKey
 	// 注意：StringKey 结构体中嵌入了 RedisKey，所以可以直接初始化
 	newCtx := HttpStringKey[k, v]{RedisKey: newObj}
@@ -78,7 +89,11 @@ func (ctx *HttpStringKey[k, v]) Set(field string, val interface{}, expiration ti
 		return fmt.Errorf("value type assertion failed: expected %T, got %T", *new(v), val)
 	}
 
-	// 3. 调用底层 Set
+	// 3. 调用底层 Set; 带 version 字段的值必须携带客户端读到的版本
+	if skey.HasVersion() {
+		_, err = skey.SetWithVersion(key, _v, expiration)
+		return err
+	}
 	return skey.Set(key, _v, expiration)
 }
 
@@ -95,13 +110,95 @@ func (ctx *HttpStringKey[k, v]) Get(field string) (val interface{}, err error) {
 	return skey.Get(key)
 }
 
+// concreteKey is the redis key of field
+func (ctx *HttpStringKey[k, v]) concreteKey(field string) (string, error) {
+	key, err := ctx.native().toKey([]byte(field))
+	if err != nil {
+		return "", err
+	}
+	return ctx.native().fullKey(key)
+}
+
+func (ctx *HttpStringKey[k, v]) Expire(field string, ttl time.Duration) error {
+	key, err := ctx.concreteKey(field)
+	if err != nil {
+		return err
+	}
+	return ctx.httpExpire(key, ttl)
+}
+
+func (ctx *HttpStringKey[k, v]) ExpireAt(field string, tm time.Time) error {
+	key, err := ctx.concreteKey(field)
+	if err != nil {
+		return err
+	}
+	return ctx.httpExpireAt(key, tm)
+}
+
+func (ctx *HttpStringKey[k, v]) TTL(field string) (time.Duration, error) {
+	key, err := ctx.concreteKey(field)
+	if err != nil {
+		return 0, err
+	}
+	return ctx.httpTTL(key)
+}
+
+func (ctx *HttpStringKey[k, v]) Persist(field string) error {
+	key, err := ctx.concreteKey(field)
+	if err != nil {
+		return err
+	}
+	return ctx.httpPersist(key)
+}
+
+func (ctx *HttpStringKey[k, v]) DelKey(field string) error {
+	key, err := ctx.concreteKey(field)
+	if err != nil {
+		return err
+	}
+	return ctx.httpDel(key)
+}
+
+func (ctx *HttpStringKey[k, v]) ExistsKey(field string) (bool, error) {
+	key, err := ctx.concreteKey(field)
+	if err != nil {
+		return false, err
+	}
+	return ctx.httpExists(key)
+}
+
+func (ctx *HttpStringKey[k, v]) TypeOfKey(field string) (string, error) {
+	key, err := ctx.concreteKey(field)
+	if err != nil {
+		return "", err
+	}
+	return ctx.httpType(key)
+}
+
+func (ctx *HttpStringKey[k, v]) RenameKey(field string, newField string) error {
+	key, err := ctx.concreteKey(field)
+	if err != nil {
+		return err
+	}
+	newKey, err := ctx.concreteKey(newField)
+	if err != nil {
+		return err
+	}
+	return ctx.httpRename(key, newKey)
+}
+
 // 工厂方法
 func GetHttpStringKey(Key string, rdsName string) (IHttpStringKey, error) {
+	return GetHttpStringKeyWithCtx(context.Background(), Key, rdsName)
+}
+
+// GetHttpStringKeyWithCtx 同 GetHttpStringKey,返回的 key 上的命令都在 c 下执行(取消、超时、tracing)
+func GetHttpStringKeyWithCtx(c context.Context, Key string, rdsName string) (IHttpStringKey, error) {
 	_keyscope := KeyScope(Key)
 	ikey, ok := HttpStringKeyMap.Get(_keyscope + ":" + rdsName)
 	if !ok {
-		return nil, fmt.Errorf("key schema not found for scope: %s", _keyscope)
+		return nil, fmt.Errorf("%w: %s", ErrSchemaNotRegistered, _keyscope)
 	}
 	// 核心修改：必须调用 WithContext 注入具体的 Key 和 DataSource
-	return ikey.WithContext(Key, rdsName), nil
+	return ikey.WithContext(c, Key, rdsName), nil
 }
@@ -1,6 +1,7 @@
 package redisdb
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/doptime/redisdb/utils"
@@ -17,7 +18,7 @@ type IHttpVectorSetKey interface {
 	TimestampFiller(in interface{}) (err error)
 
 	// --- 上下文注入 (核心) ---
-	WithContext(key string, ds string) IHttpVectorSetKey
+	WithContext(c context.Context, key string, ds string) IHttpVectorSetKey
 
 	// --- 索引管理 ---
 	Create(args ...interface{}) error
@@ -69,9 +70,10 @@ func (ctx *HttpVectorSetKey[k, v]) TimestampFiller(in interface{}) (err error) {
 }
 
 // WithContext 实现：克隆并注入上下文
-func (ctx *HttpVectorSetKey[k, v]) WithContext(key string, ds string) IHttpVectorSetKey {
+func (ctx *HttpVectorSetKey[k, v]) WithContext(c context.Context, key string, ds string) IHttpVectorSetKey {
 	// 1. 获取底层 RedisKey 的副本
 	newObj := ctx.native().Duplicate(key, ds)
+	newObj = newObj.withCtx(c)
 	// 2. 包装并返回
 	newKey := VectorSetKey[k, v]{RedisKey: newObj}
 	newCtx := HttpVectorSetKey[k, v](newKey)
@@ -116,10 +118,15 @@ func (ctx *HttpVectorSetKey[k, v]) Search(query string, params ...interface{}) (
 
 // 工厂方法
 func GetHttpVectorSetKey(Key string, rdsName string) (IHttpVectorSetKey, error) {
+	return GetHttpVectorSetKeyWithCtx(context.Background(), Key, rdsName)
+}
+
+// GetHttpVectorSetKeyWithCtx 同 GetHttpVectorSetKey,返回的 key 上的命令都在 c 下执行(取消、超时、tracing)
+func GetHttpVectorSetKeyWithCtx(c context.Context, Key string, rdsName string) (IHttpVectorSetKey, error) {
 	_keyscope := KeyScope(Key)
 	ikey, ok := HttpVectorSetKeyMap.Get(_keyscope + ":" + rdsName)
 	if !ok {
-		return nil, fmt.Errorf("key schema not found for: %s", _keyscope)
+		return nil, fmt.Errorf("%w: %s", ErrSchemaNotRegistered, _keyscope)
 	}
-	return ikey.WithContext(Key, rdsName), nil
+	return ikey.WithContext(c, Key, rdsName), nil
 }
@@ -52,9 +52,17 @@ const (
 	HIncrByFloat
 	HSetNX
 	HScan
-
-	HashRead  = uint64(HGet|HMGET|HExists|HGetAll|HRandField|HRandFieldWithValues|HLen|HKeys|HVals|HScan) | CommonRead
-	HashWrite = uint64(HSet|HDel|HIncrBy|HIncrByFloat|HSetNX) | CommonWrite
+	// 字段级 TTL (redis 7.4+)
+	HSetEx
+	HExpire
+	HPExpire
+	HTTL
+	HPersist
+	HGetEx
+	HGetDel
+
+	HashRead  = uint64(HGet|HMGET|HExists|HGetAll|HRandField|HRandFieldWithValues|HLen|HKeys|HVals|HScan|HTTL) | CommonRead
+	HashWrite = uint64(HSet|HDel|HIncrBy|HIncrByFloat|HSetNX|HSetEx|HExpire|HPExpire|HPersist|HGetEx|HGetDel) | CommonWrite
 	HashAll   = HashRead | HashWrite
 )
 
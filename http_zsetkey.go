@@ -1,6 +1,7 @@
 package redisdb
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/doptime/redisdb/utils"
@@ -17,9 +18,11 @@ type IHttpZSetKey interface {
 	GetValue() interface{}
 	ValidDataKey() error
 	TimestampFiller(in interface{}) (err error)
+	// Del / Exists / Type / Rename / Expire / TTL / Persist of the key, checked against IsAllowedCommon
+	IHttpCommonKey
 
 	// Context 注入 (核心：用于多租户/Key变换)
-	WithContext(key string, ds string) IHttpZSetKey
+	WithContext(c context.Context, key string, ds string) IHttpZSetKey
 
 	// 数据操作 (对应 startHttp.go 中的调用)
 	ZAdd(members ...redis.Z) (err error)
@@ -74,9 +77,10 @@ func (ctx *HttpZSetKey[k, v]) ValidDataKey() error {
 }
 
 // WithContext 实现：克隆自己，修改 Key 和 DS，返回接口
-func (ctx *HttpZSetKey[k, v]) WithContext(key string, RedisDataSource string) IHttpZSetKey {
+func (ctx *HttpZSetKey[k, v]) WithContext(c context.Context, key string, RedisDataSource string) IHttpZSetKey {
 	// 1. 获取原始对象的副本 (浅拷贝结构体)
 	newObj := ctx.native().Duplicate(key, RedisDataSource)
+	newObj = newObj.withCtx(c)
 	newCtx := HttpZSetKey[k, v]{RedisKey: newObj}
 	return &newCtx
 }
@@ -159,11 +163,16 @@ func (ctx *HttpZSetKey[k, v]) ZScan(cursor uint64, match string, count int64) (i
 
 // 工厂方法
 func GetHttpZSetKey(Key string, rdsName string) (IHttpZSetKey, error) {
+	return GetHttpZSetKeyWithCtx(context.Background(), Key, rdsName)
+}
+
+// GetHttpZSetKeyWithCtx 同 GetHttpZSetKey,返回的 key 上的命令都在 c 下执行(取消、超时、tracing)
+func GetHttpZSetKeyWithCtx(c context.Context, Key string, rdsName string) (IHttpZSetKey, error) {
 	_keyscope := KeyScope(Key)
 	// 这里返回的是 IHttpZSetKey 接口，底层可能是 HttpZSetKey[string, *Profile]
 	ikey, ok := HttpZSetKeyMap.Get(_keyscope + ":" + rdsName)
 	if !ok {
-		return nil, fmt.Errorf("key schema not found for scope: %s", _keyscope)
+		return nil, fmt.Errorf("%w: %s", ErrSchemaNotRegistered, _keyscope)
 	}
-	return ikey.WithContext(Key, rdsName), nil
+	return ikey.WithContext(c, Key, rdsName), nil
 }
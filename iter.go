@@ -0,0 +1,91 @@
+package redisdb
+
+import (
+	"context"
+	"iter"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ScanPageSize is the COUNT hint used by the iterators (KeysIter, HashKey.All, SetKey.Members, ZSetKey.ScanAll, StringKey.Entries)
+var ScanPageSize int64 = 256
+
+// KeyValue is yielded by HashKey.All and StringKey.Entries
+type KeyValue[k comparable, v any] struct {
+	Key   k
+	Value v
+}
+
+// ZMember is yielded by ZSetKey.ScanAll
+type ZMember[v any] struct {
+	Member v
+	Score  float64
+}
+
+// masterNodes lists the master nodes of a cluster (shards of a ring), or the client itself.
+// unlike forEachMaster the nodes can then be visited one by one, which iterators need
+func masterNodes(c context.Context, rds redis.UniversalClient) (nodes []redis.UniversalClient, err error) {
+	var mu sync.Mutex
+	err = forEachMaster(c, rds, func(c context.Context, node redis.UniversalClient) error {
+		mu.Lock()
+		nodes = append(nodes, node)
+		mu.Unlock()
+		return nil
+	})
+	return nodes, err
+}
+
+// scanPages yields the keys matching match page by page, master by master. yield returns false to stop
+func scanPages(c context.Context, rds redis.UniversalClient, match string, yield func(node redis.UniversalClient, keys []string, err error) bool) {
+	nodes, err := masterNodes(c, rds)
+	if err != nil {
+		yield(nil, nil, err)
+		return
+	}
+	for _, node := range nodes {
+		var cursor uint64
+		for {
+			keys, next, err := node.Scan(c, cursor, match, ScanPageSize).Result()
+			if err != nil {
+				yield(node, nil, err)
+				return
+			}
+			if len(keys) > 0 && !yield(node, keys, nil) {
+				return
+			}
+			if cursor = next; cursor == 0 {
+				break
+			}
+		}
+	}
+}
+
+// trimKeyPrefix removes "ctx.Key:" from a full redis key
+func (ctx *RedisKey[k, v]) trimKeyPrefix(key string) string {
+	if len(ctx.Key) > 0 && strings.HasPrefix(key, ctx.Key+":") {
+		return key[len(ctx.Key)+1:]
+	}
+	return key
+}
+
+// KeysIter iterates the keys under "ctx.Key:*" with SCAN, page by page. a failing key decode is yielded and iteration goes on;
+// a redis error is yielded last. it replaces the deprecated Keys, which loads every key with KEYS
+func (ctx *RedisKey[k, v]) KeysIter() iter.Seq2[k, error] {
+	return func(yield func(k, error) bool) {
+		scanPages(ctx.Context, ctx.reader(), ctx.Key+":*", func(_ redis.UniversalClient, keys []string, err error) bool {
+			if err != nil {
+				var zero k
+				yield(zero, err)
+				return false
+			}
+			for _, key := range keys {
+				if !yield(ctx.toKey([]byte(ctx.trimKeyPrefix(key)))) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package redisdb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/doptime/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+// DelayQueue holds jobs until a given time, on top of a ZSetKey: the sorted set of the key option scores job IDs by due time (unix ms),
+// the hash "{<key>}:jobs" holds their values. due jobs are taken atomically, so any number of pollers can run.
+// due times are read from the redis server clock, the one of QueueKey
+type DelayQueue[v any] struct {
+	// set is the sorted set of job IDs. they are stored as is, not with the member encoding of ZSetKey,
+	// so the ZSetKey commands are not exposed
+	set ZSetKey[string, string]
+	// jobs encodes the values, as configured by the options
+	jobs RedisKey[string, v]
+}
+
+// DelayedJob is a job taken from a DelayQueue
+type DelayedJob[v any] struct {
+	ID    string
+	At    time.Time
+	Value v
+}
+
+// NewDelayQueue creates a DelayQueue on the sorted set of the key option
+func NewDelayQueue[v any](ops ...Option) *DelayQueue[v] {
+	ctx := &DelayQueue[v]{jobs: RedisKey[string, v]{KeyType: KeyTypeHash}}
+	// the key name defaults to the name of v, the options apply to the values
+	err := ctx.jobs.applyOptionsAndCheck(KeyTypeHash, ops...)
+	ctx.set = ZSetKey[string, string]{RedisKey[string, string]{KeyType: KeyTypeZSet, Key: ctx.jobs.Key, RdsName: ctx.jobs.RdsName, Rds: ctx.jobs.Rds}}
+	ctx.jobs.Key = ctx.jobsKey()
+	if err != nil {
+		ctx.set.invalidate("NewDelayQueue", err)
+	}
+	ctx.set.InitFunc()
+	ctx.jobs.InitFunc()
+	return ctx
+}
+
+// MustNewDelayQueue is NewDelayQueue for strict startup: it panics if the options are invalid or the data source is not available
+func MustNewDelayQueue[v any](ops ...Option) *DelayQueue[v] {
+	ctx := NewDelayQueue[v](ops...)
+	ctx.set.mustBeUsable("MustNewDelayQueue")
+	return ctx
+}
+
+func (ctx *DelayQueue[v]) WithCtx(c context.Context) *DelayQueue[v] {
+	return &DelayQueue[v]{ZSetKey[string, string]{ctx.set.withCtx(c)}, ctx.jobs.withCtx(c)}
+}
+
+// Key is the name of the sorted set of job IDs
+func (ctx *DelayQueue[v]) Key() string { return ctx.set.Key }
+
+// Err returns the error the queue was created with, nil if it is usable. see RedisKey.Err
+func (ctx *DelayQueue[v]) Err() error { return ctx.set.Err() }
+
+func (ctx *DelayQueue[v]) jobsKey() string { return "{" + ctx.set.Key + "}:jobs" }
+func (ctx *DelayQueue[v]) deadKey() string { return "{" + ctx.set.Key + "}:dead" }
+
+// now reads the clock of the redis server, so pollers and schedulers with skewed clocks agree on what is due
+func (ctx *DelayQueue[v]) now() (time.Time, error) {
+	return ctx.set.rds().Time(ctx.set.Context).Result()
+}
+
+// Schedule adds value as a job due at at. returns its ID
+func (ctx *DelayQueue[v]) Schedule(value v, at time.Time) (string, error) {
+	id := NanoId(16)
+	return id, ctx.ScheduleAs(id, value, at)
+}
+
+// ScheduleIn adds value as a job due in d, by the redis server clock. returns its ID
+func (ctx *DelayQueue[v]) ScheduleIn(value v, d time.Duration) (string, error) {
+	now, err := ctx.now()
+	if err != nil {
+		return "", err
+	}
+	return ctx.Schedule(value, now.Add(d))
+}
+
+// ScheduleAs adds value as the job id due at at, replacing the job id if it is scheduled
+func (ctx *DelayQueue[v]) ScheduleAs(id string, value v, at time.Time) error {
+	if ctx.jobs.UseModer {
+		ApplyModifiers(&value)
+	}
+	valStr, err := ctx.jobs.SerializeValue(value)
+	if err != nil {
+		return err
+	}
+	_, err = ctx.set.rds().TxPipelined(ctx.set.Context, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx.set.Context, ctx.jobsKey(), id, valStr)
+		pipe.ZAdd(ctx.set.Context, ctx.set.Key, redis.Z{Score: float64(at.UnixMilli()), Member: id})
+		return nil
+	})
+	return err
+}
+
+// Cancel removes the job id. returns ErrNotFound if it isn't scheduled, already taken or never was
+func (ctx *DelayQueue[v]) Cancel(id string) error {
+	var removed *redis.IntCmd
+	_, err := ctx.set.rds().TxPipelined(ctx.set.Context, func(pipe redis.Pipeliner) error {
+		removed = pipe.ZRem(ctx.set.Context, ctx.set.Key, id)
+		pipe.HDel(ctx.set.Context, ctx.jobsKey(), id)
+		return nil
+	})
+	if err == nil && removed.Val() == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// At returns when the job id is due. returns ErrNotFound if it isn't scheduled
+func (ctx *DelayQueue[v]) At(id string) (time.Time, error) {
+	ms, err := ctx.set.reader().ZScore(ctx.set.Context, ctx.set.Key, id).Result()
+	if err != nil {
+		return time.Time{}, asNotFound(err)
+	}
+	return time.UnixMilli(int64(ms)), nil
+}
+
+// Len returns the number of jobs scheduled, due or not
+func (ctx *DelayQueue[v]) Len() (int64, error) {
+	return ctx.set.reader().ZCard(ctx.set.Context, ctx.set.Key).Result()
+}
+
+// popDueScript takes up to ARGV[1] jobs of KEYS[1] due by the server clock, with their values in KEYS[2].
+// with KEYS[3] it pushes the values to that list and returns their number, else it returns id, due, value of each.
+// it reads TIME before writing, which needs effects replication before redis 7
+var popDueScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', now, 'WITHSCORES', 'LIMIT', 0, tonumber(ARGV[1]))
+local out, n = {}, 0
+for i = 1, #ids, 2 do
+	local value = redis.call('HGET', KEYS[2], ids[i])
+	redis.call('ZREM', KEYS[1], ids[i])
+	redis.call('HDEL', KEYS[2], ids[i])
+	if value then
+		n = n + 1
+		if KEYS[3] then
+			redis.call('RPUSH', KEYS[3], value)
+		else
+			out[#out + 1] = ids[i]
+			out[#out + 1] = ids[i + 1]
+			out[#out + 1] = value
+		end
+	end
+end
+if KEYS[3] then return n end
+return out
+`)
+
+// PopDue takes up to limit jobs that are due (all if limit <= 0), earliest first. each job is taken by a single caller.
+// a job that can't be decoded is moved to the hash "{Key}:dead" (ID to stored value); the first such error is returned with the other jobs
+func (ctx *DelayQueue[v]) PopDue(limit int64) (jobs []DelayedJob[v], err error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	keys := []string{ctx.set.Key, ctx.jobsKey()}
+	reply, err := popDueScript.Run(ctx.set.Context, ctx.set.rds(), keys, limit).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	jobs = make([]DelayedJob[v], 0, len(reply)/3)
+	for i := 0; i+2 < len(reply); i += 3 {
+		job := DelayedJob[v]{ID: reply[i]}
+		ms, parseErr := strconv.ParseFloat(reply[i+1], 64)
+		job.At = time.UnixMilli(int64(ms))
+		value, decodeErr := ctx.jobs.decodeValue(job.ID, []byte(reply[i+2]))
+		if parseErr != nil || decodeErr != nil {
+			if err == nil {
+				err = decodeErr
+				if parseErr != nil {
+					err = fmt.Errorf("redisdb: DelayQueue %s: due time of %s: %w", ctx.set.Key, job.ID, parseErr)
+				}
+			}
+			if deadErr := ctx.set.rds().HSet(ctx.set.Context, ctx.deadKey(), job.ID, reply[i+2]).Err(); deadErr != nil {
+				logger.Error().Err(deadErr).Str("key", ctx.set.Key).Str("id", job.ID).Msg("redisdb: DelayQueue.PopDue failed to keep an undecodable job, job lost")
+			}
+			continue
+		}
+		job.Value = value
+		jobs = append(jobs, job)
+	}
+	return jobs, err
+}
+
+// MoveDue moves up to limit due jobs (all if limit <= 0), earliest first, to the end of ready, for workers popping it from the front.
+// the stored values are moved as is: ready must be on the data source of the queue, in its hash slot ("{<key>}:ready"),
+// with the same codec, compression and key provider, else nothing is moved and an error is returned. returns the number moved
+func (ctx *DelayQueue[v]) MoveDue(ready *ListKey[v], limit int64) (int64, error) {
+	if err := ctx.checkReady(ready); err != nil {
+		return 0, err
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+	keys := []string{ctx.set.Key, ctx.jobsKey(), ready.Key}
+	return popDueScript.Run(ctx.set.Context, ctx.set.rds(), keys, limit).Int64()
+}
+
+// checkReady returns an error unless the values of the queue can be moved to ready as they are stored
+func (ctx *DelayQueue[v]) checkReady(ready *ListKey[v]) error {
+	if err := ready.Err(); err != nil {
+		return err
+	}
+	mismatch := ""
+	switch {
+	case ready.RdsName != ctx.jobs.RdsName:
+		mismatch = fmt.Sprintf("data source %q, want %q", ready.RdsName, ctx.jobs.RdsName)
+	case keySlot(ready.Key) != keySlot(ctx.jobsKey()):
+		mismatch = fmt.Sprintf("hash slot of %s, want the one of %s, e.g. {%s}:ready", ready.Key, ctx.jobsKey(), ctx.set.Key)
+	case ready.Codec.Name() != ctx.jobs.Codec.Name():
+		mismatch = fmt.Sprintf("codec %s, want %s", ready.Codec.Name(), ctx.jobs.Codec.Name())
+	case ready.Compression != ctx.jobs.Compression:
+		mismatch = "compression"
+	case ctx.jobs.UseEncryptor && !sameKeyProvider(ready.KeyProvider, ctx.jobs.KeyProvider):
+		mismatch = "key provider"
+	}
+	if mismatch != "" {
+		return fmt.Errorf("redisdb: DelayQueue %s: MoveDue to %s: %s", ctx.set.Key, ready.Key, mismatch)
+	}
+	return nil
+}
+
+// sameKeyProvider reports whether a and b are the same key provider; providers of uncomparable types never are
+func sameKeyProvider(a, b KeyProvider) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return reflect.TypeOf(a).Comparable() && reflect.TypeOf(b).Comparable() && a == b
+}
+
+// Poll takes the due jobs every interval (1s if <= 0) and runs handler on each, until the context of the key (WithCtx) is done.
+// a job whose handler returns an error or panics is scheduled again interval later, with the same ID
+func (ctx *DelayQueue[v]) Poll(interval time.Duration, handler func(DelayedJob[v]) error) error {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// taken jobs are still handled or scheduled again after the shutdown
+	q := ctx.WithCtx(context.WithoutCancel(ctx.set.Context))
+	for {
+		for {
+			jobs, err := q.PopDue(100)
+			if err != nil {
+				logger.Error().Err(err).Str("key", ctx.set.Key).Msg("redisdb: DelayQueue.Poll failed")
+			}
+			for _, job := range jobs {
+				q.handle(job, interval, handler)
+			}
+			if len(jobs) < 100 || ctx.set.Context.Err() != nil {
+				break
+			}
+		}
+		select {
+		case <-ctx.set.Context.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (ctx *DelayQueue[v]) handle(job DelayedJob[v], retryIn time.Duration, handler func(DelayedJob[v]) error) {
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return handler(job)
+	}()
+	if err == nil {
+		return
+	}
+	logger.Info().Err(err).Str("key", ctx.set.Key).Str("id", job.ID).Msg("redisdb: DelayQueue.Poll handler failed, job scheduled again")
+	now, err := ctx.now()
+	if err == nil {
+		err = ctx.ScheduleAs(job.ID, job.Value, now.Add(retryIn))
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("key", ctx.set.Key).Str("id", job.ID).Msg("redisdb: DelayQueue.Poll failed to schedule a job again, job lost")
+	}
+}
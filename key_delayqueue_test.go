@@ -0,0 +1,206 @@
+package redisdb_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doptime/redisdb"
+)
+
+func TestDelayQueueSchedule(t *testing.T) {
+	_, rds := newServer(t)
+	q := redisdb.NewDelayQueue[*Event](rds.Key("reminders").Modifier(trimmed))
+	later, _ := q.ScheduleIn(&Event{User: "later", Kind: "mail"}, time.Hour)
+	second, _ := q.Schedule(&Event{User: "second", Kind: "mail"}, time.Now().Add(-time.Second))
+	first, _ := q.Schedule(&Event{User: " first ", Kind: "mail"}, time.Now().Add(-time.Minute))
+	if n, err := q.Len(); err != nil || n != 3 {
+		t.Fatalf("Len = %d, %v", n, err)
+	}
+	if at, err := q.At(later); err != nil || time.Until(at) < 59*time.Minute {
+		t.Fatalf("At = %v, %v", at, err)
+	}
+	jobs, err := q.PopDue(0)
+	if err != nil || len(jobs) != 2 || jobs[0].ID != first || jobs[1].ID != second {
+		t.Fatalf("PopDue = %+v, %v, want the due jobs, earliest first", jobs, err)
+	}
+	if jobs[0].Value.User != "first" {
+		t.Fatalf("PopDue value = %+v, want modifiers applied", jobs[0].Value)
+	}
+	if jobs, _ = q.PopDue(0); len(jobs) != 0 {
+		t.Fatalf("PopDue again = %+v", jobs)
+	}
+
+	if err = q.Cancel(later); err != nil {
+		t.Fatal(err)
+	}
+	if err = q.Cancel(later); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("Cancel twice = %v, want ErrNotFound", err)
+	}
+	if _, err = q.At(later); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("At of a canceled job = %v, want ErrNotFound", err)
+	}
+	if n, _ := q.Len(); n != 0 {
+		t.Fatalf("Len after Cancel = %d", n)
+	}
+}
+
+func TestDelayQueueMoveDue(t *testing.T) {
+	_, rds := newServer(t)
+	q := redisdb.NewDelayQueue[string](rds.Key("reminders"))
+	ready := redisdb.NewListKey[string](rds.Key("{reminders}:ready"))
+	q.Schedule("b", time.Now().Add(-time.Second))
+	q.Schedule("a", time.Now().Add(-time.Minute))
+	q.ScheduleIn("c", time.Hour)
+	// values are moved as stored: a list in another slot or with another codec is refused
+	for _, other := range []*redisdb.ListKey[string]{
+		redisdb.NewListKey[string](rds.Key("reminders:ready")),
+		redisdb.NewListKey[string](rds.Key("{reminders}:ready").Codec(redisdb.JSONCodec)),
+	} {
+		if n, err := q.MoveDue(other, 0); err == nil || n != 0 {
+			t.Fatalf("MoveDue to %s = %d, %v, want an error", other.Key, n, err)
+		}
+	}
+	if n, _ := q.Len(); n != 3 {
+		t.Fatalf("Len after a refused MoveDue = %d", n)
+	}
+	if n, err := q.MoveDue(ready, 0); err != nil || n != 2 {
+		t.Fatalf("MoveDue = %d, %v", n, err)
+	}
+	if values, _ := ready.LRange(0, -1); len(values) != 2 || values[0] != "a" || values[1] != "b" {
+		t.Fatalf("ready = %v", values)
+	}
+	if n, _ := q.Len(); n != 1 {
+		t.Fatalf("Len after MoveDue = %d", n)
+	}
+}
+
+func TestDelayQueueConcurrentPollers(t *testing.T) {
+	_, rds := newServer(t)
+	q := redisdb.NewDelayQueue[int](rds.Key("reminders"))
+	for i := 0; i < 50; i++ {
+		q.Schedule(i, time.Now().Add(-time.Second))
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	taken := map[string]int{}
+	for p := 0; p < 4; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				jobs, err := q.PopDue(3)
+				if err != nil || len(jobs) == 0 {
+					return
+				}
+				mu.Lock()
+				for _, job := range jobs {
+					taken[job.ID]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(taken) != 50 {
+		t.Fatalf("%d jobs taken, want 50", len(taken))
+	}
+	for id, n := range taken {
+		if n != 1 {
+			t.Fatalf("%s taken %d times", id, n)
+		}
+	}
+}
+
+func TestDelayQueuePoll(t *testing.T) {
+	_, rds := newServer(t)
+	q := redisdb.NewDelayQueue[string](rds.Key("reminders"))
+	q.Schedule("a", time.Now())
+	id, _ := q.ScheduleIn("b", 50*time.Millisecond)
+	c, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	handled, failed := map[string]int{}, false
+	done := make(chan error)
+	go func() {
+		done <- q.WithCtx(c).Poll(20*time.Millisecond, func(job redisdb.DelayedJob[string]) error {
+			mu.Lock()
+			defer mu.Unlock()
+			// the first attempt of b fails and is scheduled again under the same ID
+			if job.Value == "b" && !failed {
+				failed = true
+				if job.ID != id {
+					t.Errorf("job ID = %s, want %s", job.ID, id)
+				}
+				return errors.New("transient")
+			}
+			if handled[job.Value]++; len(handled) == 2 {
+				cancel()
+			}
+			return nil
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		cancel()
+		t.Fatalf("Poll didn't handle every job: %v", handled)
+	}
+	if !failed || handled["a"] != 1 || handled["b"] != 1 {
+		t.Fatalf("handled = %v, failed = %v", handled, failed)
+	}
+	if n, _ := q.Len(); n != 0 {
+		t.Fatalf("Len after Poll = %d", n)
+	}
+}
+
+func TestDelayQueueDeadJobs(t *testing.T) {
+	srv, rds := newServer(t)
+	q := redisdb.NewDelayQueue[*Event](rds.Key("reminders"))
+	bad, _ := q.Schedule(&Event{User: "bad"}, time.Now().Add(-time.Second))
+	good, _ := q.Schedule(&Event{User: "good"}, time.Now().Add(-time.Second))
+	srv.HSet("{reminders}:jobs", bad, "garbage")
+	jobs, err := q.PopDue(0)
+	var decodeErr *redisdb.DecodeError
+	if !errors.As(err, &decodeErr) || len(jobs) != 1 || jobs[0].ID != good {
+		t.Fatalf("PopDue = %+v, %v, want the good job and a DecodeError", jobs, err)
+	}
+	if got := srv.HGet("{reminders}:dead", bad); got != "garbage" {
+		t.Fatalf("dead job = %q, want it kept", got)
+	}
+}
+
+func TestDelayQueuePollRecovers(t *testing.T) {
+	_, rds := newServer(t)
+	q := redisdb.NewDelayQueue[string](rds.Key("reminders"))
+	q.Schedule("a", time.Now())
+	c, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	done := make(chan error)
+	go func() {
+		// interval 0 polls every second
+		done <- q.WithCtx(c).Poll(0, func(job redisdb.DelayedJob[string]) error {
+			if attempts++; attempts == 1 {
+				panic("transient")
+			}
+			cancel()
+			return nil
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		cancel()
+		t.Fatalf("Poll didn't retry the job after a panic, %d attempts", attempts)
+	}
+	if attempts != 2 {
+		t.Fatalf("attempts = %d, want 2", attempts)
+	}
+}
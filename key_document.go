@@ -0,0 +1,407 @@
+package redisdb
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DocumentKey stores each value of the struct type v as its own hash "<key>:<id>", one hash field per struct field,
+// so fields can be read, indexed and patched one by one. the id is the first field of v of type k, as in HashKey.Save.
+// field names come from the msgpack tag, then the json tag, then the field name; `-` skips a field, omitempty skips zero values.
+// strings, numbers, bools and encoding.TextMarshaler (time.Time) are stored as text, other types as json;
+// `encrypt` tagged string / []byte fields are stored as ciphertext
+type DocumentKey[k comparable, v any] struct {
+	RedisKey[k, v]
+	fields []docField
+	byName map[string]int
+}
+
+type docField struct {
+	index     int
+	name      string
+	omitEmpty bool
+	// encrypted fields (`encrypt` tag) are stored as ciphertext, see EncryptFields
+	encrypted bool
+}
+
+// NewDocumentKey creates a DocumentKey, v must be a struct or a pointer to one
+func NewDocumentKey[k comparable, v any](ops ...Option) *DocumentKey[k, v] {
+	ctx := &DocumentKey[k, v]{}
+	if err := ctx.applyOptionsAndCheck(KeyTypeHash, ops...); err != nil {
+		ctx.invalidate("NewDocumentKey", err)
+	}
+	ctx.InitFunc()
+	ctx.getPrimaryKeyFieldIndex()
+	vType := reflect.TypeOf((*v)(nil)).Elem()
+	for vType.Kind() == reflect.Ptr {
+		vType = vType.Elem()
+	}
+	if vType.Kind() != reflect.Struct {
+		ctx.invalidate("NewDocumentKey", fmt.Errorf("value type %s is not a struct", vType))
+		return ctx
+	}
+	var err error
+	if ctx.fields, ctx.byName, err = docFields(vType); err != nil {
+		ctx.invalidate("NewDocumentKey", err)
+	}
+	return ctx
+}
+
+// MustNewDocumentKey is NewDocumentKey for strict startup: it panics if the options are invalid or the data source is not available
+func MustNewDocumentKey[k comparable, v any](ops ...Option) *DocumentKey[k, v] {
+	ctx := NewDocumentKey[k, v](ops...)
+	ctx.mustBeUsable("MustNewDocumentKey")
+	return ctx
+}
+
+// docFields returns the stored fields of t. an `encrypt` tag that can't be honoured is an error, the field would be stored in plaintext
+func docFields(t reflect.Type) (fields []docField, byName map[string]int, err error) {
+	byName = map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, opts := storedFieldName(sf)
+		if name == "-" {
+			continue
+		}
+		encryptor, err := newFieldEncryptor(i, sf)
+		if err != nil {
+			return nil, nil, err
+		}
+		byName[name] = len(fields)
+		fields = append(fields, docField{index: i, name: name, omitEmpty: strings.Contains(opts, "omitempty"), encrypted: encryptor != nil})
+	}
+	return fields, byName, nil
+}
+
+func (ctx *DocumentKey[k, v]) ConcatKey(fields ...interface{}) *DocumentKey[k, v] {
+	return &DocumentKey[k, v]{ctx.Duplicate(ConcatedKeys(ctx.Key, fields...), ctx.RdsName), ctx.fields, ctx.byName}
+}
+func (ctx *DocumentKey[k, v]) WithCtx(c context.Context) *DocumentKey[k, v] {
+	return &DocumentKey[k, v]{ctx.withCtx(c), ctx.fields, ctx.byName}
+}
+func (ctx *DocumentKey[k, v]) Primary() *DocumentKey[k, v] {
+	return ctx.WithCtx(ReadFromPrimary(ctx.Context))
+}
+
+func (ctx *DocumentKey[k, v]) docKey(id k) (string, error) {
+	idStr, err := ctx.SerializeKey(id)
+	if err != nil {
+		return "", err
+	}
+	return ctx.Key + ":" + idStr, nil
+}
+
+// Save replaces the document of value's id with value. returns ErrNoPrimaryKey if v has no field of type k
+func (ctx *DocumentKey[k, v]) Save(value v) error {
+	if ctx.UseModer {
+		ApplyModifiers(&value)
+	}
+	rv := reflect.Indirect(reflect.ValueOf(value))
+	if ctx.PrimaryKeyFieldIndex < 0 || rv.Kind() != reflect.Struct {
+		return ErrNoPrimaryKey
+	}
+	id, ok := rv.Field(ctx.PrimaryKeyFieldIndex).Interface().(k)
+	if !ok {
+		return ErrNoPrimaryKey
+	}
+	key, err := ctx.docKey(id)
+	if err != nil {
+		return err
+	}
+	args := make([]interface{}, 0, 2*len(ctx.fields))
+	for _, f := range ctx.fields {
+		fv := rv.Field(f.index)
+		if f.omitEmpty && fv.IsZero() || fv.Kind() == reflect.Ptr && fv.IsNil() {
+			continue
+		}
+		s, err := ctx.encodeField(f, fv)
+		if err != nil {
+			return fmt.Errorf("redisdb: encode %s %s: %w", key, f.name, err)
+		}
+		args = append(args, f.name, s)
+	}
+	_, err = ctx.rds().TxPipelined(ctx.Context, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx.Context, key)
+		if len(args) > 0 {
+			pipe.HSet(ctx.Context, key, args...)
+		}
+		if ctx.DefaultTTL > 0 {
+			pipe.PExpire(ctx.Context, key, ctx.DefaultTTL)
+		}
+		return nil
+	})
+	return err
+}
+
+// Load rebuilds the document of id. hash fields that are not fields of v are ignored. returns ErrNotFound if it doesn't exist
+func (ctx *DocumentKey[k, v]) Load(id k) (value v, err error) {
+	key, err := ctx.docKey(id)
+	if err != nil {
+		return value, err
+	}
+	defer ctx.touch(key)
+	raw, err := ctx.reader().HGetAll(ctx.Context, key).Result()
+	if err != nil {
+		return value, err
+	}
+	if len(raw) == 0 {
+		return value, ErrNotFound
+	}
+	rv := reflect.ValueOf(&value).Elem()
+	for rv.Kind() == reflect.Ptr {
+		rv.Set(reflect.New(rv.Type().Elem()))
+		rv = rv.Elem()
+	}
+	for name, s := range raw {
+		i, ok := ctx.byName[name]
+		if !ok {
+			continue
+		}
+		if err = ctx.decodeField(ctx.fields[i], rv.Field(ctx.fields[i].index), s); err != nil {
+			return value, &DecodeError{Key: key, Field: name, Raw: []byte(s), Err: err}
+		}
+	}
+	return value, nil
+}
+
+// patchScript sets the ARGV[1] field / value pairs that follow it and deletes the remaining fields of KEYS[1], if it exists.
+// returns 0 if it doesn't
+var patchScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then return 0 end
+local n = 2 + 2 * tonumber(ARGV[1])
+if n > 2 then redis.call('HSET', KEYS[1], unpack(ARGV, 3, n)) end
+if #ARGV > n then redis.call('HDEL', KEYS[1], unpack(ARGV, n + 1)) end
+if tonumber(ARGV[2]) > 0 then redis.call('PEXPIRE', KEYS[1], ARGV[2]) end
+return 1
+`)
+
+// Patch sets some fields of the document of id in one atomic step, leaving the others as they are.
+// fields are named as stored, values are converted to the type of the field: 3.0 to an int, "true" to a bool, a map to a struct...
+// nil removes a pointer field. returns ErrUnknownField for a name that isn't a field of v, ErrNotFound if the document doesn't exist
+func (ctx *DocumentKey[k, v]) Patch(id k, fields map[string]any) error {
+	key, err := ctx.docKey(id)
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	vType := reflect.TypeOf((*v)(nil)).Elem()
+	for vType.Kind() == reflect.Ptr {
+		vType = vType.Elem()
+	}
+	var set, del []interface{}
+	for name, val := range fields {
+		i, ok := ctx.byName[name]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownField, name)
+		}
+		fv := reflect.New(vType.Field(ctx.fields[i].index).Type).Elem()
+		if err = assignDocField(fv, val); err != nil {
+			return fmt.Errorf("redisdb: patch %s %s: %w", key, name, err)
+		}
+		if fv.Kind() == reflect.Ptr && fv.IsNil() {
+			del = append(del, name)
+			continue
+		}
+		s, err := ctx.encodeField(ctx.fields[i], fv)
+		if err != nil {
+			return fmt.Errorf("redisdb: patch %s %s: %w", key, name, err)
+		}
+		set = append(set, name, s)
+	}
+	args := append(append([]interface{}{len(set) / 2, ctx.DefaultTTL.Milliseconds()}, set...), del...)
+	n, err := patchScript.Run(ctx.Context, ctx.rds(), []string{key}, args...).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Del removes the documents of ids
+func (ctx *DocumentKey[k, v]) Del(ids ...k) error {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		key, err := ctx.docKey(id)
+		if err != nil {
+			return err
+		}
+		keys[i] = key
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return ctx.rds().Del(ctx.Context, keys...).Err()
+}
+
+// encodeField encodes fv, the field f, encrypting it if tagged so
+func (ctx *DocumentKey[k, v]) encodeField(f docField, fv reflect.Value) (string, error) {
+	s, err := encodeDocField(fv)
+	if err != nil || !f.encrypted {
+		return s, err
+	}
+	sealed, err := encryptText(ctx.keyProvider(), f.name, []byte(s))
+	return string(sealed), err
+}
+
+// decodeField decrypts s if f is tagged so, then decodes it into fv
+func (ctx *DocumentKey[k, v]) decodeField(f docField, fv reflect.Value, s string) error {
+	if f.encrypted {
+		plain, err := decryptText(ctx.keyProvider(), f.name, []byte(s))
+		if err != nil {
+			return err
+		}
+		s = string(plain)
+	}
+	return decodeDocField(fv, s)
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+func encodeDocField(fv reflect.Value) (string, error) {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return "", nil
+		}
+		fv = fv.Elem()
+	}
+	if fv.Type().Implements(textMarshalerType) {
+		b, err := fv.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		return fv.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(fv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(fv.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(fv.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(fv.Float(), 'f', -1, 64), nil
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Uint8 {
+			return string(fv.Bytes()), nil
+		}
+	}
+	b, err := json.Marshal(fv.Interface())
+	return string(b), err
+}
+
+// decodeDocField parses s, as written by encodeDocField, into fv
+func decodeDocField(fv reflect.Value, s string) (err error) {
+	for fv.Kind() == reflect.Ptr {
+		fv.Set(reflect.New(fv.Type().Elem()))
+		fv = fv.Elem()
+	}
+	if u, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok && fv.Type().Implements(textMarshalerType) {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		fv.SetBool(b)
+		return err
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		fv.SetInt(n)
+		return err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		fv.SetUint(n)
+		return err
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, fv.Type().Bits())
+		fv.SetFloat(f)
+		return err
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Uint8 {
+			fv.SetBytes([]byte(s))
+			return nil
+		}
+	}
+	return json.Unmarshal([]byte(s), fv.Addr().Interface())
+}
+
+// assignDocField sets fv, a field of v, to val of any type
+func assignDocField(fv reflect.Value, val any) error {
+	if val == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(val)
+	switch {
+	case rv.Type().AssignableTo(fv.Type()):
+		fv.Set(rv)
+		return nil
+	case rv.Kind() == reflect.String:
+		return decodeDocField(fv, rv.String())
+	case isNumberKind(rv.Kind()) && isNumberKind(fv.Kind()):
+		return assignNumber(fv, rv)
+	}
+	// maps / slices decoded from json requests: round trip through json
+	b, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, fv.Addr().Interface())
+}
+
+// assignNumber sets the number field fv to the number rv, rejecting fractions for integers and values out of the range of fv
+func assignNumber(fv, rv reflect.Value) error {
+	var overflow bool
+	switch fk := fv.Kind(); {
+	case fk == reflect.Float32 || fk == reflect.Float64:
+		overflow = fv.OverflowFloat(rv.Convert(reflect.TypeOf(float64(0))).Float())
+	case rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) {
+			return fmt.Errorf("%v is not an integer", f)
+		}
+		if fk >= reflect.Uint {
+			overflow = f < 0 || f >= 1<<64 || fv.OverflowUint(uint64(f))
+		} else {
+			overflow = f < math.MinInt64 || f >= 1<<63 || fv.OverflowInt(int64(f))
+		}
+	case rv.Kind() >= reflect.Uint:
+		if fk >= reflect.Uint {
+			overflow = fv.OverflowUint(rv.Uint())
+		} else {
+			overflow = rv.Uint() > math.MaxInt64 || fv.OverflowInt(int64(rv.Uint()))
+		}
+	default:
+		if fk >= reflect.Uint {
+			overflow = rv.Int() < 0 || fv.OverflowUint(uint64(rv.Int()))
+		} else {
+			overflow = fv.OverflowInt(rv.Int())
+		}
+	}
+	if overflow {
+		return fmt.Errorf("%v overflows %s", rv.Interface(), fv.Type())
+	}
+	fv.Set(rv.Convert(fv.Type()))
+	return nil
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64 && kind != reflect.Uintptr
+}
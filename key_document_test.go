@@ -0,0 +1,171 @@
+package redisdb_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/doptime/redisdb"
+)
+
+type Profile struct {
+	ID       string            `msgpack:"id"`
+	Name     string            `json:"name"`
+	Age      int               `msgpack:"age"`
+	Score    float64           `msgpack:"score"`
+	Active   bool              `msgpack:"active"`
+	Tags     []string          `msgpack:"tags"`
+	Address  *Address          `msgpack:"address"`
+	Note     string            `msgpack:"note,omitempty"`
+	Joined   time.Time         `msgpack:"joined"`
+	Extra    map[string]string `msgpack:"extra"`
+	Internal string            `msgpack:"-"`
+}
+
+type Address struct {
+	City string `json:"city"`
+}
+
+func TestDocumentKeySaveLoad(t *testing.T) {
+	srv, rds := newServer(t)
+	profiles := redisdb.NewDocumentKey[string, *Profile](rds.Key("profile"))
+	joined := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	p := &Profile{ID: "p1", Name: "alice", Age: 30, Score: 1.5, Active: true, Tags: []string{"a", "b"},
+		Address: &Address{City: "Paris"}, Joined: joined, Extra: map[string]string{"k": "v"}, Internal: "secret"}
+	if err := profiles.Save(p); err != nil {
+		t.Fatal(err)
+	}
+	// fields are stored one by one, readable as text
+	for field, want := range map[string]string{"id": "p1", "name": "alice", "age": "30", "score": "1.5", "active": "true",
+		"tags": `["a","b"]`, "address": `{"city":"Paris"}`, "joined": "2024-05-01T08:00:00Z"} {
+		if got := srv.HGet("profile:p1", field); got != want {
+			t.Fatalf("field %s = %q, want %q", field, got, want)
+		}
+	}
+	if keys, _ := srv.HKeys("profile:p1"); len(keys) != 9 {
+		t.Fatalf("stored fields = %v, want no note or Internal", keys)
+	}
+
+	got, err := profiles.Load("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "alice" || got.Age != 30 || got.Score != 1.5 || !got.Active || len(got.Tags) != 2 ||
+		got.Address == nil || got.Address.City != "Paris" || !got.Joined.Equal(joined) || got.Extra["k"] != "v" || got.Internal != "" {
+		t.Fatalf("Load = %+v", got)
+	}
+
+	// Save replaces the whole document
+	if err = profiles.Save(&Profile{ID: "p1", Name: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if srv.HGet("profile:p1", "address") != "" {
+		t.Fatal("Save kept a field of the previous document")
+	}
+	if _, err = profiles.Load("missing"); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("Load missing = %v, want ErrNotFound", err)
+	}
+	if err = profiles.Del("p1"); err != nil || srv.Exists("profile:p1") {
+		t.Fatalf("Del = %v", err)
+	}
+}
+
+func TestDocumentKeyPatch(t *testing.T) {
+	srv, rds := newServer(t)
+	profiles := redisdb.NewDocumentKey[string, Profile](rds.Key("profile"))
+	profiles.Save(Profile{ID: "p1", Name: "alice", Age: 30, Address: &Address{City: "Paris"}})
+
+	// values as decoded from a json request are converted to the field types
+	err := profiles.Patch("p1", map[string]any{"age": float64(31), "active": "true", "address": map[string]any{"city": "Rome"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := profiles.Load("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "alice" || got.Age != 31 || !got.Active || got.Address.City != "Rome" {
+		t.Fatalf("Load after Patch = %+v", got)
+	}
+	if err = profiles.Patch("p1", map[string]any{"address": nil}); err != nil || srv.HGet("profile:p1", "address") != "" {
+		t.Fatalf("Patch nil = %v, field %q", err, srv.HGet("profile:p1", "address"))
+	}
+
+	if err = profiles.Patch("p1", map[string]any{"age": 1.5}); err == nil {
+		t.Fatal("Patch of a fraction into an int succeeded")
+	}
+	if err = profiles.Patch("p1", map[string]any{"nope": 1}); !errors.Is(err, redisdb.ErrUnknownField) {
+		t.Fatalf("Patch unknown field = %v, want ErrUnknownField", err)
+	}
+	if err = profiles.Patch("missing", map[string]any{"age": 1}); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("Patch missing = %v, want ErrNotFound", err)
+	}
+	if srv.Exists("profile:missing") {
+		t.Fatal("Patch created a partial document")
+	}
+}
+
+func TestDocumentKeyNotStruct(t *testing.T) {
+	_, rds := newServer(t)
+	if key := redisdb.NewDocumentKey[string, string](rds.Key("doc")); key.Err() == nil {
+		t.Fatal("NewDocumentKey of a string value succeeded")
+	}
+}
+
+func TestDocumentKeyPatchRange(t *testing.T) {
+	_, rds := newServer(t)
+	type Counter struct {
+		ID    string `msgpack:"id"`
+		Small int8   `msgpack:"small"`
+		Size  uint   `msgpack:"size"`
+	}
+	counters := redisdb.NewDocumentKey[string, Counter](rds.Key("counter"))
+	counters.Save(Counter{ID: "c1"})
+	for _, patch := range []map[string]any{{"small": float64(300)}, {"small": int64(-129)}, {"size": float64(-1)}, {"size": -1}} {
+		if err := counters.Patch("c1", patch); err == nil {
+			t.Fatalf("Patch %v succeeded", patch)
+		}
+	}
+	if err := counters.Patch("c1", map[string]any{"small": float64(-128), "size": uint64(7)}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := counters.Load("c1"); got.Small != -128 || got.Size != 7 {
+		t.Fatalf("Load after Patch = %+v", got)
+	}
+}
+
+func TestDocumentKeyEncryptedFields(t *testing.T) {
+	srv, rds := newServer(t)
+	patients := redisdb.NewDocumentKey[string, *Patient](rds.Key("patient").KeyProvider(testKeys))
+	if err := patients.Save(&Patient{Name: "alice", SSN: "123-45-6789"}); err != nil {
+		t.Fatal(err)
+	}
+	if raw := srv.HGet("patient:alice", "ssn"); !strings.HasPrefix(raw, "$enc$k1$") {
+		t.Fatalf("stored ssn = %q, want a ciphertext", raw)
+	}
+	if err := patients.Patch("alice", map[string]any{"ssn": "987-65-4321"}); err != nil {
+		t.Fatal(err)
+	}
+	if raw := srv.HGet("patient:alice", "ssn"); !strings.HasPrefix(raw, "$enc$k1$") {
+		t.Fatalf("patched ssn = %q, want a ciphertext", raw)
+	}
+	if got, err := patients.Load("alice"); err != nil || got.SSN != "987-65-4321" {
+		t.Fatalf("Load = %+v, %v", got, err)
+	}
+}
+
+func TestDocumentKeyInvalidEncryptTag(t *testing.T) {
+	srv, rds := newServer(t)
+	type Record struct {
+		ID    string `msgpack:"id"`
+		Score int    `msgpack:"score" encrypt:"aes-gcm"`
+	}
+	records := redisdb.NewDocumentKey[string, *Record](rds.Key("record").KeyProvider(testKeys))
+	if err := records.Err(); !errors.Is(err, redisdb.ErrInvalidEncryptTag) {
+		t.Fatalf("Err = %v, want ErrInvalidEncryptTag", err)
+	}
+	if err := records.Save(&Record{ID: "r1", Score: 7}); err == nil || srv.Exists("record:r1") {
+		t.Fatalf("Save = %v, want the plaintext never stored", err)
+	}
+}
@@ -1,11 +1,13 @@
 package redisdb
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"reflect"
+	"time"
 
-	"github.com/doptime/logger"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -18,14 +20,22 @@ type HashKey[k comparable, v any] struct {
 func NewHashKey[k comparable, v any](ops ...Option) *HashKey[k, v] {
 	ctx := &HashKey[k, v]{RedisKey: RedisKey[k, v]{}}
 	if err := ctx.applyOptionsAndCheck(KeyTypeHash, ops...); err != nil {
-		logger.Error().Err(err).Msg("redisdb.NewHashKey failed")
-		return nil
+		ctx.invalidate("NewHashKey", err)
 	}
 	ctx.InitFunc()
 	ctx.getPrimaryKeyFieldIndex()
 	return ctx
 }
-func (ctx *HashKey[K, V]) getPrimaryKeyFieldIndex() {
+
+// MustNewHashKey is NewHashKey for strict startup: it panics if the options are invalid or the data source is not available
+func MustNewHashKey[k comparable, v any](ops ...Option) *HashKey[k, v] {
+	ctx := NewHashKey[k, v](ops...)
+	ctx.mustBeUsable("MustNewHashKey")
+	return ctx
+}
+
+// getPrimaryKeyFieldIndex finds the first field of v assignable to k, the id used by Save
+func (ctx *RedisKey[K, V]) getPrimaryKeyFieldIndex() {
 	ctx.PrimaryKeyFieldIndex = -1
 
 	// 获取 V 的反射类型
@@ -64,6 +74,12 @@ func (ctx *HashKey[K, V]) getPrimaryKeyFieldIndex() {
 func (ctx *HashKey[k, v]) ConcatKey(fields ...interface{}) *HashKey[k, v] {
 	return &HashKey[k, v]{ctx.Duplicate(ConcatedKeys(ctx.Key, fields...), ctx.RdsName)}
 }
+func (ctx *HashKey[k, v]) WithCtx(c context.Context) *HashKey[k, v] {
+	return &HashKey[k, v]{ctx.withCtx(c)}
+}
+func (ctx *HashKey[k, v]) Primary() *HashKey[k, v] {
+	return ctx.WithCtx(ReadFromPrimary(ctx.Context))
+}
 func (ctx *HashKey[k, v]) HttpOn(op HashOp) (ctx1 *HashKey[k, v]) {
 	if op != 0 && ctx.Key != "" {
 		httpAllow(ctx.Key, uint64(op))
@@ -81,19 +97,19 @@ func (ctx *HashKey[k, v]) RegisterHttpInterface() {
 }
 
 func (ctx *HashKey[k, v]) HGet(field k) (value v, err error) {
+	defer ctx.touch(ctx.Key)
 	fieldStr, err := ctx.SerializeKey(field)
 	if err != nil {
 		return value, err
 	}
-	cmd := ctx.Rds.HGet(ctx.Context, ctx.Key, fieldStr)
-	if err := cmd.Err(); err != nil {
-		return value, err
-	}
-	data, err := cmd.Bytes()
+	data, err := ctx.cached(ctx.Key, fieldStr, func(rds redis.UniversalClient) ([]byte, error) {
+		data, err := rds.HGet(ctx.Context, ctx.Key, fieldStr).Bytes()
+		return data, asNotFound(err)
+	})
 	if err != nil {
 		return value, err
 	}
-	return ctx.DeserializeToValue(data)
+	return ctx.decodeValue(fieldStr, data)
 }
 
 // HSet accepts values in following formats:
@@ -102,7 +118,7 @@ func (ctx *HashKey[k, v]) HGet(field k) (value v, err error) {
 //
 //   - HSet("myhash", map[string]interface{}{"key1": "value1", "key2": "value2"})
 func (ctx *HashKey[k, v]) HSet(values ...interface{}) (int64, error) {
-
+	defer ctx.uncache(ctx.Key)
 	if kvMap, ok := values[0].(map[k]v); ok {
 		return ctx.HMSet(kvMap)
 	}
@@ -127,27 +143,127 @@ func (ctx *HashKey[k, v]) HSet(values ...interface{}) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	return ctx.Rds.HSet(ctx.Context, ctx.Key, KeyValuesStrs).Result()
+	return writeWithTTL(&ctx.RedisKey, ctx.Key, func(rds redis.Cmdable) *redis.IntCmd {
+		return rds.HSet(ctx.Context, ctx.Key, KeyValuesStrs)
+	}).Result()
 }
 func (ctx *HashKey[k, v]) Save(value v) (int64, error) {
-	//get first field of v , which type is k
-	var fieldN = reflect.TypeOf(value).NumField()
-	if fieldN == 0 {
-		return ctx.HSet(value)
-	}
+	defer ctx.uncache(ctx.Key)
 	if ctx.UseModer {
 		ApplyModifiers(&value)
 	}
-	if ctx.PrimaryKeyFieldIndex > 0 {
-		field, ok := reflect.ValueOf(value).Field(ctx.PrimaryKeyFieldIndex).Interface().(k)
+	//get first field of v , which type is k
+	if rv := reflect.Indirect(reflect.ValueOf(value)); ctx.PrimaryKeyFieldIndex >= 0 && rv.Kind() == reflect.Struct {
+		field, ok := rv.Field(ctx.PrimaryKeyFieldIndex).Interface().(k)
 		if ok {
 			return ctx.HSet(field, value)
 		}
 	}
-	return 0, fmt.Errorf("no field of type k found in value")
+	return 0, ErrNoPrimaryKey
+}
+
+// Update reads field, applies fn and writes the result back with WATCH/MULTI/EXEC, retrying when the hash is modified concurrently.
+// fn gets the zero value if field doesn't exist. if v has a `version` field it is incremented.
+func (ctx *HashKey[k, v]) Update(field k, fn func(old v) (v, error)) (value v, err error) {
+	defer ctx.uncache(ctx.Key)
+	fieldStr, err := ctx.SerializeKey(field)
+	if err != nil {
+		return value, err
+	}
+	for i := 0; i < MaxUpdateRetries; i++ {
+		err = ctx.rds().Watch(ctx.Context, func(tx *redis.Tx) error {
+			var old v
+			raw, err := tx.HGet(ctx.Context, ctx.Key, fieldStr).Bytes()
+			if err == nil {
+				if old, err = ctx.decodeValue(fieldStr, raw); err != nil {
+					return err
+				}
+			} else if err != redis.Nil {
+				return err
+			}
+			if value, err = fn(old); err != nil {
+				return err
+			}
+			if ctx.UseModer {
+				ApplyModifiers(&value)
+			}
+			ctx.setVersion(&value, ctx.getVersion(old)+1)
+			valStr, err := ctx.SerializeValue(value)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx.Context, func(pipe redis.Pipeliner) error {
+				pipe.HSet(ctx.Context, ctx.Key, fieldStr, valStr)
+				if ctx.DefaultTTL > 0 {
+					pipe.PExpire(ctx.Context, ctx.Key, ctx.DefaultTTL)
+				}
+				return nil
+			})
+			return err
+		}, ctx.Key)
+		if err != redis.TxFailedErr {
+			return value, err
+		}
+	}
+	return value, fmt.Errorf("%w: %s %s still contended after %d retries", ErrVersionConflict, ctx.Key, fieldStr, MaxUpdateRetries)
+}
+
+// HSetWithVersion writes value only if its `version` field equals the stored version (0 if field doesn't exist),
+// and stores it with version+1. returns ErrVersionConflict otherwise. the check and write are atomic.
+// value is not modified; the stored copy with the new version is returned on success
+func (ctx *HashKey[k, v]) HSetWithVersion(field k, value v) (stored v, err error) {
+	stored, _, err = ctx.hsetWithVersion(field, value, 0)
+	return stored, err
+}
+
+// hsetWithVersion is HSetWithVersion, also reporting whether the field was created like HSet does.
+// fieldTTL > 0 expires the field like HSetEx
+func (ctx *HashKey[k, v]) hsetWithVersion(field k, value v, fieldTTL time.Duration) (stored v, added int64, err error) {
+	defer ctx.uncache(ctx.Key)
+	if !ctx.HasVersion() {
+		return value, 0, fmt.Errorf("redisdb: %T has no version field", value)
+	}
+	fieldStr, err := ctx.SerializeKey(field)
+	if err != nil {
+		return value, 0, err
+	}
+	if ctx.UseModer {
+		ApplyModifiers(&value)
+	}
+	raw, err := ctx.rds().HGet(ctx.Context, ctx.Key, fieldStr).Bytes()
+	if err == redis.Nil {
+		raw, err = nil, nil
+	} else if err != nil {
+		return value, 0, err
+	}
+	next, err := ctx.checkVersion(fieldStr, raw, value)
+	if err != nil {
+		return value, 0, err
+	}
+	valStr, err := ctx.SerializeValue(next)
+	if err != nil {
+		return value, 0, err
+	}
+	if fieldTTL > 0 {
+		if err = ctx.probeFieldTTL(fieldStr); err != nil {
+			return value, 0, err
+		}
+	}
+	existed, read := casArgs(raw)
+	swapped, err := casHSetScript.Run(ctx.Context, ctx.rds(), []string{ctx.Key}, existed, read, fieldStr, valStr, ctx.DefaultTTL.Milliseconds(), fieldTTL.Milliseconds()).Int()
+	if err != nil {
+		return value, 0, err
+	} else if swapped == 0 {
+		return value, 0, ErrVersionConflict
+	}
+	if raw == nil {
+		added = 1
+	}
+	return next, added, nil
 }
 
 func (ctx *HashKey[k, v]) HMSet(kvMap map[k]v) (int64, error) {
+	defer ctx.uncache(ctx.Key)
 	// if Moder is not nil, apply modifiers to the values
 	if ctx.UseModer {
 		for _, value := range kvMap {
@@ -159,18 +275,22 @@ func (ctx *HashKey[k, v]) HMSet(kvMap map[k]v) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	return ctx.Rds.HSet(ctx.Context, ctx.Key, KeyValuesStrs).Result()
+	return writeWithTTL(&ctx.RedisKey, ctx.Key, func(rds redis.Cmdable) *redis.IntCmd {
+		return rds.HSet(ctx.Context, ctx.Key, KeyValuesStrs)
+	}).Result()
 }
 func (ctx *HashKey[k, v]) HExists(field k) (bool, error) {
+	defer ctx.touch(ctx.Key)
 	fieldStr, err := ctx.SerializeKey(field)
 	if err != nil {
 		return false, err
 	}
-	return ctx.Rds.HExists(ctx.Context, ctx.Key, fieldStr).Result()
+	return ctx.reader().HExists(ctx.Context, ctx.Key, fieldStr).Result()
 }
 
 func (ctx *HashKey[k, v]) HGetAll() (map[k]v, error) {
-	result, err := ctx.Rds.HGetAll(ctx.Context, ctx.Key).Result()
+	defer ctx.touch(ctx.Key)
+	result, err := ctx.reader().HGetAll(ctx.Context, ctx.Key).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -190,19 +310,21 @@ func (ctx *HashKey[k, v]) HGetAll() (map[k]v, error) {
 }
 
 func (ctx *HashKey[k, v]) HRandField(count int) (fields []k, err error) {
+	defer ctx.touch(ctx.Key)
 	var (
 		cmd *redis.StringSliceCmd
 	)
-	if cmd = ctx.Rds.HRandField(ctx.Context, ctx.Key, count); cmd.Err() != nil {
+	if cmd = ctx.reader().HRandField(ctx.Context, ctx.Key, count); cmd.Err() != nil {
 		return nil, cmd.Err()
 	}
 	return ctx.toKeys(cmd.Val())
 }
 func (ctx *HashKey[k, v]) HRandFieldWithValues(count int) (fields []k, values []v, err error) {
+	defer ctx.touch(ctx.Key)
 	var (
 		cmd *redis.KeyValueSliceCmd
 	)
-	if cmd = ctx.Rds.HRandFieldWithValues(ctx.Context, ctx.Key, count); cmd.Err() != nil {
+	if cmd = ctx.reader().HRandFieldWithValues(ctx.Context, ctx.Key, count); cmd.Err() != nil {
 		return nil, nil, cmd.Err()
 	}
 	strs := cmd.Val()
@@ -223,6 +345,7 @@ func (ctx *HashKey[k, v]) HRandFieldWithValues(count int) (fields []k, values []
 	return fields, values, nil
 }
 func (ctx *HashKey[k, v]) HMGET(fields ...interface{}) (values []v, err error) {
+	defer ctx.touch(ctx.Key)
 	var (
 		cmd          *redis.SliceCmd
 		fieldsString []string
@@ -232,7 +355,7 @@ func (ctx *HashKey[k, v]) HMGET(fields ...interface{}) (values []v, err error) {
 	if fieldsString, err = ctx.toKeyStrs(fields...); err != nil {
 		return values, err
 	}
-	if cmd = ctx.Rds.HMGet(ctx.Context, ctx.Key, fieldsString...); cmd.Err() != nil {
+	if cmd = ctx.reader().HMGet(ctx.Context, ctx.Key, fieldsString...); cmd.Err() != nil {
 		return values, cmd.Err()
 	}
 	rawValues = make([]string, len(cmd.Val()))
@@ -245,11 +368,13 @@ func (ctx *HashKey[k, v]) HMGET(fields ...interface{}) (values []v, err error) {
 	return ctx.DeserializeToValues(rawValues)
 }
 func (ctx *HashKey[k, v]) HLen() (length int64, err error) {
-	cmd := ctx.Rds.HLen(ctx.Context, ctx.Key)
+	defer ctx.touch(ctx.Key)
+	cmd := ctx.reader().HLen(ctx.Context, ctx.Key)
 	return cmd.Val(), cmd.Err()
 }
 
 func (ctx *HashKey[k, v]) HDel(fields ...k) (err error) {
+	defer ctx.uncache(ctx.Key)
 	var (
 		cmd       *redis.IntCmd
 		fieldStrs []string
@@ -271,12 +396,13 @@ func (ctx *HashKey[k, v]) HDel(fields ...k) (err error) {
 			fieldStrs[i] = string(bytes)
 		}
 	}
-	cmd = ctx.Rds.HDel(ctx.Context, ctx.Key, fieldStrs...)
+	cmd = ctx.rds().HDel(ctx.Context, ctx.Key, fieldStrs...)
 	return cmd.Err()
 }
 
 func (ctx *HashKey[k, v]) HKeys() ([]k, error) {
-	result, err := ctx.Rds.HKeys(ctx.Context, ctx.Key).Result()
+	defer ctx.touch(ctx.Key)
+	result, err := ctx.reader().HKeys(ctx.Context, ctx.Key).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -292,7 +418,8 @@ func (ctx *HashKey[k, v]) HKeys() ([]k, error) {
 }
 
 func (ctx *HashKey[k, v]) HVals() ([]v, error) {
-	result, err := ctx.Rds.HVals(ctx.Context, ctx.Key).Result()
+	defer ctx.touch(ctx.Key)
+	result, err := ctx.reader().HVals(ctx.Context, ctx.Key).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -308,21 +435,28 @@ func (ctx *HashKey[k, v]) HVals() ([]v, error) {
 }
 
 func (ctx *HashKey[k, v]) HIncrBy(field k, increment int64) error {
+	defer ctx.uncache(ctx.Key)
 	fieldStr, err := ctx.SerializeKey(field)
 	if err != nil {
 		return err
 	}
-	return ctx.Rds.HIncrBy(ctx.Context, ctx.Key, fieldStr, increment).Err()
+	return writeWithTTL(&ctx.RedisKey, ctx.Key, func(rds redis.Cmdable) *redis.IntCmd {
+		return rds.HIncrBy(ctx.Context, ctx.Key, fieldStr, increment)
+	}).Err()
 }
 
 func (ctx *HashKey[k, v]) HIncrByFloat(field k, increment float64) error {
+	defer ctx.uncache(ctx.Key)
 	fieldStr, err := ctx.SerializeKey(field)
 	if err != nil {
 		return err
 	}
-	return ctx.Rds.HIncrByFloat(ctx.Context, ctx.Key, fieldStr, increment).Err()
+	return writeWithTTL(&ctx.RedisKey, ctx.Key, func(rds redis.Cmdable) *redis.FloatCmd {
+		return rds.HIncrByFloat(ctx.Context, ctx.Key, fieldStr, increment)
+	}).Err()
 }
 func (ctx *HashKey[k, v]) HSetNX(field k, value v) error {
+	defer ctx.uncache(ctx.Key)
 	fieldStr, err := ctx.SerializeKey(field)
 	if err != nil {
 		return err
@@ -331,15 +465,18 @@ func (ctx *HashKey[k, v]) HSetNX(field k, value v) error {
 	if err != nil {
 		return err
 	}
-	return ctx.Rds.HSetNX(ctx.Context, ctx.Key, fieldStr, valStr).Err()
+	return writeWithTTL(&ctx.RedisKey, ctx.Key, func(rds redis.Cmdable) *redis.BoolCmd {
+		return rds.HSetNX(ctx.Context, ctx.Key, fieldStr, valStr)
+	}).Err()
 }
 
 func (ctx *HashKey[k, v]) HScan(cursor uint64, match string, count int64) (keys []k, values []v, cursorRet uint64, err error) {
+	defer ctx.touch(ctx.Key)
 	var (
 		cmd          *redis.ScanCmd
 		keyValueStrs []string
 	)
-	if cmd = ctx.Rds.HScan(ctx.Context, ctx.Key, cursor, match, count); cmd.Err() != nil {
+	if cmd = ctx.reader().HScan(ctx.Context, ctx.Key, cursor, match, count); cmd.Err() != nil {
 		return nil, nil, 0, cmd.Err()
 	}
 	keys = make([]k, 0)
@@ -357,11 +494,12 @@ func (ctx *HashKey[k, v]) HScan(cursor uint64, match string, count int64) (keys
 	return keys, values, cursorRet, err
 }
 func (ctx *HashKey[k, v]) HScanNoValues(cursor uint64, match string, count int64) (keys []k, cursorRet uint64, err error) {
+	defer ctx.touch(ctx.Key)
 	var (
 		cmd      *redis.ScanCmd
 		keysStrs []string
 	)
-	if cmd = ctx.Rds.HScanNoValues(ctx.Context, ctx.Key, cursor, match, count); cmd.Err() != nil {
+	if cmd = ctx.reader().HScanNoValues(ctx.Context, ctx.Key, cursor, match, count); cmd.Err() != nil {
 		return nil, 0, cmd.Err()
 	}
 	keysStrs, cursorRet, err = cmd.Result()
@@ -370,3 +508,30 @@ func (ctx *HashKey[k, v]) HScanNoValues(cursor uint64, match string, count int64
 	}
 	return keys, cursorRet, err
 }
+
+// All iterates the hash with HSCAN, page by page. a field that fails to decode is yielded with its error and iteration goes on;
+// a redis error ends the iteration
+func (ctx *HashKey[k, v]) All() iter.Seq2[KeyValue[k, v], error] {
+	return func(yield func(KeyValue[k, v], error) bool) {
+		var cursor uint64
+		for {
+			fieldValues, next, err := ctx.reader().HScan(ctx.Context, ctx.Key, cursor, "", ScanPageSize).Result()
+			if err != nil {
+				yield(KeyValue[k, v]{}, err)
+				return
+			}
+			for i := 0; i+1 < len(fieldValues); i += 2 {
+				var kv KeyValue[k, v]
+				if kv.Key, err = ctx.toKey([]byte(fieldValues[i])); err == nil {
+					kv.Value, err = ctx.decodeValue(fieldValues[i], []byte(fieldValues[i+1]))
+				}
+				if !yield(kv, err) {
+					return
+				}
+			}
+			if cursor = next; cursor == 0 {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package redisdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/doptime/redisdb"
+)
+
+func TestHashKeyHSetHGet(t *testing.T) {
+	_, rds := newServer(t)
+	users := redisdb.NewHashKey[string, *User](rds.Key("users").Modifier(trimmed))
+	if _, err := users.HSet("u1", &User{ID: "u1", Name: "  alice  "}); err != nil {
+		t.Fatal(err)
+	}
+	u, err := users.HGet("u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "alice" {
+		t.Fatalf("mod trim not applied: %q", u.Name)
+	}
+	if _, err = users.HGet("missing"); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("HGet missing: %v, want ErrNotFound", err)
+	}
+	if ok, _ := users.HExists("u1"); !ok {
+		t.Fatal("HExists = false")
+	}
+}
+
+func TestHashKeyBulk(t *testing.T) {
+	_, rds := newServer(t)
+	users := redisdb.NewHashKey[string, *User](rds.Key("users"))
+	in := map[string]*User{"u1": {ID: "u1", Name: "a"}, "u2": {ID: "u2", Name: "b"}, "u3": {ID: "u3", Name: "c"}}
+	if _, err := users.HMSet(in); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := users.HLen(); n != 3 {
+		t.Fatalf("HLen = %d", n)
+	}
+	all, err := users.HGetAll()
+	if err != nil || len(all) != 3 || all["u2"].Name != "b" {
+		t.Fatalf("HGetAll = %v, %v", all, err)
+	}
+	vals, err := users.HMGET("u1", "u3")
+	if err != nil || len(vals) != 2 || vals[1].Name != "c" {
+		t.Fatalf("HMGET = %v, %v", vals, err)
+	}
+	if err = users.HDel("u1"); err != nil {
+		t.Fatal(err)
+	}
+	keys, _ := users.HKeys()
+	if len(keys) != 2 {
+		t.Fatalf("HKeys = %v", keys)
+	}
+	seen := 0
+	for kv, err := range users.All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kv.Value.ID != kv.Key {
+			t.Fatalf("All yielded %s => %+v", kv.Key, kv.Value)
+		}
+		seen++
+	}
+	if seen != 2 {
+		t.Fatalf("All yielded %d values", seen)
+	}
+}
+
+func TestHashKeySave(t *testing.T) {
+	_, rds := newServer(t)
+	type Doc struct {
+		ID   string `msgpack:"id"` // the first field of type K is the primary key
+		Body string `msgpack:"body"`
+	}
+	docs := redisdb.NewHashKey[string, *Doc](rds.Key("docs"))
+	if _, err := docs.Save(&Doc{ID: "d1", Body: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := docs.HGet("d1"); err != nil || d.Body != "x" {
+		t.Fatalf("HGet = %+v, %v", d, err)
+	}
+}
+
+func TestHashKeyVersion(t *testing.T) {
+	_, rds := newServer(t)
+	type Account struct {
+		Balance int   `msgpack:"balance"`
+		Ver     int64 `msgpack:"ver" version:""`
+	}
+	accounts := redisdb.NewHashKey[string, *Account](rds.Key("acct"))
+	first := &Account{Balance: 1}
+	stored, err := accounts.HSetWithVersion("a1", first)
+	if err != nil || stored.Ver != 1 || first.Ver != 0 {
+		t.Fatalf("HSetWithVersion = %+v, %v; caller's value %+v", stored, err, first)
+	}
+	stale := &Account{Balance: 2}
+	if _, err = accounts.HSetWithVersion("a1", stale); !errors.Is(err, redisdb.ErrVersionConflict) || stale.Ver != 0 {
+		t.Fatalf("stale write: %v, want ErrVersionConflict; caller's value %+v", err, stale)
+	}
+	acct, err := accounts.Update("a1", func(old *Account) (*Account, error) {
+		old.Balance += 10
+		return old, nil
+	})
+	if err != nil || acct.Balance != 11 || acct.Ver != 2 {
+		t.Fatalf("Update = %+v, %v", acct, err)
+	}
+}
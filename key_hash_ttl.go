@@ -0,0 +1,185 @@
+package redisdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// hash field ttl: HEXPIRE, HPEXPIRE, HPTTL, HPERSIST need redis 7.4+, HSETEX, HGETEX, HGETDEL redis 8.0+.
+// on 7.4 HSetEx / HGetEx / HGetDel fall back to a MULTI of HSET / HGET + HPEXPIRE / HDEL; older servers return ErrFieldTTLUnsupported
+
+func isUnknownCommand(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "unknown command")
+}
+
+// fieldTTLErr maps the error of a command of the HEXPIRE family on a server without it to ErrFieldTTLUnsupported
+func fieldTTLErr(err error) error {
+	if isUnknownCommand(err) {
+		return fmt.Errorf("%w: %v", ErrFieldTTLUnsupported, err)
+	}
+	return err
+}
+
+// HSetEx sets field to value, expiring after ttl
+func (ctx *HashKey[k, v]) HSetEx(field k, value v, ttl time.Duration) error {
+	defer ctx.uncache(ctx.Key)
+	fieldStr, err := ctx.SerializeKey(field)
+	if err != nil {
+		return err
+	}
+	if ctx.UseModer {
+		ApplyModifiers(&value)
+	}
+	valStr, err := ctx.SerializeValue(value)
+	if err != nil {
+		return err
+	}
+	err = ctx.rds().Do(ctx.Context, "HSETEX", ctx.Key, "PX", ttl.Milliseconds(), "FIELDS", 1, fieldStr, valStr).Err()
+	if !isUnknownCommand(err) {
+		return err
+	}
+	if err = ctx.probeFieldTTL(fieldStr); err != nil {
+		return err
+	}
+	_, err = ctx.rds().TxPipelined(ctx.Context, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx.Context, ctx.Key, fieldStr, valStr)
+		pipe.HPExpire(ctx.Context, ctx.Key, ttl, fieldStr)
+		return nil
+	})
+	return err
+}
+
+// probeFieldTTL checks that the server has HPEXPIRE before a fallback MULTI uses it, so nothing is written without its ttl
+func (ctx *HashKey[k, v]) probeFieldTTL(fieldStr string) error {
+	if err := ctx.rds().HPTTL(ctx.Context, ctx.Key, fieldStr).Err(); isUnknownCommand(err) {
+		return fieldTTLErr(err)
+	}
+	return nil
+}
+
+// HExpire sets the ttl of fields, in seconds. the result of each field is -2 if it doesn't exist, 1 if set, 2 if it was deleted (ttl 0)
+func (ctx *HashKey[k, v]) HExpire(ttl time.Duration, fields ...k) ([]int64, error) {
+	defer ctx.uncache(ctx.Key)
+	fieldStrs, err := ctx.fieldStrs(fields)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := ctx.rds().HExpire(ctx.Context, ctx.Key, ttl, fieldStrs...).Result()
+	return ret, fieldTTLErr(err)
+}
+
+// HPExpire is HExpire in milliseconds
+func (ctx *HashKey[k, v]) HPExpire(ttl time.Duration, fields ...k) ([]int64, error) {
+	defer ctx.uncache(ctx.Key)
+	fieldStrs, err := ctx.fieldStrs(fields)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := ctx.rds().HPExpire(ctx.Context, ctx.Key, ttl, fieldStrs...).Result()
+	return ret, fieldTTLErr(err)
+}
+
+// HTTL returns the remaining time to live of fields: -1 if a field doesn't expire, -2 if it doesn't exist
+func (ctx *HashKey[k, v]) HTTL(fields ...k) ([]time.Duration, error) {
+	fieldStrs, err := ctx.fieldStrs(fields)
+	if err != nil {
+		return nil, err
+	}
+	ms, err := ctx.reader().HPTTL(ctx.Context, ctx.Key, fieldStrs...).Result()
+	if err != nil {
+		return nil, fieldTTLErr(err)
+	}
+	ttls := make([]time.Duration, len(ms))
+	for i, m := range ms {
+		if ttls[i] = time.Duration(m); m >= 0 {
+			ttls[i] = time.Duration(m) * time.Millisecond
+		}
+	}
+	return ttls, nil
+}
+
+// HPersist removes the ttl of fields. the result of each field is -2 if it doesn't exist, -1 if it had no ttl, 1 if removed
+func (ctx *HashKey[k, v]) HPersist(fields ...k) ([]int64, error) {
+	fieldStrs, err := ctx.fieldStrs(fields)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := ctx.rds().HPersist(ctx.Context, ctx.Key, fieldStrs...).Result()
+	return ret, fieldTTLErr(err)
+}
+
+// HGetEx returns the value of field and, if ttl > 0, makes it expire after ttl. returns ErrNotFound if field doesn't exist
+func (ctx *HashKey[k, v]) HGetEx(field k, ttl time.Duration) (value v, err error) {
+	fieldStr, err := ctx.SerializeKey(field)
+	if err != nil {
+		return value, err
+	}
+	if ttl <= 0 {
+		return ctx.HGet(field)
+	}
+	defer ctx.uncache(ctx.Key)
+	return ctx.getAnd(fieldStr, "HGETEX", []interface{}{"HGETEX", ctx.Key, "PX", ttl.Milliseconds(), "FIELDS", 1, fieldStr}, true,
+		func(c context.Context, pipe redis.Pipeliner) { pipe.HPExpire(c, ctx.Key, ttl, fieldStr) })
+}
+
+// HGetDel returns the value of field and deletes it. returns ErrNotFound if field doesn't exist
+func (ctx *HashKey[k, v]) HGetDel(field k) (value v, err error) {
+	fieldStr, err := ctx.SerializeKey(field)
+	if err != nil {
+		return value, err
+	}
+	defer ctx.uncache(ctx.Key)
+	return ctx.getAnd(fieldStr, "HGETDEL", []interface{}{"HGETDEL", ctx.Key, "FIELDS", 1, fieldStr}, false,
+		func(c context.Context, pipe redis.Pipeliner) { pipe.HDel(c, ctx.Key, fieldStr) })
+}
+
+// getAnd runs the single field command args (HGETEX / HGETDEL); on a server without it, HGET and then in the same MULTI.
+// probe checks the server has the command then uses, if it may not
+func (ctx *HashKey[k, v]) getAnd(fieldStr, name string, args []interface{}, probe bool, then func(c context.Context, pipe redis.Pipeliner)) (value v, err error) {
+	reply, err := ctx.rds().Do(ctx.Context, args...).Slice()
+	var raw interface{}
+	if isUnknownCommand(err) {
+		if probe {
+			if err = ctx.probeFieldTTL(fieldStr); err != nil {
+				return value, err
+			}
+		}
+		var get *redis.StringCmd
+		_, err = ctx.rds().TxPipelined(ctx.Context, func(pipe redis.Pipeliner) error {
+			get = pipe.HGet(ctx.Context, ctx.Key, fieldStr)
+			then(ctx.Context, pipe)
+			return nil
+		})
+		if err != nil {
+			return value, asNotFound(err)
+		}
+		raw = get.Val()
+	} else if err != nil {
+		return value, err
+	} else if len(reply) != 1 {
+		return value, fmt.Errorf("redisdb: %s %s: unexpected reply %v", name, ctx.Key, reply)
+	} else {
+		raw = reply[0]
+	}
+	data, ok := raw.(string)
+	if !ok {
+		return value, ErrNotFound
+	}
+	return ctx.decodeValue(fieldStr, []byte(data))
+}
+
+func (ctx *HashKey[k, v]) fieldStrs(fields []k) ([]string, error) {
+	fieldStrs := make([]string, len(fields))
+	for i, field := range fields {
+		fieldStr, err := ctx.SerializeKey(field)
+		if err != nil {
+			return nil, err
+		}
+		fieldStrs[i] = fieldStr
+	}
+	return fieldStrs, nil
+}
@@ -0,0 +1,60 @@
+package redisdb_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/doptime/redisdb"
+)
+
+func TestHashFieldExpire(t *testing.T) {
+	srv, rds := newServer(t)
+	sessions := redisdb.NewHashKey[string, string](rds.Key("sessions"))
+	sessions.HSet("s1", "alice", "s2", "bob")
+	ret, err := sessions.HExpire(time.Minute, "s1", "missing")
+	if err != nil || len(ret) != 2 || ret[0] != 1 || ret[1] != -2 {
+		t.Fatalf("HExpire = %v, %v", ret, err)
+	}
+	srv.FastForward(2 * time.Minute)
+	if _, err = sessions.HGet("s1"); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("HGet expired field = %v, want ErrNotFound", err)
+	}
+	if v, err := sessions.HGet("s2"); err != nil || v != "bob" {
+		t.Fatalf("HGet = %q, %v", v, err)
+	}
+}
+
+func TestHashGetDel(t *testing.T) {
+	_, rds := newServer(t)
+	codes := redisdb.NewHashKey[string, int](rds.Key("codes"))
+	codes.HSet("a", 7)
+	if v, err := codes.HGetDel("a"); err != nil || v != 7 {
+		t.Fatalf("HGetDel = %d, %v", v, err)
+	}
+	if _, err := codes.HGetDel("a"); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("HGetDel deleted field = %v, want ErrNotFound", err)
+	}
+}
+
+// the in-memory server only has HEXPIRE, the other commands behave as on a server older than 7.4
+func TestHashFieldTTLUnsupported(t *testing.T) {
+	srv, rds := newServer(t)
+	sessions := redisdb.NewHashKey[string, string](rds.Key("sessions"))
+	if err := sessions.HSetEx("s1", "alice", time.Minute); !errors.Is(err, redisdb.ErrFieldTTLUnsupported) {
+		t.Fatalf("HSetEx = %v, want ErrFieldTTLUnsupported", err)
+	}
+	if srv.Exists("sessions") {
+		t.Fatal("HSetEx wrote the field without its ttl")
+	}
+	sessions.HSet("s1", "alice")
+	if _, err := sessions.HTTL("s1"); !errors.Is(err, redisdb.ErrFieldTTLUnsupported) {
+		t.Fatalf("HTTL = %v, want ErrFieldTTLUnsupported", err)
+	}
+	if _, err := sessions.HGetEx("s1", time.Minute); !errors.Is(err, redisdb.ErrFieldTTLUnsupported) {
+		t.Fatalf("HGetEx = %v, want ErrFieldTTLUnsupported", err)
+	}
+	if v, err := sessions.HGetEx("s1", 0); err != nil || v != "alice" {
+		t.Fatalf("HGetEx without ttl = %q, %v", v, err)
+	}
+}
@@ -1,9 +1,9 @@
 package redisdb
 
 import (
+	"context"
 	"time"
 
-	"github.com/doptime/logger"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -13,13 +13,23 @@ type ListKey[v any] struct {
 
 func NewListKey[v any](ops ...Option) *ListKey[v] {
 	ctx := &ListKey[v]{RedisKey: RedisKey[string, v]{KeyType: KeyTypeList}}
-	if err := ctx.applyOptionsAndCheck(KeyTypeList, ops...); err != nil {
-		logger.Error().Err(err).Msg("redisdb.NewListKey failed")
-		return nil
+	err := ctx.applyOptionsAndCheck(KeyTypeList, ops...)
+	if err == nil {
+		err = ctx.checkMatchableMembers()
+	}
+	if err != nil {
+		ctx.invalidate("NewListKey", err)
 	}
 	ctx.InitFunc()
 	return ctx
 }
+
+// MustNewListKey is NewListKey for strict startup: it panics if the options are invalid or the data source is not available
+func MustNewListKey[v any](ops ...Option) *ListKey[v] {
+	ctx := NewListKey[v](ops...)
+	ctx.mustBeUsable("MustNewListKey")
+	return ctx
+}
 func (ctx *ListKey[v]) HttpOn(op ListOp) (ctx1 *ListKey[v]) {
 	httpAllow(ctx.Key, uint64(op))
 	// don't register web data if it fully prepared
@@ -40,12 +50,20 @@ func (ctx *ListKey[v]) RegisterHttpInterface() {
 func (ctx *ListKey[v]) ConcatKey(fields ...interface{}) *ListKey[v] {
 	return &ListKey[v]{ctx.Duplicate(ConcatedKeys(ctx.Key, fields...), ctx.RdsName)}
 }
+func (ctx *ListKey[v]) WithCtx(c context.Context) *ListKey[v] {
+	return &ListKey[v]{ctx.withCtx(c)}
+}
+func (ctx *ListKey[v]) Primary() *ListKey[v] {
+	return ctx.WithCtx(ReadFromPrimary(ctx.Context))
+}
 func (ctx *ListKey[v]) RPush(param ...v) error {
 	vals, err := ctx.toValueStrsSlice(param...)
 	if err != nil {
 		return err
 	}
-	return ctx.Rds.RPush(ctx.Context, ctx.Key, vals...).Err()
+	return writeWithTTL(&ctx.RedisKey, ctx.Key, func(rds redis.Cmdable) *redis.IntCmd {
+		return rds.RPush(ctx.Context, ctx.Key, vals...)
+	}).Err()
 }
 
 func (ctx *ListKey[v]) LPush(param ...v) error {
@@ -53,7 +71,9 @@ func (ctx *ListKey[v]) LPush(param ...v) error {
 	if err != nil {
 		return err
 	}
-	return ctx.Rds.LPush(ctx.Context, ctx.Key, vals...).Err()
+	return writeWithTTL(&ctx.RedisKey, ctx.Key, func(rds redis.Cmdable) *redis.IntCmd {
+		return rds.LPush(ctx.Context, ctx.Key, vals...)
+	}).Err()
 }
 
 func (ctx *ListKey[v]) RPushX(param ...v) error {
@@ -61,48 +81,53 @@ func (ctx *ListKey[v]) RPushX(param ...v) error {
 	if err != nil {
 		return err
 	}
-	return ctx.Rds.RPushX(ctx.Context, ctx.Key, vals...).Err()
+	return writeWithTTL(&ctx.RedisKey, ctx.Key, func(rds redis.Cmdable) *redis.IntCmd {
+		return rds.RPushX(ctx.Context, ctx.Key, vals...)
+	}).Err()
 }
 func (ctx *ListKey[v]) LPushX(param ...v) error {
 	vals, err := ctx.toValueStrsSlice(param...)
 	if err != nil {
 		return err
 	}
-	return ctx.Rds.LPushX(ctx.Context, ctx.Key, vals...).Err()
+	return writeWithTTL(&ctx.RedisKey, ctx.Key, func(rds redis.Cmdable) *redis.IntCmd {
+		return rds.LPushX(ctx.Context, ctx.Key, vals...)
+	}).Err()
 }
 
 func (ctx *ListKey[v]) RPop() (ret v, err error) {
-	cmd := ctx.Rds.RPop(ctx.Context, ctx.Key)
+	cmd := ctx.rds().RPop(ctx.Context, ctx.Key)
 	if err = cmd.Err(); err != nil {
-		return ret, err
+		return ret, asNotFound(err)
 	}
 	data, err := cmd.Bytes()
 	if err != nil {
 		return ret, err
 	}
-	return ctx.DeserializeToValue(data)
+	return ctx.decodeValue("", data)
 }
 
 func (ctx *ListKey[v]) LPop() (ret v, err error) {
-	cmd := ctx.Rds.LPop(ctx.Context, ctx.Key)
+	cmd := ctx.rds().LPop(ctx.Context, ctx.Key)
 	if err := cmd.Err(); err != nil {
-		return ret, err
+		return ret, asNotFound(err)
 	}
 	data, err := cmd.Bytes()
 	if err != nil {
 		return ret, err
 	}
-	return ctx.DeserializeToValue(data)
+	return ctx.decodeValue("", data)
 }
 
 func (ctx *ListKey[v]) LRange(start, stop int64) ([]v, error) {
-	cmd := ctx.Rds.LRange(ctx.Context, ctx.Key, start, stop)
+	defer ctx.touch(ctx.Key)
+	cmd := ctx.reader().LRange(ctx.Context, ctx.Key, start, stop)
 	if err := cmd.Err(); err != nil {
 		return nil, err
 	}
 	values := make([]v, len(cmd.Val()))
 	for i, v := range cmd.Val() {
-		value, err := ctx.DeserializeToValue([]byte(v))
+		value, err := ctx.decodeValue("", []byte(v))
 		if err != nil {
 			return nil, err
 		}
@@ -116,7 +141,7 @@ func (ctx *ListKey[v]) LRem(count int64, param v) error {
 	if err != nil {
 		return err
 	}
-	return ctx.Rds.LRem(ctx.Context, ctx.Key, count, val).Err()
+	return ctx.rds().LRem(ctx.Context, ctx.Key, count, val).Err()
 }
 
 func (ctx *ListKey[v]) LSet(index int64, param v) error {
@@ -124,54 +149,57 @@ func (ctx *ListKey[v]) LSet(index int64, param v) error {
 	if err != nil {
 		return err
 	}
-	return ctx.Rds.LSet(ctx.Context, ctx.Key, index, val).Err()
+	return writeWithTTL(&ctx.RedisKey, ctx.Key, func(rds redis.Cmdable) *redis.StatusCmd {
+		return rds.LSet(ctx.Context, ctx.Key, index, val)
+	}).Err()
 }
 func (ctx *ListKey[v]) LIndex(ind int64) (ret v, err error) {
-	cmd := ctx.Rds.LIndex(ctx.Context, ctx.Key, ind)
+	defer ctx.touch(ctx.Key)
+	cmd := ctx.reader().LIndex(ctx.Context, ctx.Key, ind)
 	if err = cmd.Err(); err != nil {
-		return ret, err
+		return ret, asNotFound(err)
 	}
 	data, err := cmd.Bytes()
 	if err != nil {
 		return ret, err
 	}
-	return ctx.DeserializeToValue(data)
+	return ctx.decodeValue("", data)
 }
 
 func (ctx *ListKey[v]) BLPop(timeout time.Duration) (ret v, err error) {
-	cmd := ctx.Rds.BLPop(ctx.Context, timeout, ctx.Key)
+	cmd := ctx.rds().BLPop(ctx.Context, timeout, ctx.Key)
 	if err := cmd.Err(); err != nil {
-		return ret, err
+		return ret, asNotFound(err)
 	}
 	data, err := cmd.Result()
 	if err != nil {
 		return ret, err
 	}
-	return ctx.DeserializeToValue([]byte(data[1]))
+	return ctx.decodeValue("", []byte(data[1]))
 }
 
 func (ctx *ListKey[v]) BRPop(timeout time.Duration) (ret v, err error) {
-	cmd := ctx.Rds.BRPop(ctx.Context, timeout, ctx.Key)
+	cmd := ctx.rds().BRPop(ctx.Context, timeout, ctx.Key)
 	if err := cmd.Err(); err != nil {
-		return ret, err
+		return ret, asNotFound(err)
 	}
 	data, err := cmd.Result()
 	if err != nil {
 		return ret, err
 	}
-	return ctx.DeserializeToValue([]byte(data[1]))
+	return ctx.decodeValue("", []byte(data[1]))
 }
 
 func (ctx *ListKey[v]) BRPopLPush(destination string, timeout time.Duration) (ret v, err error) {
-	cmd := ctx.Rds.BRPopLPush(ctx.Context, ctx.Key, destination, timeout)
+	cmd := ctx.rds().BRPopLPush(ctx.Context, ctx.Key, destination, timeout)
 	if err := cmd.Err(); err != nil {
-		return ret, err
+		return ret, asNotFound(err)
 	}
 	data, err := cmd.Bytes()
 	if err != nil {
 		return ret, err
 	}
-	return ctx.DeserializeToValue(data)
+	return ctx.decodeValue("", data)
 }
 
 func (ctx *ListKey[v]) LInsertBefore(pivot, param v) error {
@@ -183,7 +211,9 @@ func (ctx *ListKey[v]) LInsertBefore(pivot, param v) error {
 	if err != nil {
 		return err
 	}
-	return ctx.Rds.LInsertBefore(ctx.Context, ctx.Key, pivotStr, valStr).Err()
+	return writeWithTTL(&ctx.RedisKey, ctx.Key, func(rds redis.Cmdable) *redis.IntCmd {
+		return rds.LInsertBefore(ctx.Context, ctx.Key, pivotStr, valStr)
+	}).Err()
 }
 
 func (ctx *ListKey[v]) LInsertAfter(pivot, param v) error {
@@ -195,16 +225,18 @@ func (ctx *ListKey[v]) LInsertAfter(pivot, param v) error {
 	if err != nil {
 		return err
 	}
-	return ctx.Rds.LInsertAfter(ctx.Context, ctx.Key, pivotStr, valStr).Err()
+	return writeWithTTL(&ctx.RedisKey, ctx.Key, func(rds redis.Cmdable) *redis.IntCmd {
+		return rds.LInsertAfter(ctx.Context, ctx.Key, pivotStr, valStr)
+	}).Err()
 }
 func (ctx *ListKey[v]) Sort(sort *redis.Sort) ([]v, error) {
-	cmd := ctx.Rds.Sort(ctx.Context, ctx.Key, sort)
+	cmd := ctx.rds().Sort(ctx.Context, ctx.Key, sort)
 	if err := cmd.Err(); err != nil {
 		return nil, err
 	}
 	values := make([]v, len(cmd.Val()))
 	for i, v := range cmd.Val() {
-		value, err := ctx.DeserializeToValue([]byte(v))
+		value, err := ctx.decodeValue("", []byte(v))
 		if err != nil {
 			return nil, err
 		}
@@ -214,9 +246,10 @@ func (ctx *ListKey[v]) Sort(sort *redis.Sort) ([]v, error) {
 }
 
 func (ctx *ListKey[v]) LTrim(start, stop int64) error {
-	return ctx.Rds.LTrim(ctx.Context, ctx.Key, start, stop).Err()
+	return ctx.rds().LTrim(ctx.Context, ctx.Key, start, stop).Err()
 }
 
 func (ctx *ListKey[v]) LLen() (int64, error) {
-	return ctx.Rds.LLen(ctx.Context, ctx.Key).Result()
+	defer ctx.touch(ctx.Key)
+	return ctx.reader().LLen(ctx.Context, ctx.Key).Result()
 }
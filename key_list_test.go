@@ -0,0 +1,52 @@
+package redisdb_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/doptime/redisdb"
+)
+
+func TestListKeyPushPop(t *testing.T) {
+	_, rds := newServer(t)
+	jobs := redisdb.NewListKey[*User](rds.Key("jobs"))
+	if err := jobs.RPush(&User{ID: "1"}, &User{ID: "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := jobs.LPush(&User{ID: "0"}); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := jobs.LLen(); n != 3 {
+		t.Fatalf("LLen = %d", n)
+	}
+	all, err := jobs.LRange(0, -1)
+	if err != nil || len(all) != 3 || all[0].ID != "0" || all[2].ID != "2" {
+		t.Fatalf("LRange = %v, %v", all, err)
+	}
+	if u, err := jobs.LIndex(1); err != nil || u.ID != "1" {
+		t.Fatalf("LIndex = %+v, %v", u, err)
+	}
+	if u, err := jobs.RPop(); err != nil || u.ID != "2" {
+		t.Fatalf("RPop = %+v, %v", u, err)
+	}
+	if u, err := jobs.LPop(); err != nil || u.ID != "0" {
+		t.Fatalf("LPop = %+v, %v", u, err)
+	}
+	jobs.LPop()
+	if _, err = jobs.LPop(); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("LPop empty: %v, want ErrNotFound", err)
+	}
+}
+
+func TestListKeyBlockingPop(t *testing.T) {
+	_, rds := newServer(t)
+	jobs := redisdb.NewListKey[string](rds.Key("jobs"))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		jobs.RPush("job")
+	}()
+	if v, err := jobs.BLPop(time.Second); err != nil || v != "job" {
+		t.Fatalf("BLPop = %q, %v", v, err)
+	}
+}
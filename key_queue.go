@@ -0,0 +1,436 @@
+package redisdb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/doptime/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+// QueueKey is a reliable work queue on redis lists. Push adds jobs to the list Key; a worker moves each job it takes
+// (BLMOVE) to its own processing list "{Key}:processing:<worker>" until it acks it. workers heartbeat in the hash "{Key}:workers";
+// Reap puts back the jobs of workers that stopped heartbeating for Visibility. a failed job is retried, after Backoff
+// in the sorted set "{Key}:delayed", and goes to the list "{Key}:dead" once it failed MaxAttempts times.
+// the keys share the hash slot of Key, for cluster
+type QueueKey[v any] struct {
+	RedisKey[string, v]
+	// Visibility is how long a worker can go without heartbeat before its jobs are requeued, DefaultVisibility if not set
+	Visibility time.Duration
+	// MaxAttempts is the number of failed attempts after which a job goes to the dead letter list; 0 retries forever
+	MaxAttempts int
+	// Backoff is how long a job waits before its next attempt, given the number of failed attempts; nil retries at once
+	Backoff func(attempts int) time.Duration
+}
+
+// DefaultVisibility is the Visibility of a QueueKey that doesn't set one
+const DefaultVisibility = 30 * time.Second
+
+// Job is a job taken from a QueueKey
+type Job[v any] struct {
+	ID string
+	// Attempts is the number of failed attempts before this one
+	Attempts int
+	Value    v
+
+	raw, worker string
+}
+
+// NewQueueKey creates a QueueKey on the list of the key option
+func NewQueueKey[v any](ops ...Option) *QueueKey[v] {
+	ctx := &QueueKey[v]{RedisKey: RedisKey[string, v]{KeyType: KeyTypeList}, Visibility: DefaultVisibility}
+	if err := ctx.applyOptionsAndCheck(KeyTypeList, ops...); err != nil {
+		ctx.invalidate("NewQueueKey", err)
+	}
+	ctx.InitFunc()
+	return ctx
+}
+
+// MustNewQueueKey is NewQueueKey for strict startup: it panics if the options are invalid or the data source is not available
+func MustNewQueueKey[v any](ops ...Option) *QueueKey[v] {
+	ctx := NewQueueKey[v](ops...)
+	ctx.mustBeUsable("MustNewQueueKey")
+	return ctx
+}
+
+func (ctx *QueueKey[v]) WithCtx(c context.Context) *QueueKey[v] {
+	return &QueueKey[v]{ctx.withCtx(c), ctx.Visibility, ctx.MaxAttempts, ctx.Backoff}
+}
+
+// ExponentialBackoff waits base, 2*base, 4*base... after each failed attempt, at most max
+func ExponentialBackoff(base, max time.Duration) func(attempts int) time.Duration {
+	return func(attempts int) time.Duration {
+		d := base
+		for i := 1; i < attempts && d < max; i++ {
+			d *= 2
+		}
+		return min(d, max)
+	}
+}
+
+func (ctx *QueueKey[v]) visibility() time.Duration {
+	if ctx.Visibility <= 0 {
+		return DefaultVisibility
+	}
+	return ctx.Visibility
+}
+
+func (ctx *QueueKey[v]) processingKey(worker string) string {
+	return "{" + ctx.Key + "}:processing:" + worker
+}
+func (ctx *QueueKey[v]) workersKey() string { return "{" + ctx.Key + "}:workers" }
+func (ctx *QueueKey[v]) delayedKey() string { return "{" + ctx.Key + "}:delayed" }
+func (ctx *QueueKey[v]) deadKey() string    { return "{" + ctx.Key + "}:dead" }
+
+// a job is stored as "<id>|<failed attempts>|<encoded value>", so scripts can count attempts without decoding it
+func (ctx *QueueKey[v]) encodeJob(id string, attempts int, value v) (string, error) {
+	if ctx.UseModer {
+		ApplyModifiers(&value)
+	}
+	valStr, err := ctx.SerializeValue(value)
+	if err != nil {
+		return "", err
+	}
+	return id + "|" + strconv.Itoa(attempts) + "|" + valStr, nil
+}
+
+func (ctx *QueueKey[v]) decodeJob(raw, worker string) (job Job[v], err error) {
+	job.raw, job.worker = raw, worker
+	id, rest, ok1 := strings.Cut(raw, "|")
+	attempts, payload, ok2 := strings.Cut(rest, "|")
+	if !ok1 || !ok2 {
+		return job, &DecodeError{Key: ctx.Key, Raw: []byte(raw), Err: fmt.Errorf("not a queue job")}
+	}
+	job.ID = id
+	if job.Attempts, err = strconv.Atoi(attempts); err != nil {
+		return job, &DecodeError{Key: ctx.Key, Field: id, Raw: []byte(raw), Err: err}
+	}
+	job.Value, err = ctx.decodeValue(id, []byte(payload))
+	return job, err
+}
+
+// Push adds values to the queue, as jobs with new IDs. returns the IDs
+func (ctx *QueueKey[v]) Push(values ...v) ([]string, error) {
+	ids := make([]string, len(values))
+	jobs := make([]interface{}, len(values))
+	for i, value := range values {
+		ids[i] = NanoId(16)
+		raw, err := ctx.encodeJob(ids[i], 0, value)
+		if err != nil {
+			return nil, err
+		}
+		jobs[i] = raw
+	}
+	if len(jobs) == 0 {
+		return ids, nil
+	}
+	return ids, ctx.rds().LPush(ctx.Context, ctx.Key, jobs...).Err()
+}
+
+// Len returns the number of jobs waiting, not counting the delayed and running ones
+func (ctx *QueueKey[v]) Len() (int64, error) {
+	return ctx.reader().LLen(ctx.Context, ctx.Key).Result()
+}
+
+// queueLib is shared by the queue scripts. ARGV[1] is MaxAttempts, ARGV[3...] the backoff in ms after 1, 2... failed attempts
+// the scripts read TIME before writing, which needs effects replication before redis 7
+const queueLib = `
+redis.replicate_commands()
+local function now()
+	local t = redis.call('TIME')
+	return tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+end
+-- retry puts the job back after a failed attempt: KEYS[1] pending, KEYS[2] delayed, KEYS[3] dead
+local function retry(job, t)
+	local id, n, payload = string.match(job, '^([^|]*)|(%d+)|(.*)$')
+	if not id then
+		redis.call('LPUSH', KEYS[3], job)
+		return
+	end
+	n = tonumber(n) + 1
+	job = id .. '|' .. n .. '|' .. payload
+	local max = tonumber(ARGV[1])
+	if max > 0 and n >= max then
+		redis.call('LPUSH', KEYS[3], job)
+		return
+	end
+	local delay = 0
+	if #ARGV >= 3 then delay = tonumber(ARGV[math.min(2 + n, #ARGV)]) end
+	if delay > 0 then
+		redis.call('ZADD', KEYS[2], t + delay, job)
+	else
+		redis.call('LPUSH', KEYS[1], job)
+	end
+end
+`
+
+// nackScript fails the job ARGV[2] of the processing list KEYS[4]. returns 0 if it isn't there
+var nackScript = redis.NewScript(queueLib + `
+if redis.call('LREM', KEYS[4], 1, ARGV[2]) == 0 then return 0 end
+retry(ARGV[2], now())
+return 1
+`)
+
+// reapScript fails the jobs of the processing list KEYS[5] if the heartbeat of its worker ARGV[2] in KEYS[4] expired.
+// returns the number of jobs failed
+var reapScript = redis.NewScript(queueLib + `
+local t = now()
+local deadline = redis.call('HGET', KEYS[4], ARGV[2])
+if not deadline or tonumber(deadline) >= t then return 0 end
+local n = 0
+local job = redis.call('RPOP', KEYS[5])
+while job do
+	retry(job, t)
+	n = n + 1
+	job = redis.call('RPOP', KEYS[5])
+end
+redis.call('HDEL', KEYS[4], ARGV[2])
+return n
+`)
+
+// promoteScript moves the due jobs of the delayed set KEYS[2] to the pending list KEYS[1]. returns the number moved
+var promoteScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+local due = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000), 'LIMIT', 0, 1000)
+for _, job in ipairs(due) do
+	redis.call('LPUSH', KEYS[1], job)
+end
+if #due > 0 then redis.call('ZREM', KEYS[2], unpack(due)) end
+return #due
+`)
+
+// requeueDeadScript moves the oldest job of the dead letter list KEYS[1] to the pending list KEYS[2] with its attempts reset.
+// returns 0 if the dead letter list is empty
+var requeueDeadScript = redis.NewScript(`
+local job = redis.call('RPOP', KEYS[1])
+if not job then return 0 end
+local id, payload = string.match(job, '^([^|]*)|%d+|(.*)$')
+if id then job = id .. '|0|' .. payload end
+redis.call('LPUSH', KEYS[2], job)
+return 1
+`)
+
+// heartbeatScript sets the deadline of worker ARGV[1] in KEYS[1] to ARGV[2] ms from now
+var heartbeatScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+redis.call('HSET', KEYS[1], ARGV[1], tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000) + tonumber(ARGV[2]))
+return 1
+`)
+
+// retryArgs are ARGV[1] and ARGV[3...] of queueLib
+func (ctx *QueueKey[v]) retryArgs(arg2 string) []interface{} {
+	args := []interface{}{ctx.MaxAttempts, arg2}
+	if ctx.Backoff == nil {
+		return args
+	}
+	n := ctx.MaxAttempts
+	if n <= 0 {
+		n = 32
+	}
+	for attempts := 1; attempts <= n; attempts++ {
+		args = append(args, ctx.Backoff(attempts).Milliseconds())
+	}
+	return args
+}
+
+// Heartbeat keeps the jobs of worker from being requeued for Visibility
+func (ctx *QueueKey[v]) Heartbeat(worker string) error {
+	return heartbeatScript.Run(ctx.Context, ctx.rds(), []string{ctx.workersKey()}, worker, ctx.visibility().Milliseconds()).Err()
+}
+
+// Pop takes the oldest job for worker, waiting up to timeout (rounded up to 1s, 0 waits forever). returns ErrNotFound if there is none.
+// worker must Ack or Nack it, and Heartbeat more often than Visibility while it runs. a job whose value can't be decoded
+// goes to the dead letter list and Pop returns its *DecodeError
+func (ctx *QueueKey[v]) Pop(worker string, timeout time.Duration) (job Job[v], err error) {
+	if err = ctx.Heartbeat(worker); err != nil {
+		return job, err
+	}
+	return ctx.take(worker, timeout)
+}
+
+// take moves the oldest job to the processing list of worker
+func (ctx *QueueKey[v]) take(worker string, timeout time.Duration) (job Job[v], err error) {
+	raw, err := ctx.rds().BLMove(ctx.Context, ctx.Key, ctx.processingKey(worker), "RIGHT", "LEFT", timeout).Result()
+	if err != nil {
+		return job, asNotFound(err)
+	}
+	if job, err = ctx.decodeJob(raw, worker); err != nil {
+		_, deadErr := ctx.rds().TxPipelined(ctx.Context, func(pipe redis.Pipeliner) error {
+			pipe.LRem(ctx.Context, ctx.processingKey(worker), 1, raw)
+			pipe.LPush(ctx.Context, ctx.deadKey(), raw)
+			return nil
+		})
+		if deadErr != nil {
+			return job, deadErr
+		}
+	}
+	return job, err
+}
+
+// Ack completes job. returns ErrNotFound if it is no longer running on its worker, requeued by Reap
+func (ctx *QueueKey[v]) Ack(job Job[v]) error {
+	n, err := ctx.rds().LRem(ctx.Context, ctx.processingKey(job.worker), 1, job.raw).Result()
+	if err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Nack fails job: it is retried after Backoff, or goes to the dead letter list after MaxAttempts.
+// returns ErrNotFound if it is no longer running on its worker
+func (ctx *QueueKey[v]) Nack(job Job[v]) error {
+	keys := []string{ctx.Key, ctx.delayedKey(), ctx.deadKey(), ctx.processingKey(job.worker)}
+	n, err := nackScript.Run(ctx.Context, ctx.rds(), keys, ctx.retryArgs(job.raw)...).Int()
+	if err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Reap requeues, as failed attempts, the jobs of workers without heartbeat for Visibility, and moves the delayed jobs
+// that are due back to the queue. returns the number of jobs requeued. Consume runs it, any worker can
+func (ctx *QueueKey[v]) Reap() (n int64, err error) {
+	workers, err := ctx.rds().HKeys(ctx.Context, ctx.workersKey()).Result()
+	if err != nil {
+		return 0, err
+	}
+	// one worker per script, so every key it touches is declared
+	for _, worker := range workers {
+		keys := []string{ctx.Key, ctx.delayedKey(), ctx.deadKey(), ctx.workersKey(), ctx.processingKey(worker)}
+		reaped, err := reapScript.Run(ctx.Context, ctx.rds(), keys, ctx.retryArgs(worker)...).Int64()
+		if err != nil {
+			return n, err
+		}
+		n += reaped
+	}
+	return n, ctx.promote()
+}
+
+// promote moves the delayed jobs that are due back to the queue
+func (ctx *QueueKey[v]) promote() error {
+	return promoteScript.Run(ctx.Context, ctx.rds(), []string{ctx.Key, ctx.delayedKey()}).Err()
+}
+
+// DeadJobs returns the jobs of the dead letter list from start to stop, newest first
+func (ctx *QueueKey[v]) DeadJobs(start, stop int64) ([]Job[v], error) {
+	raws, err := ctx.reader().LRange(ctx.Context, ctx.deadKey(), start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]Job[v], 0, len(raws))
+	for _, raw := range raws {
+		job, err := ctx.decodeJob(raw, "")
+		if err != nil {
+			return jobs, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// RequeueDead moves the dead letter jobs back to the queue with their attempts reset, one atomic move per job. returns the number moved
+func (ctx *QueueKey[v]) RequeueDead() (n int64, err error) {
+	for {
+		moved, err := requeueDeadScript.Run(ctx.Context, ctx.rds(), []string{ctx.deadKey(), ctx.Key}).Int()
+		if err != nil || moved == 0 {
+			return n, err
+		}
+		n++
+	}
+}
+
+// Consume runs concurrency handlers as worker until the context of the key (WithCtx) is done, heartbeating and reaping
+// in the background. a job is acked if handler returns nil and nacked if it returns an error or panics.
+// delayed jobs are moved back to the queue within 1s of being due.
+// on shutdown it waits for the running handlers and returns nil, noticing it within 1s
+func (ctx *QueueKey[v]) Consume(worker string, concurrency int, handler func(Job[v]) error) error {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	done := ctx.Context.Done()
+	// jobs taken before the shutdown still get acked
+	q := ctx.WithCtx(context.WithoutCancel(ctx.Context))
+	// blocking commands wait at least 1s
+	block := time.Second
+	if err := q.Heartbeat(worker); err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				job, err := q.take(worker, block)
+				if err == ErrNotFound {
+					continue
+				}
+				if err != nil {
+					logger.Error().Err(err).Str("key", q.Key).Str("worker", worker).Msg("redisdb: QueueKey.Consume pop failed")
+					time.Sleep(block)
+					continue
+				}
+				q.handle(job, handler)
+			}
+		}()
+	}
+	ticker := time.NewTicker(q.visibility() / 3)
+	defer ticker.Stop()
+	// the delayed set is polled on its own, a short Backoff shouldn't wait for the next reap
+	promoter := time.NewTicker(block)
+	defer promoter.Stop()
+	stopped := make(chan struct{})
+	go func() { wg.Wait(); close(stopped) }()
+	if _, err := q.Reap(); err != nil {
+		logger.Error().Err(err).Str("key", q.Key).Msg("redisdb: QueueKey.Consume reap failed")
+	}
+	for {
+		select {
+		case <-stopped:
+			q.rds().HDel(q.Context, q.workersKey(), worker)
+			return nil
+		case <-promoter.C:
+			if err := q.promote(); err != nil {
+				logger.Error().Err(err).Str("key", q.Key).Msg("redisdb: QueueKey.Consume moving due jobs failed")
+			}
+		case <-ticker.C:
+			if err := q.Heartbeat(worker); err != nil {
+				logger.Error().Err(err).Str("key", q.Key).Str("worker", worker).Msg("redisdb: QueueKey.Consume heartbeat failed")
+			}
+			if _, err := q.Reap(); err != nil {
+				logger.Error().Err(err).Str("key", q.Key).Msg("redisdb: QueueKey.Consume reap failed")
+			}
+		}
+	}
+}
+
+func (ctx *QueueKey[v]) handle(job Job[v], handler func(Job[v]) error) {
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return handler(job)
+	}()
+	if err == nil {
+		err = ctx.Ack(job)
+	} else {
+		logger.Info().Err(err).Str("key", ctx.Key).Str("id", job.ID).Int("attempts", job.Attempts+1).Msg("redisdb: QueueKey.Consume handler failed")
+		err = ctx.Nack(job)
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("key", ctx.Key).Str("id", job.ID).Msg("redisdb: QueueKey.Consume ack failed")
+	}
+}
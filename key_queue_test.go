@@ -0,0 +1,176 @@
+package redisdb_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doptime/redisdb"
+)
+
+func TestQueueKeyAckNack(t *testing.T) {
+	srv, rds := newServer(t)
+	q := redisdb.NewQueueKey[*Event](rds.Key("jobs"))
+	q.MaxAttempts = 2
+	ids, err := q.Push(&Event{User: "a", Kind: "job"}, &Event{User: "b", Kind: "job"})
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("Push = %v, %v", ids, err)
+	}
+	job, err := q.Pop("w1", time.Second)
+	if err != nil || job.ID != ids[0] || job.Value.User != "a" || job.Attempts != 0 {
+		t.Fatalf("Pop = %+v, %v, want the oldest job", job, err)
+	}
+	if n, _ := srv.List("{jobs}:processing:w1"); len(n) != 1 {
+		t.Fatalf("processing list = %v", n)
+	}
+	if err = q.Ack(job); err != nil {
+		t.Fatal(err)
+	}
+	if err = q.Ack(job); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("Ack twice = %v, want ErrNotFound", err)
+	}
+
+	// b fails twice and goes to the dead letter list
+	for attempt := 0; attempt < 2; attempt++ {
+		job, err = q.Pop("w1", time.Second)
+		if err != nil || job.Value.User != "b" || job.Attempts != attempt {
+			t.Fatalf("Pop attempt %d = %+v, %v", attempt, job, err)
+		}
+		if err = q.Nack(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, _ := q.Len(); n != 0 {
+		t.Fatalf("Len after MaxAttempts = %d", n)
+	}
+	dead, err := q.DeadJobs(0, -1)
+	if err != nil || len(dead) != 1 || dead[0].ID != ids[1] || dead[0].Attempts != 2 {
+		t.Fatalf("DeadJobs = %+v, %v", dead, err)
+	}
+	if n, err := q.RequeueDead(); err != nil || n != 1 {
+		t.Fatalf("RequeueDead = %d, %v", n, err)
+	}
+	if job, _ = q.Pop("w1", time.Second); job.ID != ids[1] || job.Attempts != 0 {
+		t.Fatalf("Pop after RequeueDead = %+v", job)
+	}
+}
+
+func TestQueueKeyBackoff(t *testing.T) {
+	_, rds := newServer(t)
+	q := redisdb.NewQueueKey[string](rds.Key("jobs"))
+	q.Backoff = redisdb.ExponentialBackoff(50*time.Millisecond, time.Second)
+	q.Push("x")
+	job, _ := q.Pop("w1", time.Second)
+	q.Nack(job)
+	if n, _ := q.Reap(); n != 0 {
+		t.Fatalf("Reap = %d, want nothing requeued from a live worker", n)
+	}
+	if n, _ := q.Len(); n != 0 {
+		t.Fatal("job requeued before its backoff")
+	}
+	time.Sleep(60 * time.Millisecond)
+	q.Reap()
+	if job, err := q.Pop("w1", time.Second); err != nil || job.Attempts != 1 {
+		t.Fatalf("Pop after backoff = %+v, %v", job, err)
+	}
+}
+
+func TestQueueKeyReap(t *testing.T) {
+	_, rds := newServer(t)
+	q := redisdb.NewQueueKey[string](rds.Key("jobs"))
+	q.Visibility = 50 * time.Millisecond
+	q.Push("x")
+	// w1 takes the job and dies
+	abandoned, _ := q.Pop("w1", time.Second)
+	time.Sleep(80 * time.Millisecond)
+	if n, err := q.Reap(); err != nil || n != 1 {
+		t.Fatalf("Reap = %d, %v", n, err)
+	}
+	job, err := q.Pop("w2", time.Second)
+	if err != nil || job.ID != abandoned.ID || job.Attempts != 1 {
+		t.Fatalf("Pop of the requeued job = %+v, %v", job, err)
+	}
+	if err = q.Ack(abandoned); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("Ack of a requeued job = %v, want ErrNotFound", err)
+	}
+}
+
+func TestQueueKeyConsume(t *testing.T) {
+	_, rds := newServer(t)
+	q := redisdb.NewQueueKey[string](rds.Key("jobs"))
+	q.Visibility = 300 * time.Millisecond
+	q.Push("a", "b", "c", "d")
+	c, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	handled, failed := map[string]int{}, false
+	done := make(chan error)
+	go func() {
+		done <- q.WithCtx(c).Consume("w1", 2, func(job redisdb.Job[string]) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if job.Value == "c" && !failed {
+				failed = true
+				panic("transient")
+			}
+			if handled[job.Value]++; len(handled) == 4 {
+				cancel()
+			}
+			return nil
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		cancel()
+		t.Fatalf("Consume didn't handle every job: %v", handled)
+	}
+	for value, n := range handled {
+		if n != 1 {
+			t.Fatalf("%s handled %d times", value, n)
+		}
+	}
+	if n, _ := q.Len(); n != 0 {
+		t.Fatalf("Len after Consume = %d", n)
+	}
+}
+
+func TestQueueKeyConsumeBackoff(t *testing.T) {
+	_, rds := newServer(t)
+	q := redisdb.NewQueueKey[string](rds.Key("jobs"))
+	// not set: DefaultVisibility, reaping every 10s
+	q.Visibility = 0
+	q.Backoff = redisdb.ExponentialBackoff(50*time.Millisecond, time.Second)
+	q.Push("a")
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	attempts := make(chan int, 2)
+	done := make(chan error)
+	go func() {
+		done <- q.WithCtx(c).Consume("w1", 1, func(job redisdb.Job[string]) error {
+			attempts <- job.Attempts
+			if job.Attempts == 0 {
+				return errors.New("transient")
+			}
+			return nil
+		})
+	}()
+	for want := 0; want < 2; want++ {
+		select {
+		case got := <-attempts:
+			if got != want {
+				t.Fatalf("attempts = %d, want %d", got, want)
+			}
+		case <-time.After(4 * time.Second):
+			t.Fatalf("attempt %d not retried before the next reap", want)
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,7 +1,10 @@
 package redisdb
 
 import (
-	"github.com/doptime/logger"
+	"context"
+	"iter"
+
+	"github.com/redis/go-redis/v9"
 )
 
 type SetKey[k comparable, v any] struct {
@@ -10,16 +13,32 @@ type SetKey[k comparable, v any] struct {
 
 func NewSetKey[k comparable, v any](ops ...Option) *SetKey[k, v] {
 	ctx := &SetKey[k, v]{RedisKey: RedisKey[k, v]{KeyType: KeyTypeSet}}
-	if err := ctx.applyOptionsAndCheck(KeyTypeSet, ops...); err != nil {
-		logger.Error().Err(err).Msg("redisdb.NewSetKey failed")
-		return nil
+	err := ctx.applyOptionsAndCheck(KeyTypeSet, ops...)
+	if err == nil {
+		err = ctx.checkMatchableMembers()
+	}
+	if err != nil {
+		ctx.invalidate("NewSetKey", err)
 	}
 	ctx.InitFunc()
 	return ctx
 }
+
+// MustNewSetKey is NewSetKey for strict startup: it panics if the options are invalid or the data source is not available
+func MustNewSetKey[k comparable, v any](ops ...Option) *SetKey[k, v] {
+	ctx := NewSetKey[k, v](ops...)
+	ctx.mustBeUsable("MustNewSetKey")
+	return ctx
+}
 func (ctx *SetKey[k, v]) ConcatKey(fields ...interface{}) *SetKey[k, v] {
 	return &SetKey[k, v]{ctx.Duplicate(ConcatedKeys(ctx.Key, fields...), ctx.RdsName)}
 }
+func (ctx *SetKey[k, v]) WithCtx(c context.Context) *SetKey[k, v] {
+	return &SetKey[k, v]{ctx.withCtx(c)}
+}
+func (ctx *SetKey[k, v]) Primary() *SetKey[k, v] {
+	return ctx.WithCtx(ReadFromPrimary(ctx.Context))
+}
 func (ctx *SetKey[k, v]) HttpOn(op SetOp) (ctx1 *SetKey[k, v]) {
 	httpAllow(ctx.Key, uint64(op))
 	// don't register web data if it fully prepared
@@ -49,11 +68,14 @@ func (ctx *SetKey[k, v]) SAdd(members ...v) (err error) {
 		vals[i] = valStr
 	}
 	// 调用 Redis SAdd (接受 ...interface{})
-	return ctx.Rds.SAdd(ctx.Context, ctx.Key, vals...).Err()
+	return writeWithTTL(&ctx.RedisKey, ctx.Key, func(rds redis.Cmdable) *redis.IntCmd {
+		return rds.SAdd(ctx.Context, ctx.Key, vals...)
+	}).Err()
 }
 
 func (ctx *SetKey[k, v]) SCard() (int64, error) {
-	return ctx.Rds.SCard(ctx.Context, ctx.Key).Result()
+	defer ctx.touch(ctx.Key)
+	return ctx.reader().SCard(ctx.Context, ctx.Key).Result()
 }
 
 // SRem: 支持批量删除
@@ -66,19 +88,21 @@ func (ctx *SetKey[k, v]) SRem(members ...v) error {
 		}
 		vals[i] = valStr
 	}
-	return ctx.Rds.SRem(ctx.Context, ctx.Key, vals...).Err()
+	return ctx.rds().SRem(ctx.Context, ctx.Key, vals...).Err()
 }
 
 func (ctx *SetKey[k, v]) SIsMember(param v) (bool, error) {
+	defer ctx.touch(ctx.Key)
 	valStr, err := ctx.SerializeValue(param)
 	if err != nil {
 		return false, err
 	}
-	return ctx.Rds.SIsMember(ctx.Context, ctx.Key, valStr).Result()
+	return ctx.reader().SIsMember(ctx.Context, ctx.Key, valStr).Result()
 }
 
 func (ctx *SetKey[k, v]) SMembers() ([]v, error) {
-	cmd := ctx.Rds.SMembers(ctx.Context, ctx.Key)
+	defer ctx.touch(ctx.Key)
+	cmd := ctx.reader().SMembers(ctx.Context, ctx.Key)
 	if err := cmd.Err(); err != nil {
 		return nil, err
 	}
@@ -86,7 +110,8 @@ func (ctx *SetKey[k, v]) SMembers() ([]v, error) {
 }
 
 func (ctx *SetKey[k, v]) SScan(cursor uint64, match string, count int64) ([]v, uint64, error) {
-	cmd := ctx.Rds.SScan(ctx.Context, ctx.Key, cursor, match, count)
+	defer ctx.touch(ctx.Key)
+	cmd := ctx.reader().SScan(ctx.Context, ctx.Key, cursor, match, count)
 	if err := cmd.Err(); err != nil {
 		return nil, 0, err
 	}
@@ -100,3 +125,26 @@ func (ctx *SetKey[k, v]) SScan(cursor uint64, match string, count int64) ([]v, u
 	}
 	return values, cursor, nil
 }
+
+// Members iterates the set with SSCAN, page by page
+func (ctx *SetKey[k, v]) Members() iter.Seq2[v, error] {
+	return func(yield func(v, error) bool) {
+		var cursor uint64
+		for {
+			members, next, err := ctx.reader().SScan(ctx.Context, ctx.Key, cursor, "", ScanPageSize).Result()
+			if err != nil {
+				var zero v
+				yield(zero, err)
+				return
+			}
+			for _, member := range members {
+				if !yield(ctx.decodeValue("", []byte(member))) {
+					return
+				}
+			}
+			if cursor = next; cursor == 0 {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package redisdb_test
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/doptime/redisdb"
+)
+
+func TestSetKey(t *testing.T) {
+	_, rds := newServer(t)
+	tags := redisdb.NewSetKey[string, string](rds.Key("tags"))
+	if err := tags.SAdd("go", "redis", "go"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := tags.SCard(); n != 2 {
+		t.Fatalf("SCard = %d", n)
+	}
+	if ok, _ := tags.SIsMember("redis"); !ok {
+		t.Fatal("SIsMember = false")
+	}
+	if err := tags.SRem("redis"); err != nil {
+		t.Fatal(err)
+	}
+	members, err := tags.SMembers()
+	if err != nil || len(members) != 1 || members[0] != "go" {
+		t.Fatalf("SMembers = %v, %v", members, err)
+	}
+}
+
+func TestSetKeyMembersIter(t *testing.T) {
+	_, rds := newServer(t)
+	ids := redisdb.NewSetKey[string, int](rds.Key("ids"))
+	ids.SAdd(3, 1, 2)
+	var got []int
+	for id, err := range ids.Members() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, id)
+	}
+	sort.Ints(got)
+	if len(got) != 3 || got[0] != 1 || got[2] != 3 {
+		t.Fatalf("Members = %v", got)
+	}
+}
+
+func TestSetKeyRejectsEncryptedMembers(t *testing.T) {
+	_, rds := newServer(t)
+	type Card struct {
+		Number string `encrypt:"aes-gcm"`
+	}
+	cards := redisdb.NewSetKey[string, *Card](rds.Key("cards"))
+	if !errors.Is(cards.Err(), redisdb.ErrEncryptedMember) {
+		t.Fatalf("Err = %v, want ErrEncryptedMember", cards.Err())
+	}
+	if _, err := cards.SMembers(); !errors.Is(err, redisdb.ErrEncryptedMember) {
+		t.Fatalf("SMembers = %v, want ErrEncryptedMember", err)
+	}
+}
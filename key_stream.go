@@ -1,6 +1,10 @@
 package redisdb
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/doptime/logger"
 	"github.com/redis/go-redis/v9"
 )
@@ -12,16 +16,28 @@ type StreamKey[k comparable, v any] struct {
 func NewStreamKey[k comparable, v any](ops ...Option) *StreamKey[k, v] {
 	ctx := &StreamKey[k, v]{RedisKey: RedisKey[k, v]{KeyType: KeyTypeStream}}
 	if err := ctx.applyOptionsAndCheck(KeyTypeStream, ops...); err != nil {
-		logger.Error().Err(err).Msg("redisdb.NewStreamKey failed")
-		return nil
+		ctx.invalidate("NewStreamKey", err)
 	}
 	ctx.InitFunc()
 	return ctx
 }
 
+// MustNewStreamKey is NewStreamKey for strict startup: it panics if the options are invalid or the data source is not available
+func MustNewStreamKey[k comparable, v any](ops ...Option) *StreamKey[k, v] {
+	ctx := NewStreamKey[k, v](ops...)
+	ctx.mustBeUsable("MustNewStreamKey")
+	return ctx
+}
+
 func (ctx *StreamKey[k, v]) ConcatKey(fields ...interface{}) *StreamKey[k, v] {
 	return &StreamKey[k, v]{ctx.RedisKey.Duplicate(ConcatedKeys(ctx.Key, fields...), ctx.RdsName)}
 }
+func (ctx *StreamKey[k, v]) WithCtx(c context.Context) *StreamKey[k, v] {
+	return &StreamKey[k, v]{ctx.withCtx(c)}
+}
+func (ctx *StreamKey[k, v]) Primary() *StreamKey[k, v] {
+	return ctx.WithCtx(ReadFromPrimary(ctx.Context))
+}
 
 func (ctx *StreamKey[k, v]) HttpOn(op StreamOp) (ctx1 *StreamKey[k, v]) {
 	httpAllow(ctx.Key, uint64(op))
@@ -43,34 +59,62 @@ func (ctx *StreamKey[k, v]) RegisterHttpInterface() {
 
 // --- 新增的核心操作方法 ---
 
+// XAdd appends an entry. unless args trims itself, the stream is trimmed as configured by WithRetention / WithMaxLen.
+// args is not modified
 func (ctx *StreamKey[k, v]) XAdd(args *redis.XAddArgs) (string, error) {
 	// 确保 Stream Key 是正确的 (Context Key)
-	args.Stream = ctx.Key
-	return ctx.Rds.XAdd(ctx.Context, args).Result()
+	a := *args
+	a.Stream = ctx.Key
+	trimMaxLen := false
+	if a.MaxLen <= 0 && a.MinID == "" && (ctx.StreamRetention > 0 || ctx.StreamMaxLen > 0) {
+		// XADD takes a single trim strategy: the retention, then the length cap separately
+		a.Approx = true
+		if ctx.StreamRetention > 0 {
+			a.MinID = retentionMinID(ctx.StreamRetention)
+			trimMaxLen = ctx.StreamMaxLen > 0
+		} else {
+			a.MaxLen = ctx.StreamMaxLen
+		}
+	}
+	id, err := writeWithTTL(&ctx.RedisKey, ctx.Key, func(rds redis.Cmdable) *redis.StringCmd {
+		return rds.XAdd(ctx.Context, &a)
+	}).Result()
+	if err == nil && trimMaxLen {
+		// the entry is added: a failed trim is caught up by the next XAdd, returning it would invite a duplicate retry
+		if trimErr := ctx.rds().XTrimMaxLenApprox(ctx.Context, ctx.Key, ctx.StreamMaxLen, 0).Err(); trimErr != nil {
+			logger.Error().Err(trimErr).Str("key", ctx.Key).Msg("redisdb: XAdd trim failed")
+		}
+	}
+	return id, err
 }
 
 func (ctx *StreamKey[k, v]) XDel(ids ...string) (int64, error) {
-	return ctx.Rds.XDel(ctx.Context, ctx.Key, ids...).Result()
+	return ctx.rds().XDel(ctx.Context, ctx.Key, ids...).Result()
 }
 
 func (ctx *StreamKey[k, v]) XLen() (int64, error) {
-	return ctx.Rds.XLen(ctx.Context, ctx.Key).Result()
+	defer ctx.touch(ctx.Key)
+	return ctx.reader().XLen(ctx.Context, ctx.Key).Result()
 }
 
 func (ctx *StreamKey[k, v]) XRange(start, stop string) ([]redis.XMessage, error) {
-	return ctx.Rds.XRange(ctx.Context, ctx.Key, start, stop).Result()
+	defer ctx.touch(ctx.Key)
+	return ctx.reader().XRange(ctx.Context, ctx.Key, start, stop).Result()
 }
 
 func (ctx *StreamKey[k, v]) XRangeN(start, stop string, count int64) ([]redis.XMessage, error) {
-	return ctx.Rds.XRangeN(ctx.Context, ctx.Key, start, stop, count).Result()
+	defer ctx.touch(ctx.Key)
+	return ctx.reader().XRangeN(ctx.Context, ctx.Key, start, stop, count).Result()
 }
 
 func (ctx *StreamKey[k, v]) XRevRange(start, stop string) ([]redis.XMessage, error) {
-	return ctx.Rds.XRevRange(ctx.Context, ctx.Key, start, stop).Result()
+	defer ctx.touch(ctx.Key)
+	return ctx.reader().XRevRange(ctx.Context, ctx.Key, start, stop).Result()
 }
 
 func (ctx *StreamKey[k, v]) XRevRangeN(start, stop string, count int64) ([]redis.XMessage, error) {
-	return ctx.Rds.XRevRangeN(ctx.Context, ctx.Key, start, stop, count).Result()
+	defer ctx.touch(ctx.Key)
+	return ctx.reader().XRevRangeN(ctx.Context, ctx.Key, start, stop, count).Result()
 }
 
 func (ctx *StreamKey[k, v]) XRead(args *redis.XReadArgs) ([]redis.XStream, error) {
@@ -80,7 +124,113 @@ func (ctx *StreamKey[k, v]) XRead(args *redis.XReadArgs) ([]redis.XStream, error
 	if len(args.Streams) == 0 {
 		args.Streams = []string{ctx.Key, "$"} // 默认读最新
 	}
-	return ctx.Rds.XRead(ctx.Context, args).Result()
+	return ctx.reader().XRead(ctx.Context, args).Result()
+}
+
+// StreamEntry is an entry of a typed stream: its ID and decoded value
+type StreamEntry[v any] struct {
+	ID    string
+	Value v
+	// Fields are the raw fields of an entry not added by XAddValue, only set by the HTTP reads
+	Fields map[string]interface{} `json:",omitempty" msgpack:",omitempty"`
 }
 
-// XInfoGroups 等其他管理命令按需添加...
+// StreamValueField is the entry field XAddValue stores the encoded value in
+const StreamValueField = "data"
+
+// XAddValue appends value, encoded with the codec / compression / encryption of the key into the field "data",
+// after applying its modifiers and validating it. returns the entry ID
+func (ctx *StreamKey[k, v]) XAddValue(value v) (string, error) {
+	return ctx.xAddValue("*", value)
+}
+
+func (ctx *StreamKey[k, v]) xAddValue(id string, value v) (string, error) {
+	if ctx.UseModer {
+		ApplyModifiers(&value)
+	}
+	if ctx.Validator != nil {
+		if err := ctx.Validator(value); err != nil {
+			return "", err
+		}
+	}
+	valStr, err := ctx.SerializeValue(value)
+	if err != nil {
+		return "", err
+	}
+	return ctx.XAdd(&redis.XAddArgs{ID: id, Values: []interface{}{StreamValueField, valStr}})
+}
+
+// toEntries decodes the "data" field of msgs. an entry without it, not added by XAddValue, is a *DecodeError
+func (ctx *StreamKey[k, v]) toEntries(msgs []redis.XMessage) ([]StreamEntry[v], error) {
+	entries := make([]StreamEntry[v], 0, len(msgs))
+	for _, msg := range msgs {
+		entry, err := ctx.toEntry(msg)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (ctx *StreamKey[k, v]) toEntry(msg redis.XMessage) (StreamEntry[v], error) {
+	data, ok := msg.Values[StreamValueField].(string)
+	if !ok {
+		return StreamEntry[v]{ID: msg.ID}, &DecodeError{Key: ctx.Key, Field: msg.ID, Err: fmt.Errorf("no %q field in entry", StreamValueField)}
+	}
+	value, err := ctx.decodeValue(msg.ID, []byte(data))
+	return StreamEntry[v]{ID: msg.ID, Value: value}, err
+}
+
+// XRangeValues returns the decoded entries between start and stop, at most count if count > 0
+func (ctx *StreamKey[k, v]) XRangeValues(start, stop string, count int64) ([]StreamEntry[v], error) {
+	var msgs []redis.XMessage
+	var err error
+	if count > 0 {
+		msgs, err = ctx.XRangeN(start, stop, count)
+	} else {
+		msgs, err = ctx.XRange(start, stop)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ctx.toEntries(msgs)
+}
+
+// XRevRangeValues is XRangeValues from stop down to start
+func (ctx *StreamKey[k, v]) XRevRangeValues(start, stop string, count int64) ([]StreamEntry[v], error) {
+	var msgs []redis.XMessage
+	var err error
+	if count > 0 {
+		msgs, err = ctx.XRevRangeN(start, stop, count)
+	} else {
+		msgs, err = ctx.XRevRange(start, stop)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ctx.toEntries(msgs)
+}
+
+// XReadValues returns the decoded entries after lastID, at most count if count > 0.
+// it waits up to block for entries if block > 0; none is not an error.
+// lastID "" is "$" (entries added while blocking) with block, "0" (from the start) without
+func (ctx *StreamKey[k, v]) XReadValues(lastID string, count int64, block time.Duration) ([]StreamEntry[v], error) {
+	if lastID == "" {
+		lastID = "$"
+		if block <= 0 {
+			lastID = "0"
+		}
+	}
+	if block <= 0 {
+		block = -1
+	}
+	streams, err := ctx.XRead(&redis.XReadArgs{Streams: []string{ctx.Key, lastID}, Count: count, Block: block})
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil || len(streams) == 0 {
+		return nil, err
+	}
+	return ctx.toEntries(streams[0].Messages)
+}
@@ -0,0 +1,261 @@
+package redisdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/doptime/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+// XGroupCreate creates group reading the stream from start ("$" new entries only, "0" everything), creating the stream if needed.
+// an existing group is not an error
+func (ctx *StreamKey[k, v]) XGroupCreate(group, start string) error {
+	err := ctx.rds().XGroupCreateMkStream(ctx.Context, ctx.Key, group, start).Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// XGroupDestroy removes group and its pending entries
+func (ctx *StreamKey[k, v]) XGroupDestroy(group string) error {
+	return ctx.rds().XGroupDestroy(ctx.Context, ctx.Key, group).Err()
+}
+
+// XGroupDelConsumer removes consumer from group, returns the number of entries it had pending
+func (ctx *StreamKey[k, v]) XGroupDelConsumer(group, consumer string) (int64, error) {
+	return ctx.rds().XGroupDelConsumer(ctx.Context, ctx.Key, group, consumer).Result()
+}
+
+// XReadGroupValues delivers to consumer up to count entries never delivered to group, waiting up to block if block > 0.
+// none is not an error. entries stay pending until XAck
+func (ctx *StreamKey[k, v]) XReadGroupValues(group, consumer string, count int64, block time.Duration) ([]StreamEntry[v], error) {
+	msgs, err := ctx.xReadGroup(ctx.Context, group, consumer, count, block)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.toEntries(msgs)
+}
+
+func (ctx *StreamKey[k, v]) xReadGroup(c context.Context, group, consumer string, count int64, block time.Duration) ([]redis.XMessage, error) {
+	if block <= 0 {
+		block = -1
+	}
+	streams, err := ctx.rds().XReadGroup(c, &redis.XReadGroupArgs{
+		Group: group, Consumer: consumer, Streams: []string{ctx.Key, ">"}, Count: count, Block: block,
+	}).Result()
+	if err == redis.Nil || err == nil && len(streams) == 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return streams[0].Messages, nil
+}
+
+// XAck acknowledges ids in group, removing them from its pending entries. returns the number acknowledged
+func (ctx *StreamKey[k, v]) XAck(group string, ids ...string) (int64, error) {
+	return ctx.rds().XAck(ctx.Context, ctx.Key, group, ids...).Result()
+}
+
+// XPending summarizes the pending entries of group
+func (ctx *StreamKey[k, v]) XPending(group string) (*redis.XPending, error) {
+	return ctx.rds().XPending(ctx.Context, ctx.Key, group).Result()
+}
+
+// XPendingIdle lists up to count pending entries of group idle for at least idle, with their delivery counts
+func (ctx *StreamKey[k, v]) XPendingIdle(group string, idle time.Duration, count int64) ([]redis.XPendingExt, error) {
+	return ctx.rds().XPendingExt(ctx.Context, &redis.XPendingExtArgs{
+		Stream: ctx.Key, Group: group, Idle: idle, Start: "-", End: "+", Count: count,
+	}).Result()
+}
+
+// XClaim gives consumer the entries ids of group idle for at least minIdle, and returns them decoded
+func (ctx *StreamKey[k, v]) XClaim(group, consumer string, minIdle time.Duration, ids ...string) ([]StreamEntry[v], error) {
+	msgs, err := ctx.rds().XClaim(ctx.Context, &redis.XClaimArgs{
+		Stream: ctx.Key, Group: group, Consumer: consumer, MinIdle: minIdle, Messages: ids,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	return ctx.toEntries(msgs)
+}
+
+// XAutoClaim gives consumer up to count entries of group idle for at least minIdle, scanning from start.
+// returns them decoded and the start of the next scan, "0-0" when done
+func (ctx *StreamKey[k, v]) XAutoClaim(group, consumer string, minIdle time.Duration, start string, count int64) ([]StreamEntry[v], string, error) {
+	msgs, next, err := ctx.rds().XAutoClaim(ctx.Context, &redis.XAutoClaimArgs{
+		Stream: ctx.Key, Group: group, Consumer: consumer, MinIdle: minIdle, Start: start, Count: count,
+	}).Result()
+	if err != nil {
+		return nil, "", err
+	}
+	entries, err := ctx.toEntries(msgs)
+	return entries, next, err
+}
+
+// ConsumeOptions configures StreamKey.Consume
+type ConsumeOptions struct {
+	// Concurrency is the number of entries handled at once, 1 by default
+	Concurrency int
+	// Block is how long a read waits for new entries, 1s by default. shutdown is noticed within Block
+	Block time.Duration
+	// Start is where a group created by Consume starts reading: "0" (default) for the whole stream, "$" for new entries only
+	Start string
+	// MinIdle is how long an entry stays pending (handler failed or consumer died) before it is claimed and retried, 1m by default.
+	// it must be longer than a handler runs, or a running entry is handled twice
+	MinIdle time.Duration
+	// MaxDeliveries moves an entry to DeadLetter instead of retrying it once it was delivered that many times; 0 retries forever
+	MaxDeliveries int64
+	// DeadLetter is the stream, any StreamKey, entries go to after MaxDeliveries; nil drops them.
+	// the entry keeps its fields and gets "source", "source_id", "group" and "deliveries"
+	DeadLetter interface {
+		XAdd(args *redis.XAddArgs) (string, error)
+	}
+}
+
+// Consume runs a worker of consumer in group until the context of the key (WithCtx) is done, creating the group if needed.
+// handler gets each entry; the entry is acknowledged if it returns nil. an error, a panic or an entry that can't be decoded
+// leaves it pending, to be claimed and retried after MinIdle, up to MaxDeliveries.
+// on shutdown it stops reading, waits for the running handlers, whose acks still go through, and returns nil
+func (ctx *StreamKey[k, v]) Consume(group, consumer string, handler func(StreamEntry[v]) error, opts ConsumeOptions) error {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.Block <= 0 {
+		opts.Block = time.Second
+	}
+	if opts.Start == "" {
+		opts.Start = "0"
+	}
+	if opts.MinIdle <= 0 {
+		opts.MinIdle = time.Minute
+	}
+	if err := ctx.XGroupCreate(group, opts.Start); err != nil {
+		return err
+	}
+	done := ctx.Context.Done()
+	// acks and dead letters of running handlers outlive the shutdown
+	worker := ctx.WithCtx(context.WithoutCancel(ctx.Context))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, opts.Concurrency)
+	dispatch := func(msg redis.XMessage) {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			worker.handle(group, msg, handler)
+		}()
+	}
+	var lastClaim time.Time
+	for {
+		select {
+		case <-done:
+			wg.Wait()
+			return nil
+		default:
+		}
+		// claimed entries are dispatched at once: one waiting for a slot would go idle again and be re-claimed elsewhere
+		if free := int64(opts.Concurrency - len(slots)); free > 0 && time.Since(lastClaim) >= opts.MinIdle/2 {
+			lastClaim = time.Now()
+			msgs, err := worker.claimStuck(group, consumer, free, opts)
+			if err != nil {
+				logger.Error().Err(err).Str("key", ctx.Key).Str("group", group).Msg("redisdb: Consume claim failed")
+			}
+			for _, msg := range msgs {
+				dispatch(msg)
+			}
+		}
+		free := int64(opts.Concurrency - len(slots))
+		if free == 0 {
+			// wait for a running handler to finish
+			slots <- struct{}{}
+			<-slots
+			continue
+		}
+		msgs, err := ctx.xReadGroup(ctx.Context, group, consumer, free, opts.Block)
+		if err != nil {
+			if ctx.Context.Err() == nil {
+				logger.Error().Err(err).Str("key", ctx.Key).Str("group", group).Msg("redisdb: Consume read failed")
+				time.Sleep(opts.Block)
+			}
+			continue
+		}
+		for _, msg := range msgs {
+			dispatch(msg)
+		}
+	}
+}
+
+// handle runs handler on msg and acknowledges it on success
+func (ctx *StreamKey[k, v]) handle(group string, msg redis.XMessage, handler func(StreamEntry[v]) error) {
+	entry, err := ctx.toEntry(msg)
+	if err == nil {
+		err = func() (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			return handler(entry)
+		}()
+	}
+	if err != nil {
+		logger.Info().Err(err).Str("key", ctx.Key).Str("group", group).Str("id", msg.ID).Msg("redisdb: Consume handler failed, entry left pending")
+		return
+	}
+	if _, err = ctx.XAck(group, msg.ID); err != nil {
+		logger.Error().Err(err).Str("key", ctx.Key).Str("group", group).Str("id", msg.ID).Msg("redisdb: Consume ack failed")
+	}
+}
+
+// claimStuck claims up to count entries of group pending for MinIdle, to retry them. those delivered MaxDeliveries times go to the dead letter
+func (ctx *StreamKey[k, v]) claimStuck(group, consumer string, count int64, opts ConsumeOptions) (retry []redis.XMessage, err error) {
+	pending, err := ctx.XPendingIdle(group, opts.MinIdle, count)
+	if err != nil || len(pending) == 0 {
+		return nil, err
+	}
+	deliveries := make(map[string]int64, len(pending))
+	ids := make([]string, len(pending))
+	for i, p := range pending {
+		deliveries[p.ID], ids[i] = p.RetryCount, p.ID
+	}
+	msgs, err := ctx.rds().XClaim(ctx.Context, &redis.XClaimArgs{
+		Stream: ctx.Key, Group: group, Consumer: consumer, MinIdle: opts.MinIdle, Messages: ids,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range msgs {
+		if opts.MaxDeliveries <= 0 || deliveries[msg.ID] < opts.MaxDeliveries {
+			retry = append(retry, msg)
+			continue
+		}
+		if err = ctx.deadLetter(group, msg, deliveries[msg.ID], opts); err != nil {
+			return retry, err
+		}
+	}
+	return retry, nil
+}
+
+// deadLetter moves msg to the dead letter stream, if any, and acknowledges it
+func (ctx *StreamKey[k, v]) deadLetter(group string, msg redis.XMessage, deliveries int64, opts ConsumeOptions) error {
+	if opts.DeadLetter != nil {
+		values := make(map[string]interface{}, len(msg.Values)+4)
+		for field, value := range msg.Values {
+			values[field] = value
+		}
+		values["source"], values["source_id"], values["group"], values["deliveries"] = ctx.Key, msg.ID, group, deliveries
+		if _, err := opts.DeadLetter.XAdd(&redis.XAddArgs{Values: values}); err != nil {
+			return err
+		}
+	} else {
+		logger.Info().Str("key", ctx.Key).Str("group", group).Str("id", msg.ID).Msg("redisdb: Consume dropped an entry after MaxDeliveries")
+	}
+	_, err := ctx.XAck(group, msg.ID)
+	return err
+}
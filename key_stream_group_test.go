@@ -0,0 +1,131 @@
+package redisdb_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/doptime/redisdb"
+)
+
+func TestStreamGroupCommands(t *testing.T) {
+	_, rds := newServer(t)
+	jobs := redisdb.NewStreamKey[string, *Event](rds.Key("jobs"))
+	if err := jobs.XGroupCreate("g", "0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := jobs.XGroupCreate("g", "0"); err != nil {
+		t.Fatalf("XGroupCreate of an existing group = %v", err)
+	}
+	id, _ := jobs.XAddValue(&Event{User: "alice", Kind: "login"})
+	entries, err := jobs.XReadGroupValues("g", "c1", 10, 0)
+	if err != nil || len(entries) != 1 || entries[0].Value.User != "alice" {
+		t.Fatalf("XReadGroupValues = %+v, %v", entries, err)
+	}
+	if entries, err = jobs.XReadGroupValues("g", "c1", 10, 0); err != nil || len(entries) != 0 {
+		t.Fatalf("XReadGroupValues again = %+v, %v", entries, err)
+	}
+	if p, err := jobs.XPending("g"); err != nil || p.Count != 1 {
+		t.Fatalf("XPending = %+v, %v", p, err)
+	}
+	if claimed, err := jobs.XClaim("g", "c2", 0, id); err != nil || len(claimed) != 1 {
+		t.Fatalf("XClaim = %+v, %v", claimed, err)
+	}
+	pending, err := jobs.XPendingIdle("g", 0, 10)
+	if err != nil || len(pending) != 1 || pending[0].Consumer != "c2" || pending[0].RetryCount != 2 {
+		t.Fatalf("XPendingIdle = %+v, %v", pending, err)
+	}
+	if n, err := jobs.XAck("g", id); err != nil || n != 1 {
+		t.Fatalf("XAck = %d, %v", n, err)
+	}
+}
+
+func TestStreamConsume(t *testing.T) {
+	_, rds := newServer(t)
+	jobs := redisdb.NewStreamKey[string, *Event](rds.Key("jobs"))
+	for _, user := range []string{"a", "b", "c", "d"} {
+		jobs.XAddValue(&Event{User: user, Kind: "job"})
+	}
+	c, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	handled, failed := map[string]int{}, false
+	done := make(chan error)
+	go func() {
+		done <- jobs.WithCtx(c).Consume("workers", "w1", func(e redisdb.StreamEntry[*Event]) error {
+			mu.Lock()
+			defer mu.Unlock()
+			// the first attempt of b fails and is retried once idle
+			if e.Value.User == "b" && !failed {
+				failed = true
+				return errors.New("transient")
+			}
+			handled[e.Value.User]++
+			if len(handled) == 4 {
+				cancel()
+			}
+			return nil
+		}, redisdb.ConsumeOptions{Concurrency: 2, Block: 10 * time.Millisecond, MinIdle: 50 * time.Millisecond})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		cancel()
+		t.Fatalf("Consume didn't handle every entry: %v", handled)
+	}
+	for user, n := range handled {
+		if n != 1 {
+			t.Fatalf("%s handled %d times", user, n)
+		}
+	}
+	if p, _ := jobs.XPending("workers"); p.Count != 0 {
+		t.Fatalf("pending after Consume = %d, want every entry acked", p.Count)
+	}
+}
+
+func TestStreamConsumeDeadLetter(t *testing.T) {
+	_, rds := newServer(t)
+	jobs := redisdb.NewStreamKey[string, *Event](rds.Key("jobs"))
+	dead := redisdb.NewStreamKey[string, *Event](rds.Key("jobs:dead"))
+	id, _ := jobs.XAddValue(&Event{User: "poison", Kind: "job"})
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var attempts atomic.Int32
+	done := make(chan error)
+	go func() {
+		done <- jobs.WithCtx(c).Consume("workers", "w1", func(e redisdb.StreamEntry[*Event]) error {
+			attempts.Add(1)
+			return errors.New("always fails")
+		}, redisdb.ConsumeOptions{Block: 10 * time.Millisecond, MinIdle: 30 * time.Millisecond, MaxDeliveries: 3, DeadLetter: dead})
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for n, _ := dead.XLen(); n == 0; n, _ = dead.XLen() {
+		if time.Now().After(deadline) {
+			t.Fatalf("entry not dead lettered after %d attempts", attempts.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Fatalf("attempts = %d, want MaxDeliveries", n)
+	}
+	entries, err := dead.XRangeValues("-", "+", 0)
+	if err != nil || len(entries) != 1 || entries[0].Value.User != "poison" {
+		t.Fatalf("dead letters = %+v, %v", entries, err)
+	}
+	msgs, _ := dead.XRange("-", "+")
+	if msgs[0].Values["source_id"] != id || msgs[0].Values["deliveries"] != "3" {
+		t.Fatalf("dead letter fields = %v", msgs[0].Values)
+	}
+	if p, _ := jobs.XPending("workers"); p.Count != 0 {
+		t.Fatalf("pending after dead letter = %d", p.Count)
+	}
+}
@@ -0,0 +1,102 @@
+package redisdb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// retentionMinID is the smallest entry ID added within the last d, by the local clock
+func retentionMinID(d time.Duration) string {
+	return fmt.Sprintf("%d-0", time.Now().Add(-d).UnixMilli())
+}
+
+// XTrimMaxLen trims the stream to its last maxLen entries. approx (MAXLEN ~) lets redis keep a few more, which is much cheaper.
+// returns the number of entries removed
+func (ctx *StreamKey[k, v]) XTrimMaxLen(maxLen int64, approx bool) (int64, error) {
+	if approx {
+		return ctx.rds().XTrimMaxLenApprox(ctx.Context, ctx.Key, maxLen, 0).Result()
+	}
+	return ctx.rds().XTrimMaxLen(ctx.Context, ctx.Key, maxLen).Result()
+}
+
+// XTrimMinID removes the entries with an ID lower than minID, approx as in XTrimMaxLen. returns the number of entries removed
+func (ctx *StreamKey[k, v]) XTrimMinID(minID string, approx bool) (int64, error) {
+	if approx {
+		return ctx.rds().XTrimMinIDApprox(ctx.Context, ctx.Key, minID, 0).Result()
+	}
+	return ctx.rds().XTrimMinID(ctx.Context, ctx.Key, minID).Result()
+}
+
+// XTrimOlderThan removes the entries added more than age ago, going by the time in their IDs
+func (ctx *StreamKey[k, v]) XTrimOlderThan(age time.Duration, approx bool) (int64, error) {
+	return ctx.XTrimMinID(retentionMinID(age), approx)
+}
+
+// StreamInfo is the reply of XINFO STREAM, with the first and last entries decoded
+type StreamInfo[v any] struct {
+	Length               int64
+	RadixTreeKeys        int64
+	RadixTreeNodes       int64
+	Groups               int64
+	LastGeneratedID      string
+	MaxDeletedEntryID    string
+	EntriesAdded         int64
+	RecordedFirstEntryID string
+	// FirstEntry and LastEntry are nil if the stream is empty or they were not added by XAddValue
+	FirstEntry *StreamEntry[v]
+	LastEntry  *StreamEntry[v]
+}
+
+// XInfoStream describes the stream. returns ErrNotFound if it doesn't exist
+func (ctx *StreamKey[k, v]) XInfoStream() (*StreamInfo[v], error) {
+	info, err := ctx.reader().XInfoStream(ctx.Context, ctx.Key).Result()
+	if err != nil {
+		return nil, streamInfoErr(err)
+	}
+	return &StreamInfo[v]{
+		Length:               info.Length,
+		RadixTreeKeys:        info.RadixTreeKeys,
+		RadixTreeNodes:       info.RadixTreeNodes,
+		Groups:               info.Groups,
+		LastGeneratedID:      info.LastGeneratedID,
+		MaxDeletedEntryID:    info.MaxDeletedEntryID,
+		EntriesAdded:         info.EntriesAdded,
+		RecordedFirstEntryID: info.RecordedFirstEntryID,
+		FirstEntry:           ctx.infoEntry(info.FirstEntry),
+		LastEntry:            ctx.infoEntry(info.LastEntry),
+	}, nil
+}
+
+func (ctx *StreamKey[k, v]) infoEntry(msg redis.XMessage) *StreamEntry[v] {
+	if msg.ID == "" {
+		return nil
+	}
+	entry, err := ctx.toEntry(msg)
+	if err != nil {
+		return nil
+	}
+	return &entry
+}
+
+// XInfoGroups lists the consumer groups of the stream. returns ErrNotFound if it doesn't exist
+func (ctx *StreamKey[k, v]) XInfoGroups() ([]redis.XInfoGroup, error) {
+	groups, err := ctx.reader().XInfoGroups(ctx.Context, ctx.Key).Result()
+	return groups, streamInfoErr(err)
+}
+
+// XInfoConsumers lists the consumers of group. returns ErrNotFound if the stream or the group doesn't exist
+func (ctx *StreamKey[k, v]) XInfoConsumers(group string) ([]redis.XInfoConsumer, error) {
+	consumers, err := ctx.reader().XInfoConsumers(ctx.Context, ctx.Key, group).Result()
+	return consumers, streamInfoErr(err)
+}
+
+// streamInfoErr maps the "no such key" errors of XINFO to ErrNotFound
+func streamInfoErr(err error) error {
+	if err != nil && (strings.Contains(err.Error(), "no such key") || strings.HasPrefix(err.Error(), "NOGROUP")) {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return err
+}
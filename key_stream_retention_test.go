@@ -0,0 +1,101 @@
+package redisdb_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/doptime/redisdb"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestStreamTrim(t *testing.T) {
+	_, rds := newServer(t)
+	logs := redisdb.NewStreamKey[string, string](rds.Key("logs"))
+	for i := 0; i < 10; i++ {
+		logs.XAddValue(fmt.Sprint(i))
+	}
+	if n, err := logs.XTrimMaxLen(6, false); err != nil || n != 4 {
+		t.Fatalf("XTrimMaxLen = %d, %v", n, err)
+	}
+	entries, _ := logs.XRangeValues("-", "+", 0)
+	if len(entries) != 6 || entries[0].Value != "4" {
+		t.Fatalf("entries after XTrimMaxLen = %+v", entries)
+	}
+	if n, err := logs.XTrimMinID(entries[2].ID, true); err != nil || n != 2 {
+		t.Fatalf("XTrimMinID = %d, %v", n, err)
+	}
+
+	// IDs are milliseconds since the epoch
+	old := redisdb.NewStreamKey[string, string](rds.Key("old"))
+	hourAgo := time.Now().Add(-time.Hour).UnixMilli()
+	for i := int64(0); i < 3; i++ {
+		old.XAdd(&redis.XAddArgs{ID: fmt.Sprintf("%d-0", hourAgo+i), Values: []string{"data", "x"}})
+	}
+	old.XAddValue("new")
+	if n, err := old.XTrimOlderThan(time.Minute, false); err != nil || n != 3 {
+		t.Fatalf("XTrimOlderThan = %d, %v", n, err)
+	}
+}
+
+func TestStreamAutoTrim(t *testing.T) {
+	srv, rds := newServer(t)
+	capped := redisdb.NewStreamKey[string, string](rds.Key("capped").MaxLen(3))
+	for i := 0; i < 5; i++ {
+		capped.XAddValue(fmt.Sprint(i))
+	}
+	if n, _ := capped.XLen(); n != 3 {
+		t.Fatalf("XLen with MaxLen(3) = %d", n)
+	}
+
+	kept := redisdb.NewStreamKey[string, string](rds.Key("kept").Retention(time.Minute))
+	srv.XAdd("kept", fmt.Sprintf("%d-0", time.Now().Add(-time.Hour).UnixMilli()), []string{"data", "old"})
+	kept.XAddValue("new")
+	entries, _ := kept.XRangeValues("-", "+", 0)
+	if len(entries) != 1 || entries[0].Value != "new" {
+		t.Fatalf("entries with Retention(1m) = %+v", entries)
+	}
+
+	// reused args don't carry the trim of the previous call
+	args := &redis.XAddArgs{Values: []string{"data", "x"}}
+	capped.XAdd(args)
+	if args.MaxLen != 0 || args.MinID != "" || args.Stream != "" {
+		t.Fatalf("XAdd modified its args: %+v", args)
+	}
+}
+
+func TestStreamInfo(t *testing.T) {
+	srv, rds := newServer(t)
+	events := redisdb.NewStreamKey[string, *Event](rds.Key("events"))
+	if _, err := events.XInfoStream(); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("XInfoStream of a missing stream = %v, want ErrNotFound", err)
+	}
+	events.XAddValue(&Event{User: "alice", Kind: "login"})
+	events.XGroupCreate("g", "0")
+	events.XReadGroupValues("g", "c1", 10, 0)
+
+	redisdb.NewStreamKey[string, *Event](rds.Key("events")).HttpOn(redisdb.StreamOp(redisdb.StreamAll))
+	hkey, err := redisdb.GetHttpStreamKey("events", srv.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := hkey.XInfoStream()
+	if info, ok := got.(*redisdb.StreamInfo[*Event]); err != nil || !ok || info.Length != 1 {
+		t.Fatalf("XInfoStream = %#v, %v", got, err)
+	}
+	groups, err := hkey.XInfoGroups()
+	if err != nil || len(groups) != 1 || groups[0].Name != "g" || groups[0].Pending != 1 {
+		t.Fatalf("XInfoGroups = %+v, %v", groups, err)
+	}
+	consumers, err := hkey.XInfoConsumers("g")
+	if err != nil || len(consumers) != 1 || consumers[0].Name != "c1" {
+		t.Fatalf("XInfoConsumers = %+v, %v", consumers, err)
+	}
+	if _, err = hkey.XInfoConsumers("nope"); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("XInfoConsumers of a missing group = %v, want ErrNotFound", err)
+	}
+	if n, err := hkey.XTrimMaxLen(0, false); err != nil || n != 1 {
+		t.Fatalf("XTrimMaxLen = %d, %v", n, err)
+	}
+}
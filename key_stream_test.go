@@ -0,0 +1,112 @@
+package redisdb_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/doptime/redisdb"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestStreamKey(t *testing.T) {
+	_, rds := newServer(t)
+	events := redisdb.NewStreamKey[string, string](rds.Key("events"))
+	id1, err := events.XAdd(&redis.XAddArgs{Values: map[string]interface{}{"type": "login"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = events.XAdd(&redis.XAddArgs{Values: map[string]interface{}{"type": "logout"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := events.XLen(); n != 2 {
+		t.Fatalf("XLen = %d", n)
+	}
+	msgs, err := events.XRange("-", "+")
+	if err != nil || len(msgs) != 2 || msgs[0].ID != id1 || msgs[1].Values["type"] != "logout" {
+		t.Fatalf("XRange = %v, %v", msgs, err)
+	}
+	streams, err := events.XRead(&redis.XReadArgs{Streams: []string{"events", "0"}, Count: 1, Block: time.Millisecond})
+	if err != nil || len(streams) != 1 || len(streams[0].Messages) != 1 {
+		t.Fatalf("XRead = %v, %v", streams, err)
+	}
+	if n, _ := events.XDel(id1); n != 1 {
+		t.Fatalf("XDel = %d", n)
+	}
+}
+
+type Event struct {
+	User string `msgpack:"user" mod:"trim,force"`
+	Kind string `msgpack:"kind" validate:"required"`
+}
+
+func TestStreamKeyValues(t *testing.T) {
+	srv, rds := newServer(t)
+	events := redisdb.NewStreamKey[string, *Event](rds.Key("events").Modifier(trimmed))
+	id1, err := events.XAddValue(&Event{User: " alice ", Kind: "login"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events.XAddValue(&Event{User: "bob", Kind: "logout"})
+	var invalid *redisdb.ValidationError
+	if _, err = events.XAddValue(&Event{User: "carol"}); !errors.As(err, &invalid) {
+		t.Fatalf("XAddValue of an invalid value = %v, want *ValidationError", err)
+	}
+
+	entries, err := events.XRangeValues("-", "+", 0)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("XRangeValues = %v, %v", entries, err)
+	}
+	if entries[0].ID != id1 || entries[0].Value.User != "alice" || entries[1].Value.Kind != "logout" {
+		t.Fatalf("entries = %+v %+v", entries[0].Value, entries[1].Value)
+	}
+	if entries, _ = events.XRevRangeValues("+", "-", 1); len(entries) != 1 || entries[0].Value.User != "bob" {
+		t.Fatalf("XRevRangeValues = %+v", entries)
+	}
+	if entries, err = events.XReadValues(id1, 10, 0); err != nil || len(entries) != 1 || entries[0].Value.User != "bob" {
+		t.Fatalf("XReadValues = %+v, %v", entries, err)
+	}
+	if entries, err = events.XReadValues("$", 10, 0); err != nil || len(entries) != 0 {
+		t.Fatalf("XReadValues of new entries = %+v, %v", entries, err)
+	}
+	if entries, err = events.XReadValues("", 10, 0); err != nil || len(entries) != 2 {
+		t.Fatalf("XReadValues without lastID nor block = %+v, %v, want the entries from the start", entries, err)
+	}
+
+	// entries not added by XAddValue can't be decoded
+	srv.XAdd("events", "*", []string{"type", "raw"})
+	var decodeErr *redisdb.DecodeError
+	if _, err = events.XRangeValues("-", "+", 0); !errors.As(err, &decodeErr) {
+		t.Fatalf("XRangeValues of a raw entry = %v, want *DecodeError", err)
+	}
+}
+
+func TestHttpStreamKeyValues(t *testing.T) {
+	srv, rds := newServer(t)
+	redisdb.NewStreamKey[string, *Event](rds.Key("audit")).HttpOn(redisdb.StreamOp(redisdb.StreamAll))
+	audit, err := redisdb.GetHttpStreamKey("audit", srv.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = audit.XAdd("", &Event{User: "alice", Kind: "login"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := audit.XRange("-", "+", 0)
+	entries, ok := got.([]redisdb.StreamEntry[*Event])
+	if err != nil || !ok || len(entries) != 1 || entries[0].Value.User != "alice" {
+		t.Fatalf("XRange = %#v, %v", got, err)
+	}
+	// raw fields written over HTTP are read back as they are
+	if _, err = audit.XAdd("", map[string]interface{}{"type": "raw"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err = audit.XRange("-", "+", 0)
+	if entries, ok = got.([]redisdb.StreamEntry[*Event]); err != nil || len(entries) != 2 || entries[1].Fields["type"] != "raw" {
+		t.Fatalf("XRange with a raw entry = %#v, %v", got, err)
+	}
+	got, err = audit.XRead([]string{"audit", "0"}, 10, time.Millisecond)
+	read, ok := got.(map[string][]redisdb.StreamEntry[*Event])
+	if err != nil || !ok || len(read["audit"]) != 2 {
+		t.Fatalf("XRead = %#v, %v", got, err)
+	}
+}
@@ -1,10 +1,15 @@
 package redisdb
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
 	"strings"
 	"time"
 
 	"github.com/doptime/logger"
+	"github.com/redis/go-redis/v9"
 )
 
 type StringKey[k comparable, v any] struct {
@@ -14,16 +19,28 @@ type StringKey[k comparable, v any] struct {
 func NewStringKey[k comparable, v any](ops ...Option) *StringKey[k, v] {
 	ctx := &StringKey[k, v]{RedisKey: RedisKey[k, v]{KeyType: KeyTypeString}}
 	if err := ctx.applyOptionsAndCheck(KeyTypeString, ops...); err != nil {
-		logger.Error().Err(err).Msg("redisdb.NewStringKey failed")
-		return nil
+		ctx.invalidate("NewStringKey", err)
 	}
 	ctx.InitFunc()
 	return ctx
 }
 
+// MustNewStringKey is NewStringKey for strict startup: it panics if the options are invalid or the data source is not available
+func MustNewStringKey[k comparable, v any](ops ...Option) *StringKey[k, v] {
+	ctx := NewStringKey[k, v](ops...)
+	ctx.mustBeUsable("MustNewStringKey")
+	return ctx
+}
+
 func (ctx *StringKey[k, v]) ConcatKey(fields ...interface{}) *StringKey[k, v] {
 	return &StringKey[k, v]{ctx.RedisKey.Duplicate(ConcatedKeys(ctx.Key, fields...), ctx.RdsName)}
 }
+func (ctx *StringKey[k, v]) WithCtx(c context.Context) *StringKey[k, v] {
+	return &StringKey[k, v]{ctx.withCtx(c)}
+}
+func (ctx *StringKey[k, v]) Primary() *StringKey[k, v] {
+	return ctx.WithCtx(ReadFromPrimary(ctx.Context))
+}
 
 func (ctx *StringKey[k, v]) HttpOn(op StringOp) (ctx1 *StringKey[k, v]) {
 	httpAllow(ctx.Key, uint64(op))
@@ -55,15 +72,16 @@ func (ctx *StringKey[k, v]) Get(Field k) (value v, err error) {
 		keyFields = append(keyFields, FieldStr)
 	}
 
-	cmd := ctx.Rds.Get(ctx.Context, strings.Join(keyFields, ":"))
-	if err := cmd.Err(); err != nil {
-		return value, err
-	}
-	data, err := cmd.Bytes()
+	fullKey := strings.Join(keyFields, ":")
+	defer ctx.touch(fullKey)
+	data, err := ctx.cached(fullKey, "", func(rds redis.UniversalClient) ([]byte, error) {
+		data, err := rds.Get(ctx.Context, fullKey).Bytes()
+		return data, asNotFound(err)
+	})
 	if err != nil {
 		return value, err
 	}
-	return ctx.DeserializeToValue(data)
+	return ctx.decodeValue(FieldStr, data)
 }
 
 func (ctx *StringKey[k, v]) Set(key k, value v, expiration time.Duration) error {
@@ -75,7 +93,92 @@ func (ctx *StringKey[k, v]) Set(key k, value v, expiration time.Duration) error
 	if err != nil {
 		return err
 	}
-	return ctx.Rds.Set(ctx.Context, ctx.Key+":"+keyStr, valStr, expiration).Err()
+	defer ctx.uncache(ctx.Key + ":" + keyStr)
+	return ctx.rds().Set(ctx.Context, ctx.Key+":"+keyStr, valStr, ctx.expiration(expiration)).Err()
+}
+
+// Update reads key, applies fn and writes the result back with WATCH/MULTI/EXEC, retrying when the key is modified concurrently.
+// fn gets the zero value if key doesn't exist. the ttl is kept, or restarted with WithTTL; if v has a `version` field it is incremented.
+func (ctx *StringKey[k, v]) Update(key k, fn func(old v) (v, error)) (value v, err error) {
+	keyStr, err := ctx.SerializeKey(key)
+	if err != nil {
+		return value, err
+	}
+	fullKey := ctx.Key + ":" + keyStr
+	defer ctx.uncache(fullKey)
+	expiration := time.Duration(redis.KeepTTL)
+	if ctx.DefaultTTL > 0 {
+		expiration = ctx.DefaultTTL
+	}
+	for i := 0; i < MaxUpdateRetries; i++ {
+		err = ctx.rds().Watch(ctx.Context, func(tx *redis.Tx) error {
+			var old v
+			raw, err := tx.Get(ctx.Context, fullKey).Bytes()
+			if err == nil {
+				if old, err = ctx.decodeValue(keyStr, raw); err != nil {
+					return err
+				}
+			} else if err != redis.Nil {
+				return err
+			}
+			if value, err = fn(old); err != nil {
+				return err
+			}
+			if ctx.UseModer {
+				ApplyModifiers(&value)
+			}
+			ctx.setVersion(&value, ctx.getVersion(old)+1)
+			valStr, err := ctx.SerializeValue(value)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx.Context, func(pipe redis.Pipeliner) error {
+				return pipe.Set(ctx.Context, fullKey, valStr, expiration).Err()
+			})
+			return err
+		}, fullKey)
+		if err != redis.TxFailedErr {
+			return value, err
+		}
+	}
+	return value, fmt.Errorf("%w: %s still contended after %d retries", ErrVersionConflict, fullKey, MaxUpdateRetries)
+}
+
+// SetWithVersion writes value only if its `version` field equals the stored version (0 if key doesn't exist),
+// and stores it with version+1. returns ErrVersionConflict otherwise. the check and write are atomic.
+// value is not modified; the stored copy with the new version is returned on success
+func (ctx *StringKey[k, v]) SetWithVersion(key k, value v, expiration time.Duration) (stored v, err error) {
+	if !ctx.HasVersion() {
+		return value, fmt.Errorf("redisdb: %T has no version field", value)
+	}
+	keyStr, err := ctx.SerializeKey(key)
+	if err != nil {
+		return value, err
+	}
+	fullKey := ctx.Key + ":" + keyStr
+	defer ctx.uncache(fullKey)
+	raw, err := ctx.rds().Get(ctx.Context, fullKey).Bytes()
+	if err == redis.Nil {
+		raw, err = nil, nil
+	} else if err != nil {
+		return value, err
+	}
+	next, err := ctx.checkVersion(keyStr, raw, value)
+	if err != nil {
+		return value, err
+	}
+	valStr, err := ctx.SerializeValue(next)
+	if err != nil {
+		return value, err
+	}
+	existed, read := casArgs(raw)
+	swapped, err := casSetScript.Run(ctx.Context, ctx.rds(), []string{fullKey}, existed, read, valStr, ctx.expiration(expiration).Milliseconds()).Int()
+	if err != nil {
+		return value, err
+	} else if swapped == 0 {
+		return value, ErrVersionConflict
+	}
+	return next, nil
 }
 
 func (ctx *StringKey[k, v]) Del(key k) error {
@@ -83,50 +186,86 @@ func (ctx *StringKey[k, v]) Del(key k) error {
 	if err != nil {
 		return err
 	}
-	return ctx.Rds.Del(ctx.Context, ctx.Key+":"+keyStr).Err()
+	defer ctx.uncache(ctx.Key + ":" + keyStr)
+	return ctx.rds().Del(ctx.Context, ctx.Key+":"+keyStr).Err()
 }
 
-// get all keys that match the pattern, and return a map of key->value
+// get all keys that match the pattern (all keys if match is ""), and return a map of key->value.
+// keys whose GET fails are skipped, the last such error is returned with the other values
 func (ctx *StringKey[k, v]) GetAll(match string) (mapOut map[k]v, err error) {
-	var (
-		keys []string = []string{match}
-		val  []byte
-	)
-	if keys, _, err = ctx.Scan(0, match, 1024*1024*1024); err != nil {
-		return nil, err
-	}
-	mapOut = make(map[k]v, len(keys))
-	var result error
-	for _, key := range keys {
-		if val, result = ctx.Rds.Get(ctx.Context, key).Bytes(); result != nil {
-			err = result
-			continue
-		}
-		//use default prefix to avoid confict of hash key
-		//k is start with ctx.Key, remove it
-		if len(ctx.Key) > 0 && (len(key) >= len(ctx.Key)) && key[:len(ctx.Key)] == ctx.Key {
-			key = key[len(ctx.Key)+1:]
-		}
-
-		k, err := ctx.toKey([]byte(key))
-		if err != nil {
-			logger.Info().AnErr("GetAll: key unmarshal error:", err).Msgf("Key: %s", ctx.Key)
+	if match == "" {
+		match = "*"
+	}
+	mapOut = make(map[k]v)
+	for kv, e := range ctx.Entries(match) {
+		var decodeErr *DecodeError
+		if errors.As(e, &decodeErr) {
+			logger.Info().AnErr("GetAll: unmarshal error:", e).Msgf("Key: %s", ctx.Key)
 			continue
-		}
-		v, err := ctx.DeserializeToValue(val)
-		if err != nil {
-			logger.Info().AnErr("GetAll: value unmarshal error:", err).Msgf("Key: %s", ctx.Key)
+		} else if e != nil {
+			err = e
 			continue
 		}
-		mapOut[k] = v
+		mapOut[kv.Key] = kv.Value
 	}
 	return mapOut, err
 }
 
+// Entries iterates the keys matching match (a full key pattern, default "ctx.Key:*") with SCAN.
+// values of each page are fetched with one pipelined round trip; keys deleted in between are skipped.
+// a key / value that fails to decode is yielded as *DecodeError, a key whose GET fails (e.g. not a string) with that error,
+// and iteration goes on; a failed SCAN ends the iteration
+func (ctx *StringKey[k, v]) Entries(match string) iter.Seq2[KeyValue[k, v], error] {
+	if match == "" {
+		match = ctx.Key + ":*"
+	}
+	return func(yield func(KeyValue[k, v], error) bool) {
+		scanPages(ctx.Context, ctx.reader(), match, func(node redis.UniversalClient, keys []string, err error) bool {
+			if err != nil {
+				yield(KeyValue[k, v]{}, err)
+				return false
+			}
+			pipe := node.Pipeline()
+			cmds := make([]*redis.StringCmd, len(keys))
+			for i, key := range keys {
+				cmds[i] = pipe.Get(ctx.Context, key)
+			}
+			// the error of each GET is checked below
+			pipe.Exec(ctx.Context)
+			for i, key := range keys {
+				val, err := cmds[i].Bytes()
+				if err == redis.Nil {
+					continue
+				} else if err != nil {
+					if !yield(KeyValue[k, v]{}, fmt.Errorf("redisdb: GET %s: %w", key, err)) {
+						return false
+					}
+					continue
+				}
+				var kv KeyValue[k, v]
+				if kv.Key, err = ctx.toKey([]byte(ctx.trimKeyPrefix(key))); err != nil {
+					err = &DecodeError{Key: key, Raw: []byte(key), Err: err}
+				} else {
+					kv.Value, err = ctx.decodeValue(ctx.trimKeyPrefix(key), val)
+				}
+				if !yield(kv, err) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+}
+
 // set each key value of _map to redis string type key value
 func (ctx *StringKey[k, v]) SetAll(_map map[k]v) (err error) {
 	//HSet each element of _map to redis
-	pipe := ctx.Rds.Pipeline()
+	//on a cluster the pipeline groups commands by hash slot and sends one batch per master
+	pipe := ctx.rds().Pipeline()
+	expiration := time.Duration(redis.KeepTTL)
+	if ctx.DefaultTTL > 0 {
+		expiration = ctx.DefaultTTL
+	}
 	for k, v := range _map {
 		keyStr, err := ctx.SerializeKey(k)
 		if err != nil {
@@ -137,7 +276,8 @@ func (ctx *StringKey[k, v]) SetAll(_map map[k]v) (err error) {
 			return err
 		}
 
-		pipe.Set(ctx.Context, ctx.Key+":"+keyStr, valStr, -1)
+		pipe.Set(ctx.Context, ctx.Key+":"+keyStr, valStr, expiration)
+		defer ctx.uncache(ctx.Key + ":" + keyStr)
 	}
 	_, err = pipe.Exec(ctx.Context)
 	return err
@@ -0,0 +1,87 @@
+package redisdb_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/doptime/redisdb"
+)
+
+func TestStringKeySetGet(t *testing.T) {
+	_, rds := newServer(t)
+	users := redisdb.NewStringKey[string, *User](rds.Key("user"))
+	if err := users.Set("u1", &User{ID: "u1", Name: "alice"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	u, err := users.Get("u1")
+	if err != nil || u.Name != "alice" {
+		t.Fatalf("Get = %+v, %v", u, err)
+	}
+	if _, err = users.Get("missing"); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("Get missing: %v, want ErrNotFound", err)
+	}
+	if err = users.Del("u1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = users.Get("u1"); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("Get deleted: %v, want ErrNotFound", err)
+	}
+}
+
+func TestStringKeyTTL(t *testing.T) {
+	srv, rds := newServer(t)
+	tokens := redisdb.NewStringKey[string, string](rds.Key("token"))
+	if err := tokens.Set("t1", "secret", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := srv.TTL("token:t1"); ttl != time.Minute {
+		t.Fatalf("ttl = %v", ttl)
+	}
+	srv.FastForward(2 * time.Minute)
+	if _, err := tokens.Get("t1"); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("Get expired: %v, want ErrNotFound", err)
+	}
+}
+
+func TestStringKeySetAllGetAll(t *testing.T) {
+	srv, rds := newServer(t)
+	counters := redisdb.NewStringKey[string, int](rds.Key("cnt"))
+	in := map[string]int{"a": 1, "b": 2, "c": 3}
+	if err := counters.SetAll(in); err != nil {
+		t.Fatal(err)
+	}
+	out, err := counters.GetAll(counters.Key + ":*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != len(in) {
+		t.Fatalf("GetAll = %v", out)
+	}
+	for k, v := range in {
+		if out[k] != v {
+			t.Fatalf("GetAll[%s] = %d, want %d", k, out[k], v)
+		}
+	}
+
+	// "" matches every key; a key that is not a string is skipped and its error returned with the other values
+	srv.HSet("h", "f", "1")
+	srv.Set("other", "4")
+	out, err = counters.GetAll("")
+	if err == nil || len(out) != len(in)+1 || out["other"] != 4 {
+		t.Fatalf("GetAll(\"\") = %v, %v", out, err)
+	}
+}
+
+func TestStringKeyUpdate(t *testing.T) {
+	_, rds := newServer(t)
+	counters := redisdb.NewStringKey[string, int](rds.Key("cnt"))
+	for i := 0; i < 3; i++ {
+		if _, err := counters.Update("a", func(old int) (int, error) { return old + 1, nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v, _ := counters.Get("a"); v != 3 {
+		t.Fatalf("Get = %d, want 3", v)
+	}
+}
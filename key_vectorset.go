@@ -1,6 +1,7 @@
 package redisdb
 
 import (
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -24,16 +25,28 @@ type VectorSetKey[k comparable, v any] struct {
 func NewVectorSetKey[k comparable, v any](ops ...Option) *VectorSetKey[k, v] {
 	ctx := &VectorSetKey[k, v]{RedisKey: RedisKey[k, v]{KeyType: KeyTypeVectorSet}}
 	if err := ctx.applyOptionsAndCheck(KeyTypeVectorSet, ops...); err != nil {
-		logger.Error().Err(err).Msg("redisdb.NewVectorSetKey failed")
-		return nil
+		ctx.invalidate("NewVectorSetKey", err)
 	}
 	ctx.InitFunc()
 	return ctx
 }
 
+// MustNewVectorSetKey is NewVectorSetKey for strict startup: it panics if the options are invalid or the data source is not available
+func MustNewVectorSetKey[k comparable, v any](ops ...Option) *VectorSetKey[k, v] {
+	ctx := NewVectorSetKey[k, v](ops...)
+	ctx.mustBeUsable("MustNewVectorSetKey")
+	return ctx
+}
+
 func (ctx *VectorSetKey[k, v]) ConcatKey(fields ...interface{}) *VectorSetKey[k, v] {
 	return &VectorSetKey[k, v]{ctx.Duplicate(ConcatedKeys(ctx.Key, fields...), ctx.RdsName)}
 }
+func (ctx *VectorSetKey[k, v]) WithCtx(c context.Context) *VectorSetKey[k, v] {
+	return &VectorSetKey[k, v]{ctx.withCtx(c)}
+}
+func (ctx *VectorSetKey[k, v]) Primary() *VectorSetKey[k, v] {
+	return ctx.WithCtx(ReadFromPrimary(ctx.Context))
+}
 
 func (ctx *VectorSetKey[k, v]) HttpOn(op VectorSetOp) *VectorSetKey[k, v] {
 	httpAllow(ctx.Key, uint64(op))
@@ -58,7 +71,7 @@ func (ctx *VectorSetKey[k, v]) RegisterHttpInterface() {
 // Create executes FT.CREATE.
 func (ctx *VectorSetKey[k, v]) Create(args ...interface{}) error {
 	cmdArgs := append([]interface{}{"FT.CREATE", ctx.Key}, args...)
-	return ctx.Rds.Do(ctx.Context, cmdArgs...).Err()
+	return ctx.rds().Do(ctx.Context, cmdArgs...).Err()
 }
 
 // DropIndex deletes the index. If deleteDocs is true, it passes DD to delete documents.
@@ -67,12 +80,12 @@ func (ctx *VectorSetKey[k, v]) DropIndex(deleteDocs bool) error {
 	if deleteDocs {
 		args = append(args, "DD")
 	}
-	return ctx.Rds.Do(ctx.Context, args...).Err()
+	return ctx.rds().Do(ctx.Context, args...).Err()
 }
 
 // Info retrieves index statistics.
 func (ctx *VectorSetKey[k, v]) Info() (map[string]interface{}, error) {
-	res, err := ctx.Rds.Do(ctx.Context, "FT.INFO", ctx.Key).Result()
+	res, err := ctx.reader().Do(ctx.Context, "FT.INFO", ctx.Key).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -81,22 +94,22 @@ func (ctx *VectorSetKey[k, v]) Info() (map[string]interface{}, error) {
 
 // AliasAdd adds an alias to the index.
 func (ctx *VectorSetKey[k, v]) AliasAdd(alias string) error {
-	return ctx.Rds.Do(ctx.Context, "FT.ALIASADD", alias, ctx.Key).Err()
+	return ctx.rds().Do(ctx.Context, "FT.ALIASADD", alias, ctx.Key).Err()
 }
 
 // AliasUpdate updates an alias to point to this index.
 func (ctx *VectorSetKey[k, v]) AliasUpdate(alias string) error {
-	return ctx.Rds.Do(ctx.Context, "FT.ALIASUPDATE", alias, ctx.Key).Err()
+	return ctx.rds().Do(ctx.Context, "FT.ALIASUPDATE", alias, ctx.Key).Err()
 }
 
 // AliasDel deletes an alias.
 func (ctx *VectorSetKey[k, v]) AliasDel(alias string) error {
-	return ctx.Rds.Do(ctx.Context, "FT.ALIASDEL", alias).Err()
+	return ctx.rds().Do(ctx.Context, "FT.ALIASDEL", alias).Err()
 }
 
 // TagVals returns the distinct values indexed in a Tag field.
 func (ctx *VectorSetKey[k, v]) TagVals(fieldName string) ([]string, error) {
-	res, err := ctx.Rds.Do(ctx.Context, "FT.TAGVALS", ctx.Key, fieldName).Result()
+	res, err := ctx.reader().Do(ctx.Context, "FT.TAGVALS", ctx.Key, fieldName).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -120,7 +133,7 @@ func (ctx *VectorSetKey[k, v]) TagVals(fieldName string) ([]string, error) {
 func (ctx *VectorSetKey[k, v]) Search(query string, params ...interface{}) (count int64, docs []v, err error) {
 	args := append([]interface{}{"FT.SEARCH", ctx.Key, query}, params...)
 
-	res, err := ctx.Rds.Do(ctx.Context, args...).Result()
+	res, err := ctx.reader().Do(ctx.Context, args...).Result()
 	if err != nil {
 		return 0, nil, err
 	}
@@ -1,11 +1,13 @@
 package redisdb
 
 import (
+	"context"
+	"errors"
+	"iter"
 	"reflect"
+	"strconv"
 
-	"github.com/doptime/logger"
 	"github.com/redis/go-redis/v9"
-	"github.com/vmihailenco/msgpack/v5"
 )
 
 type ZSetKey[k comparable, v any] struct {
@@ -14,17 +16,33 @@ type ZSetKey[k comparable, v any] struct {
 
 func NewZSetKey[k comparable, v any](ops ...Option) *ZSetKey[k, v] {
 	ctx := &ZSetKey[k, v]{RedisKey: RedisKey[k, v]{KeyType: KeyTypeZSet}}
-	if err := ctx.applyOptionsAndCheck(KeyTypeZSet, ops...); err != nil {
-		logger.Error().Err(err).Msg("redisdb.NewZSetKey failed")
-		return nil
+	err := ctx.applyOptionsAndCheck(KeyTypeZSet, ops...)
+	if err == nil {
+		err = ctx.checkMatchableMembers()
+	}
+	if err != nil {
+		ctx.invalidate("NewZSetKey", err)
 	}
 	ctx.InitFunc()
 	return ctx
 }
 
+// MustNewZSetKey is NewZSetKey for strict startup: it panics if the options are invalid or the data source is not available
+func MustNewZSetKey[k comparable, v any](ops ...Option) *ZSetKey[k, v] {
+	ctx := NewZSetKey[k, v](ops...)
+	ctx.mustBeUsable("MustNewZSetKey")
+	return ctx
+}
+
 func (ctx *ZSetKey[k, v]) ConcatKey(fields ...interface{}) *ZSetKey[k, v] {
 	return &ZSetKey[k, v]{ctx.RedisKey.Duplicate(ConcatedKeys(ctx.Key, fields...), ctx.RdsName)}
 }
+func (ctx *ZSetKey[k, v]) WithCtx(c context.Context) *ZSetKey[k, v] {
+	return &ZSetKey[k, v]{ctx.withCtx(c)}
+}
+func (ctx *ZSetKey[k, v]) Primary() *ZSetKey[k, v] {
+	return ctx.WithCtx(ReadFromPrimary(ctx.Context))
+}
 
 func (ctx *ZSetKey[k, v]) HttpOn(op ZSetOp) (ctx1 *ZSetKey[k, v]) {
 	httpAllow(ctx.Key, uint64(op))
@@ -48,36 +66,30 @@ func (ctx *ZSetKey[k, v]) ZAdd(members ...redis.Z) (err error) {
 	// 但为了性能，直接修改 members 里的 Member 字段为 []byte
 	for i := range members {
 		// 如果 Member 是 v 类型，或者是 struct，尝试序列化
-		// 如果已经是 []byte 或 string，msgpack.Marshal 也会处理
+		// 如果已经是 []byte 或 string，codec 也会处理
 		if members[i].Member != nil {
 			// 这里假设 HTTP 层传进来的是 Struct/Map，需要序列化存储
-			b, err := msgpack.Marshal(members[i].Member)
-			if err == nil {
+			if b, err := ctx.serializeInterface(members[i].Member); err == nil {
 				members[i].Member = b
 			}
 		}
 	}
-	return ctx.Rds.ZAdd(ctx.Context, ctx.Key, members...).Err()
+	return writeWithTTL(&ctx.RedisKey, ctx.Key, func(rds redis.Cmdable) *redis.IntCmd {
+		return rds.ZAdd(ctx.Context, ctx.Key, members...)
+	}).Err()
 }
 
 func (ctx *ZSetKey[k, v]) ZRem(members ...interface{}) (err error) {
 	var bytes = make([][]byte, len(members))
-	var _msgpack string
-	for i, member := range members {
-		// 尝试断言为 v 以利用自定义序列化(如果有)，否则通用序列化
-		if vVal, ok := member.(v); ok {
-			if _msgpack, err = ctx.SerializeValue(vVal); err != nil {
-				return err
-			}
-			bytes[i] = []byte(_msgpack)
-		} else {
-			if bytes[i], err = msgpack.Marshal(member); err != nil {
-				return err
-			}
+	var member string
+	for i := range members {
+		if member, err = ctx.serializeInterface(members[i]); err != nil {
+			return err
 		}
+		bytes[i] = []byte(member)
 	}
 	// Pipeline 优化
-	var redisPipe = ctx.Rds.Pipeline()
+	var redisPipe = ctx.rds().Pipeline()
 	for _, memberBytes := range bytes {
 		redisPipe.ZRem(ctx.Context, ctx.Key, memberBytes)
 	}
@@ -86,69 +98,79 @@ func (ctx *ZSetKey[k, v]) ZRem(members ...interface{}) (err error) {
 }
 
 func (ctx *ZSetKey[k, v]) ZRange(start, stop int64) (members []v, err error) {
-	cmd := ctx.Rds.ZRange(ctx.Context, ctx.Key, start, stop)
-	if err = cmd.Err(); err != nil && err != redis.Nil {
+	defer ctx.touch(ctx.Key)
+	cmd := ctx.reader().ZRange(ctx.Context, ctx.Key, start, stop)
+	if err = cmd.Err(); err != nil && !errors.Is(err, redis.Nil) {
 		return nil, err
 	}
 	return ctx.UnmarshalToSlice(cmd.Val())
 }
 
 func (ctx *ZSetKey[k, v]) ZRangeWithScores(start, stop int64) (members []v, scores []float64, err error) {
-	cmd := ctx.Rds.ZRangeWithScores(ctx.Context, ctx.Key, start, stop)
+	defer ctx.touch(ctx.Key)
+	cmd := ctx.reader().ZRangeWithScores(ctx.Context, ctx.Key, start, stop)
 	return ctx.UnmarshalRedisZ(cmd.Val())
 }
 func (ctx *ZSetKey[k, v]) ZRevRangeWithScores(start, stop int64) (members []v, scores []float64, err error) {
-	cmd := ctx.Rds.ZRevRangeWithScores(ctx.Context, ctx.Key, start, stop)
+	defer ctx.touch(ctx.Key)
+	cmd := ctx.reader().ZRevRangeWithScores(ctx.Context, ctx.Key, start, stop)
 	return ctx.UnmarshalRedisZ(cmd.Val())
 }
 
 // ZRank: 参数改为 interface{}
 func (ctx *ZSetKey[k, v]) ZRank(member interface{}) (rank int64, err error) {
+	defer ctx.touch(ctx.Key)
 	memberBytes, err := ctx.serializeInterface(member)
 	if err != nil {
 		return 0, err
 	}
-	cmd := ctx.Rds.ZRank(ctx.Context, ctx.Key, string(memberBytes))
-	return cmd.Val(), cmd.Err()
+	cmd := ctx.reader().ZRank(ctx.Context, ctx.Key, string(memberBytes))
+	return cmd.Val(), asNotFound(cmd.Err())
 }
 
 // ZRevRank: 参数改为 interface{}
 func (ctx *ZSetKey[k, v]) ZRevRank(member interface{}) (rank int64, err error) {
+	defer ctx.touch(ctx.Key)
 	memberBytes, err := ctx.serializeInterface(member)
 	if err != nil {
 		return 0, err
 	}
-	cmd := ctx.Rds.ZRevRank(ctx.Context, ctx.Key, string(memberBytes))
-	return cmd.Val(), cmd.Err()
+	cmd := ctx.reader().ZRevRank(ctx.Context, ctx.Key, string(memberBytes))
+	return cmd.Val(), asNotFound(cmd.Err())
 }
 
 // ZScore: 参数改为 interface{}
 func (ctx *ZSetKey[k, v]) ZScore(member interface{}) (score float64, err error) {
+	defer ctx.touch(ctx.Key)
 	memberBytes, err := ctx.serializeInterface(member)
 	if err != nil {
 		return 0, err
 	}
-	cmd := ctx.Rds.ZScore(ctx.Context, ctx.Key, string(memberBytes))
+	cmd := ctx.reader().ZScore(ctx.Context, ctx.Key, string(memberBytes))
 	if err = cmd.Err(); err != nil {
-		return 0, err
+		return 0, asNotFound(err)
 	}
 	return cmd.Result()
 }
 
 func (ctx *ZSetKey[k, v]) ZCard() (int64, error) {
-	return ctx.Rds.ZCard(ctx.Context, ctx.Key).Result()
+	defer ctx.touch(ctx.Key)
+	return ctx.reader().ZCard(ctx.Context, ctx.Key).Result()
 }
 
 func (ctx *ZSetKey[k, v]) ZCount(min, max string) (int64, error) {
-	return ctx.Rds.ZCount(ctx.Context, ctx.Key, min, max).Result()
+	defer ctx.touch(ctx.Key)
+	return ctx.reader().ZCount(ctx.Context, ctx.Key, min, max).Result()
 }
 
 func (ctx *ZSetKey[k, v]) ZRangeByScore(opt *redis.ZRangeBy) (out []v, err error) {
-	cmd := ctx.Rds.ZRangeByScore(ctx.Context, ctx.Key, opt)
+	defer ctx.touch(ctx.Key)
+	cmd := ctx.reader().ZRangeByScore(ctx.Context, ctx.Key, opt)
 	return ctx.UnmarshalToSlice(cmd.Val())
 }
 func (ctx *ZSetKey[k, v]) ZRangeByScoreWithScores(opt *redis.ZRangeBy) (out []v, scores []float64, err error) {
-	cmd := ctx.Rds.ZRangeByScoreWithScores(ctx.Context, ctx.Key, opt)
+	defer ctx.touch(ctx.Key)
+	cmd := ctx.reader().ZRangeByScoreWithScores(ctx.Context, ctx.Key, opt)
 	if err = cmd.Err(); err != nil {
 		return nil, nil, err
 	}
@@ -156,12 +178,14 @@ func (ctx *ZSetKey[k, v]) ZRangeByScoreWithScores(opt *redis.ZRangeBy) (out []v,
 }
 
 func (ctx *ZSetKey[k, v]) ZRevRangeByScore(opt *redis.ZRangeBy) (out []v, err error) {
-	cmd := ctx.Rds.ZRevRangeByScore(ctx.Context, ctx.Key, opt)
+	defer ctx.touch(ctx.Key)
+	cmd := ctx.reader().ZRevRangeByScore(ctx.Context, ctx.Key, opt)
 	return ctx.UnmarshalToSlice(cmd.Val())
 }
 
 func (ctx *ZSetKey[k, v]) ZRevRange(start, stop int64) (out []v, err error) {
-	cmd := ctx.Rds.ZRevRange(ctx.Context, ctx.Key, start, stop)
+	defer ctx.touch(ctx.Key)
+	cmd := ctx.reader().ZRevRange(ctx.Context, ctx.Key, start, stop)
 	if err := cmd.Err(); err != nil {
 		return nil, err
 	}
@@ -169,18 +193,19 @@ func (ctx *ZSetKey[k, v]) ZRevRange(start, stop int64) (out []v, err error) {
 }
 
 func (ctx *ZSetKey[k, v]) ZRevRangeByScoreWithScores(opt *redis.ZRangeBy) (out []v, scores []float64, err error) {
-	cmd := ctx.Rds.ZRevRangeByScoreWithScores(ctx.Context, ctx.Key, opt)
+	defer ctx.touch(ctx.Key)
+	cmd := ctx.reader().ZRevRangeByScoreWithScores(ctx.Context, ctx.Key, opt)
 	if err = cmd.Err(); err != nil {
 		return nil, nil, err
 	}
 	return ctx.UnmarshalRedisZ(cmd.Val())
 }
 func (ctx *ZSetKey[k, v]) ZRemRangeByRank(start, stop int64) (err error) {
-	return ctx.Rds.ZRemRangeByRank(ctx.Context, ctx.Key, start, stop).Err()
+	return ctx.rds().ZRemRangeByRank(ctx.Context, ctx.Key, start, stop).Err()
 }
 
 func (ctx *ZSetKey[k, v]) ZRemRangeByScore(min, max string) error {
-	return ctx.Rds.ZRemRangeByScore(ctx.Context, ctx.Key, min, max).Err()
+	return ctx.rds().ZRemRangeByScore(ctx.Context, ctx.Key, min, max).Err()
 }
 
 // ZIncrBy: 参数改为 interface{}
@@ -189,48 +214,92 @@ func (ctx *ZSetKey[k, v]) ZIncrBy(increment float64, member interface{}) (float6
 	if err != nil {
 		return 0, err
 	}
-	// ctx.Rds.ZIncrBy 返回 *FloatCmd
+	// ctx.rds().ZIncrBy 返回 *FloatCmd
 	// .Result() 返回 (float64, error)
-	return ctx.Rds.ZIncrBy(ctx.Context, ctx.Key, increment, string(memberBytes)).Result()
+	return writeWithTTL(&ctx.RedisKey, ctx.Key, func(rds redis.Cmdable) *redis.FloatCmd {
+		return rds.ZIncrBy(ctx.Context, ctx.Key, increment, string(memberBytes))
+	}).Result()
 }
 
 func (ctx *ZSetKey[k, v]) ZPopMax(count int64) (out []v, scores []float64, err error) {
-	cmd := ctx.Rds.ZPopMax(ctx.Context, ctx.Key, count)
+	cmd := ctx.rds().ZPopMax(ctx.Context, ctx.Key, count)
 	if err = cmd.Err(); err != nil {
 		return nil, nil, err
 	}
 	return ctx.UnmarshalRedisZ(cmd.Val())
 }
 func (ctx *ZSetKey[k, v]) ZPopMin(count int64) (out []v, scores []float64, err error) {
-	cmd := ctx.Rds.ZPopMin(ctx.Context, ctx.Key, count)
+	cmd := ctx.rds().ZPopMin(ctx.Context, ctx.Key, count)
 	if err = cmd.Err(); err != nil {
 		return nil, nil, err
 	}
 	return ctx.UnmarshalRedisZ(cmd.Val())
 }
 func (ctx *ZSetKey[k, v]) ZLexCount(min, max string) (int64, error) {
-	return ctx.Rds.ZLexCount(ctx.Context, ctx.Key, min, max).Result()
+	return ctx.reader().ZLexCount(ctx.Context, ctx.Key, min, max).Result()
 }
 
 func (ctx *ZSetKey[k, v]) ZScan(cursor uint64, match string, count int64) (values []v, rcursor uint64, err error) {
+	defer ctx.touch(ctx.Key)
 	var strs []string
-	strs, rcursor, err = ctx.Rds.ZScan(ctx.Context, ctx.Key, cursor, match, count).Result()
-	values = make([]v, 0, len(strs))
-	for _, s := range strs {
-		if _v, err := ctx.DeserializeToValue([]byte(s)); err == nil {
+	strs, rcursor, err = ctx.reader().ZScan(ctx.Context, ctx.Key, cursor, match, count).Result()
+	// the reply alternates members and scores
+	values = make([]v, 0, len(strs)/2)
+	for i := 0; i < len(strs); i += 2 {
+		if _v, err := ctx.decodeMember(strs[i]); err == nil {
 			values = append(values, _v)
 		}
 	}
 	return values, rcursor, err
 }
 
+// ScanAll iterates the sorted set with ZSCAN, page by page. ZSCAN doesn't keep score order, use ZRange for that
+func (ctx *ZSetKey[k, v]) ScanAll() iter.Seq2[ZMember[v], error] {
+	return func(yield func(ZMember[v], error) bool) {
+		var cursor uint64
+		for {
+			memberScores, next, err := ctx.reader().ZScan(ctx.Context, ctx.Key, cursor, "", ScanPageSize).Result()
+			if err != nil {
+				yield(ZMember[v]{}, err)
+				return
+			}
+			for i := 0; i+1 < len(memberScores); i += 2 {
+				var m ZMember[v]
+				if m.Member, err = ctx.decodeMember(memberScores[i]); err == nil {
+					m.Score, err = strconv.ParseFloat(memberScores[i+1], 64)
+				}
+				if !yield(m, err) {
+					return
+				}
+			}
+			if cursor = next; cursor == 0 {
+				return
+			}
+		}
+	}
+}
+
+// decodeMember decodes a single member the same way as UnmarshalToSlice
+func (ctx *ZSetKey[k, v]) decodeMember(member string) (value v, err error) {
+	data, err := ctx.decompress([]byte(member))
+	if err == nil {
+		elemPtr := newCodecTarget(reflect.TypeOf((*v)(nil)).Elem())
+		if err = ctx.Codec.Unmarshal(data, codecTargetInterface(elemPtr)); err == nil {
+			return elemPtr.Elem().Interface().(v), nil
+		}
+	}
+	return value, &DecodeError{Key: ctx.Key, Raw: []byte(member), Err: err}
+}
+
 // 辅助：统一序列化 interface{}，优先尝试转为 v
+// serializeInterface encodes a member the way ZAdd stores it: always through the codec (primitives included), then compress.
+// SerializeValue is not used, members are matched byte by byte and must not be encrypted.
+// it used to encode primitive members of v as plain text, see the migration note of ZSetKey in the readme
 func (ctx *ZSetKey[k, v]) serializeInterface(member interface{}) (string, error) {
-	if vVal, ok := member.(v); ok {
-		return ctx.SerializeValue(vVal)
+	bytes, err := ctx.Codec.Marshal(member)
+	if err == nil {
+		bytes, err = ctx.compress(bytes)
 	}
-	// Fallback
-	bytes, err := msgpack.Marshal(member)
 	return string(bytes), err
 }
 
@@ -242,8 +311,12 @@ func (ctx *ZSetKey[k, v]) UnmarshalToSlice(members []string) (out []v, err error
 
 	for _, member := range members {
 		// 创建 v 的指针
-		elemPtr := reflect.New(vType)
-		if err := msgpack.Unmarshal([]byte(member), elemPtr.Interface()); err != nil {
+		data, err := ctx.decompress([]byte(member))
+		if err != nil {
+			return out, err
+		}
+		elemPtr := newCodecTarget(vType)
+		if err := ctx.Codec.Unmarshal(data, codecTargetInterface(elemPtr)); err != nil {
 			return out, err
 		}
 		out = append(out, elemPtr.Elem().Interface().(v))
@@ -262,8 +335,12 @@ func (ctx *ZSetKey[k, v]) UnmarshalRedisZ(members []redis.Z) (out []v, scores []
 			continue // 或处理错误
 		}
 
-		elemPtr := reflect.New(vType)
-		if err := msgpack.Unmarshal([]byte(str), elemPtr.Interface()); err != nil {
+		data, err := ctx.decompress([]byte(str))
+		if err != nil {
+			return nil, nil, err
+		}
+		elemPtr := newCodecTarget(vType)
+		if err := ctx.Codec.Unmarshal(data, codecTargetInterface(elemPtr)); err != nil {
 			return nil, nil, err
 		}
 		out = append(out, elemPtr.Elem().Interface().(v))
@@ -271,3 +348,19 @@ func (ctx *ZSetKey[k, v]) UnmarshalRedisZ(members []redis.Z) (out []v, scores []
 	}
 	return out, scores, nil
 }
+
+// newCodecTarget 创建 *v; 若 v 本身是指针, 预先分配元素, 以便 protobuf 这类只接受 *Message 的 codec 直接解码
+func newCodecTarget(vType reflect.Type) reflect.Value {
+	elemPtr := reflect.New(vType)
+	if vType.Kind() == reflect.Ptr {
+		elemPtr.Elem().Set(reflect.New(vType.Elem()))
+	}
+	return elemPtr
+}
+
+func codecTargetInterface(elemPtr reflect.Value) interface{} {
+	if elemPtr.Elem().Kind() == reflect.Ptr {
+		return elemPtr.Elem().Interface()
+	}
+	return elemPtr.Interface()
+}
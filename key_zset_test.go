@@ -0,0 +1,60 @@
+package redisdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/doptime/redisdb"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestZSetKeyScores(t *testing.T) {
+	_, rds := newServer(t)
+	rank := redisdb.NewZSetKey[string, string](rds.Key("rank"))
+	if err := rank.ZAdd(redis.Z{Score: 1, Member: "a"}, redis.Z{Score: 3, Member: "c"}, redis.Z{Score: 2, Member: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	members, err := rank.ZRange(0, -1)
+	if err != nil || len(members) != 3 || members[0] != "a" || members[2] != "c" {
+		t.Fatalf("ZRange = %v, %v", members, err)
+	}
+	if score, err := rank.ZIncrBy(5, "a"); err != nil || score != 6 {
+		t.Fatalf("ZIncrBy = %v, %v", score, err)
+	}
+	if r, err := rank.ZRevRank("a"); err != nil || r != 0 {
+		t.Fatalf("ZRevRank = %d, %v", r, err)
+	}
+	if _, err = rank.ZScore("missing"); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("ZScore missing: %v, want ErrNotFound", err)
+	}
+	if err = rank.ZRem("b"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := rank.ZCard(); n != 2 {
+		t.Fatalf("ZCard = %d", n)
+	}
+}
+
+func TestZSetKeyStructMembers(t *testing.T) {
+	_, rds := newServer(t)
+	rank := redisdb.NewZSetKey[string, *User](rds.Key("rank"))
+	alice := &User{ID: "u1", Name: "alice"}
+	if err := rank.ZAdd(redis.Z{Score: 10, Member: alice}); err != nil {
+		t.Fatal(err)
+	}
+	if score, err := rank.ZScore(alice); err != nil || score != 10 {
+		t.Fatalf("ZScore = %v, %v", score, err)
+	}
+	members, scores, err := rank.ZRangeWithScores(0, -1)
+	if err != nil || len(members) != 1 || members[0].Name != "alice" || scores[0] != 10 {
+		t.Fatalf("ZRangeWithScores = %v %v, %v", members, scores, err)
+	}
+	for m, err := range rank.ScanAll() {
+		if err != nil || m.Member.ID != "u1" || m.Score != 10 {
+			t.Fatalf("ScanAll yielded %+v, %v", m, err)
+		}
+	}
+	if scanned, _, err := rank.ZScan(0, "", 10); err != nil || len(scanned) != 1 || scanned[0].Name != "alice" {
+		t.Fatalf("ZScan = %v, %v", scanned, err)
+	}
+}
@@ -0,0 +1,210 @@
+package redisdb
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/doptime/logger"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// loadMetaPrefix prefixes the keys holding the load state of GetOrLoad / HGetOrLoad values:
+// a string "@load:<key>:<field>" for StringKey, a hash "@load:<key>" for HashKey.
+// the state is "<fresh until, unix ms>" for a loaded value, "-<until, unix ms>" for a cached not found
+const loadMetaPrefix = "@load:"
+
+// loads deduplicates concurrent loads of the same value in process
+var loads singleflight.Group
+
+// loadTarget is where a loaded value and its load state are kept
+type loadTarget struct {
+	flight string
+	field  string
+	// read queues the reads of the value and its state
+	read func(c context.Context, pipe redis.Pipeliner) (data, state *redis.StringCmd)
+	// store queues the write of the value (valStr) or its removal (not found), and of state, expiring after expire (0: never)
+	store func(c context.Context, pipe redis.Pipeliner, valStr string, found bool, state string, expire time.Duration)
+	// keys are the redis keys to drop from the local cache after a store
+	keys []string
+}
+
+// parseLoadState returns the fresh until time of a loaded value, or the until time of a cached not found
+func parseLoadState(state string) (freshUntil, notFoundUntil int64) {
+	if until, ok := strings.CutPrefix(state, "-"); ok {
+		notFoundUntil, _ = strconv.ParseInt(until, 10, 64)
+		return 0, notFoundUntil
+	}
+	freshUntil, _ = strconv.ParseInt(state, 10, 64)
+	return freshUntil, 0
+}
+
+// jitter spreads ttl by ±TTLJitter
+func (ctx *RedisKey[k, v]) jitter(ttl time.Duration) time.Duration {
+	if ctx.TTLJitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration((rand.Float64()*2-1)*ctx.TTLJitter*float64(ttl))
+}
+
+// getOrLoad reads the value of t; loader is called on a miss, one call per value at a time in process.
+// a value past its ttl but within StaleTTL is returned while a background load refreshes it. ttl 0 is DefaultTTL
+func (ctx *RedisKey[k, v]) getOrLoad(t loadTarget, key k, loader func(k) (v, error), ttl time.Duration) (value v, err error) {
+	if ttl <= 0 {
+		ttl = ctx.DefaultTTL
+	}
+	pipe := ctx.reader().Pipeline()
+	data, state := t.read(ctx.Context, pipe)
+	if _, err = pipe.Exec(ctx.Context); err != nil && err != redis.Nil {
+		return value, err
+	}
+	freshUntil, notFoundUntil := parseLoadState(state.Val())
+	now := time.Now().UnixMilli()
+	if raw, err := data.Bytes(); err == nil {
+		if value, err = ctx.decodeValue(t.field, raw); err != nil {
+			return value, err
+		}
+		// a value without load state was written by something else than the loader, it is used as is
+		if freshUntil == 0 || now < freshUntil {
+			return value, nil
+		}
+		if now < freshUntil+ctx.StaleTTL.Milliseconds() {
+			loads.DoChan(t.flight, func() (interface{}, error) {
+				value, err := ctx.load(t, key, loader, ttl)
+				if err != nil && !errors.Is(err, ErrNotFound) {
+					logger.Info().Err(err).Str("key", ctx.Key).Str("field", t.field).Msg("redisdb: background reload failed, stale value kept")
+				}
+				return value, err
+			})
+			return value, nil
+		}
+	} else if err != redis.Nil {
+		return value, err
+	} else if now < notFoundUntil {
+		return value, ErrNotFound
+	}
+
+	ret, err, _ := loads.Do(t.flight, func() (interface{}, error) {
+		return ctx.load(t, key, loader, ttl)
+	})
+	if value, ok := ret.(v); ok {
+		return value, err
+	}
+	// the flight was started by a key of another value type on the same redis key
+	return ctx.load(t, key, loader, ttl)
+}
+
+// load calls loader and stores the result: the value with a jittered ttl, or a not found for NegativeTTL.
+// it is not canceled with the caller's context, other callers may be waiting for it
+func (ctx *RedisKey[k, v]) load(t loadTarget, key k, loader func(k) (v, error), ttl time.Duration) (value v, err error) {
+	c := context.WithoutCancel(ctx.Context)
+	value, err = loader(key)
+	notFound := errors.Is(err, ErrNotFound) || errors.Is(err, redis.Nil)
+	if err != nil && !notFound {
+		return value, err
+	}
+	pipe := ctx.rds().Pipeline()
+	defer ctx.uncache(t.keys...)
+	if notFound {
+		if ctx.NegativeTTL <= 0 {
+			return value, ErrNotFound
+		}
+		state := "-" + strconv.FormatInt(time.Now().Add(ctx.NegativeTTL).UnixMilli(), 10)
+		t.store(c, pipe, "", false, state, ctx.NegativeTTL)
+		if _, err = pipe.Exec(c); err != nil {
+			return value, err
+		}
+		return value, ErrNotFound
+	}
+	value = ctx.V(value)
+	valStr, err := ctx.SerializeValue(value)
+	if err != nil {
+		return value, err
+	}
+	var state string
+	var expire time.Duration
+	if ttl = ctx.jitter(ttl); ttl > 0 {
+		state = strconv.FormatInt(time.Now().Add(ttl).UnixMilli(), 10)
+		expire = ttl + ctx.StaleTTL
+	}
+	t.store(c, pipe, valStr, true, state, expire)
+	_, err = pipe.Exec(c)
+	return value, err
+}
+
+// GetOrLoad returns the value of key, calling loader and storing its result for ttl if key doesn't exist.
+// ttl 0 is the DefaultTTL of the key, no expiry without one.
+// concurrent misses of the same key share one loader call. a loader returning ErrNotFound is remembered for NegativeTTL,
+// see WithNegativeCache, WithJitter and WithStaleWhileRevalidate. the load state is kept in the key "@load:<key>"
+func (ctx *StringKey[k, v]) GetOrLoad(key k, loader func(k) (v, error), ttl time.Duration) (value v, err error) {
+	keyStr, err := ctx.SerializeKey(key)
+	if err != nil {
+		return value, err
+	}
+	fullKey := ctx.Key + ":" + keyStr
+	stateKey := loadMetaPrefix + fullKey
+	return ctx.getOrLoad(loadTarget{
+		flight: ctx.RdsName + "\x00" + fullKey,
+		field:  keyStr,
+		keys:   []string{fullKey},
+		read: func(c context.Context, pipe redis.Pipeliner) (data, state *redis.StringCmd) {
+			return pipe.Get(c, fullKey), pipe.Get(c, stateKey)
+		},
+		store: func(c context.Context, pipe redis.Pipeliner, valStr string, found bool, state string, expire time.Duration) {
+			if !found {
+				pipe.Del(c, fullKey)
+			} else {
+				pipe.Set(c, fullKey, valStr, expire)
+			}
+			if state == "" {
+				pipe.Del(c, stateKey)
+			} else {
+				pipe.Set(c, stateKey, state, expire)
+			}
+		},
+	}, key, loader, ttl)
+}
+
+// HGetOrLoad returns the value of field, calling loader and storing its result if field doesn't exist.
+// the load state of the fields is kept in the hash "@load:<key>", see GetOrLoad. on Redis 7.4+ a loaded field and its state
+// expire together after ttl (plus StaleTTL) with HPEXPIRE. older servers have no field ttl: the field is kept, and its state
+// with it, so after ttl the field is reloaded by the next HGetOrLoad while HGet keeps returning it
+func (ctx *HashKey[k, v]) HGetOrLoad(field k, loader func(k) (v, error), ttl time.Duration) (value v, err error) {
+	fieldStr, err := ctx.SerializeKey(field)
+	if err != nil {
+		return value, err
+	}
+	stateKey := loadMetaPrefix + ctx.Key
+	return ctx.getOrLoad(loadTarget{
+		flight: ctx.RdsName + "\x00" + ctx.Key + "\x00" + fieldStr,
+		field:  fieldStr,
+		keys:   []string{ctx.Key},
+		read: func(c context.Context, pipe redis.Pipeliner) (data, state *redis.StringCmd) {
+			return pipe.HGet(c, ctx.Key, fieldStr), pipe.HGet(c, stateKey, fieldStr)
+		},
+		store: func(c context.Context, pipe redis.Pipeliner, valStr string, found bool, state string, expire time.Duration) {
+			// the state of a field never expires before the field: both expire, or neither
+			fieldTTL := expire > 0 && ctx.probeFieldTTL(fieldStr) == nil
+			if !found {
+				pipe.HDel(c, ctx.Key, fieldStr)
+			} else {
+				pipe.HSet(c, ctx.Key, fieldStr, valStr)
+				if fieldTTL {
+					pipe.HPExpire(c, ctx.Key, expire, fieldStr)
+				}
+			}
+			if state == "" {
+				pipe.HDel(c, stateKey, fieldStr)
+				return
+			}
+			pipe.HSet(c, stateKey, fieldStr, state)
+			if fieldTTL {
+				pipe.HPExpire(c, stateKey, expire, fieldStr)
+			}
+		},
+	}, field, loader, ttl)
+}
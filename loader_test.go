@@ -0,0 +1,200 @@
+package redisdb_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/doptime/redisdb"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	srv, rds := newServer(t)
+	users := redisdb.NewStringKey[string, *User](rds.Key("user"))
+	var calls atomic.Int32
+	loader := func(id string) (*User, error) {
+		calls.Add(1)
+		return &User{ID: id, Name: "alice"}, nil
+	}
+	for i := 0; i < 2; i++ {
+		u, err := users.GetOrLoad("u1", loader, time.Minute)
+		if err != nil || u.Name != "alice" {
+			t.Fatalf("GetOrLoad = %+v, %v", u, err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("loader called %d times, want 1", calls.Load())
+	}
+	if ttl := srv.TTL("user:u1"); ttl != time.Minute {
+		t.Fatalf("ttl = %v", ttl)
+	}
+	// the loaded value is a plain value of the key
+	if u, err := users.Get("u1"); err != nil || u.Name != "alice" {
+		t.Fatalf("Get = %+v, %v", u, err)
+	}
+
+	// a value written without the loader is used as is
+	users.Set("u2", &User{ID: "u2", Name: "bob"}, 0)
+	if u, _ := users.GetOrLoad("u2", loader, time.Minute); u.Name != "bob" || calls.Load() != 1 {
+		t.Fatalf("GetOrLoad = %+v, loader called %d times", u, calls.Load())
+	}
+
+	srv.FastForward(2 * time.Minute)
+	users.GetOrLoad("u1", loader, time.Minute)
+	if calls.Load() != 2 {
+		t.Fatalf("loader called %d times after expiry, want 2", calls.Load())
+	}
+}
+
+func TestGetOrLoadSingleflight(t *testing.T) {
+	_, rds := newServer(t)
+	users := redisdb.NewHashKey[string, *User](rds.Key("users"))
+	var calls atomic.Int32
+	loader := func(id string) (*User, error) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return &User{ID: id, Name: "alice"}, nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if u, err := users.HGetOrLoad("u1", loader, time.Minute); err != nil || u.Name != "alice" {
+				t.Errorf("HGetOrLoad = %+v, %v", u, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Fatalf("loader called %d times, want 1", calls.Load())
+	}
+}
+
+func TestGetOrLoadNegativeCache(t *testing.T) {
+	srv, rds := newServer(t)
+	var calls atomic.Int32
+	loader := func(id string) (string, error) {
+		calls.Add(1)
+		return "", redisdb.ErrNotFound
+	}
+
+	plain := redisdb.NewStringKey[string, string](rds.Key("plain"))
+	plain.GetOrLoad("a", loader, time.Minute)
+	plain.GetOrLoad("a", loader, time.Minute)
+	if calls.Load() != 2 {
+		t.Fatalf("loader called %d times without negative cache, want 2", calls.Load())
+	}
+
+	calls.Store(0)
+	names := redisdb.NewStringKey[string, string](rds.Key("name").NegativeCache(time.Minute))
+	for i := 0; i < 2; i++ {
+		if _, err := names.GetOrLoad("a", loader, time.Minute); !errors.Is(err, redisdb.ErrNotFound) {
+			t.Fatalf("GetOrLoad = %v, want ErrNotFound", err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("loader called %d times, want 1", calls.Load())
+	}
+	srv.FastForward(2 * time.Minute)
+	names.GetOrLoad("a", loader, time.Minute)
+	if calls.Load() != 2 {
+		t.Fatalf("loader called %d times after the negative ttl, want 2", calls.Load())
+	}
+
+	calls.Store(0)
+	fields := redisdb.NewHashKey[string, string](rds.Key("fields").NegativeCache(20 * time.Millisecond))
+	fields.HGetOrLoad("a", loader, time.Minute)
+	fields.HGetOrLoad("a", loader, time.Minute)
+	time.Sleep(40 * time.Millisecond)
+	fields.HGetOrLoad("a", loader, time.Minute)
+	if calls.Load() != 2 {
+		t.Fatalf("loader called %d times, want 2", calls.Load())
+	}
+	// a value written in between wins over the cached not found
+	fields.HSet("a", "set")
+	if v, err := fields.HGetOrLoad("a", loader, time.Minute); err != nil || v != "set" {
+		t.Fatalf("HGetOrLoad = %q, %v", v, err)
+	}
+}
+
+func TestGetOrLoadStaleWhileRevalidate(t *testing.T) {
+	_, rds := newServer(t)
+	prices := redisdb.NewHashKey[string, int](rds.Key("price").StaleWhileRevalidate(time.Minute))
+	var price atomic.Int32
+	price.Store(1)
+	loader := func(string) (int, error) { return int(price.Load()), nil }
+
+	prices.HGetOrLoad("p", loader, 20*time.Millisecond)
+	price.Store(2)
+	time.Sleep(40 * time.Millisecond)
+	if v, _ := prices.HGetOrLoad("p", loader, 20*time.Millisecond); v != 1 {
+		t.Fatalf("HGetOrLoad = %d, want the stale value 1", v)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		if v, _ := prices.HGet("p"); v == 2 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("HGet = %d, not refreshed in the background", v)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// without a stale window an expired field is reloaded before returning
+	fresh := redisdb.NewHashKey[string, int](rds.Key("fresh"))
+	price.Store(1)
+	fresh.HGetOrLoad("p", loader, 20*time.Millisecond)
+	price.Store(2)
+	time.Sleep(40 * time.Millisecond)
+	if v, _ := fresh.HGetOrLoad("p", loader, 20*time.Millisecond); v != 2 {
+		t.Fatalf("HGetOrLoad = %d, want 2", v)
+	}
+}
+
+func TestGetOrLoadJitter(t *testing.T) {
+	srv, rds := newServer(t)
+	keys := redisdb.NewStringKey[int, int](rds.Key("j").Jitter(0.1))
+	loader := func(i int) (int, error) { return i, nil }
+	for i := 0; i < 20; i++ {
+		keys.GetOrLoad(i, loader, 1000*time.Second)
+	}
+	distinct := map[time.Duration]bool{}
+	for _, key := range srv.Keys() {
+		if ttl := srv.TTL(key); ttl < 900*time.Second || ttl > 1100*time.Second {
+			t.Fatalf("ttl of %s = %v, want 1000s ±10%%", key, ttl)
+		} else {
+			distinct[ttl] = true
+		}
+	}
+	if len(distinct) < 2 {
+		t.Fatalf("ttls are not spread: %v", distinct)
+	}
+}
+
+func TestGetOrLoadStateTTL(t *testing.T) {
+	srv, rds := newServer(t)
+	loader := func(id string) (string, error) { return "v-" + id, nil }
+
+	// ttl 0 is the DefaultTTL of the key
+	names := redisdb.NewStringKey[string, string](rds.Key("name").TTL(time.Hour))
+	names.GetOrLoad("a", loader, 0)
+	if ttl := srv.TTL("name:a"); ttl != time.Hour {
+		t.Fatalf("ttl with DefaultTTL = %v, want 1h", ttl)
+	}
+
+	// without field ttl (the in-memory server has no HPEXPIRE) the load state is kept with its field,
+	// so a loaded field is still reloaded after ttl, however long it wasn't read
+	var calls atomic.Int32
+	fields := redisdb.NewHashKey[string, string](rds.Key("fields"))
+	counted := func(id string) (string, error) { calls.Add(1); return loader(id) }
+	fields.HGetOrLoad("a", counted, 20*time.Millisecond)
+	srv.FastForward(24 * time.Hour)
+	time.Sleep(40 * time.Millisecond)
+	fields.HGetOrLoad("a", counted, 20*time.Millisecond)
+	if calls.Load() != 2 {
+		t.Fatalf("loader called %d times, want 2", calls.Load())
+	}
+}
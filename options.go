@@ -1,12 +1,29 @@
 // package do stands for data options
 package redisdb
 
+import "time"
+
 // Option is parameter to create an API, RPC, or CallAt
 type Option struct {
 	RedisKey        string
 	KeyType         KeyType
 	RedisDataSource string
 	Modifiers       map[string]ModifierFunc
+	ValueCodec      Codec
+
+	Compression       Compression
+	CompressThreshold int
+	KeyRing           KeyProvider
+	ReadDataSources   []string
+	CacheSize         int
+	CacheTTL          time.Duration
+	NegativeTTL       time.Duration
+	TTLJitter         float64
+	StaleTTL          time.Duration
+	DefaultTTL        time.Duration
+	SlidingExpiry     bool
+	StreamMaxLen      int64
+	StreamRetention   time.Duration
 }
 
 var Opt = Option{
@@ -18,6 +35,14 @@ var Opt = Option{
 func (i Option) cp(o *Option) {
 	o.RedisKey = i.RedisKey
 	o.RedisDataSource = i.RedisDataSource
+	o.ValueCodec = i.ValueCodec
+	o.Compression, o.CompressThreshold = i.Compression, i.CompressThreshold
+	o.KeyRing = i.KeyRing
+	o.ReadDataSources = append([]string(nil), i.ReadDataSources...)
+	o.CacheSize, o.CacheTTL = i.CacheSize, i.CacheTTL
+	o.NegativeTTL, o.TTLJitter, o.StaleTTL = i.NegativeTTL, i.TTLJitter, i.StaleTTL
+	o.DefaultTTL, o.SlidingExpiry = i.DefaultTTL, i.SlidingExpiry
+	o.StreamMaxLen, o.StreamRetention = i.StreamMaxLen, i.StreamRetention
 	o.Modifiers = map[string]ModifierFunc{}
 	for k, v := range i.Modifiers {
 		o.Modifiers[k] = v
@@ -61,3 +86,154 @@ func WithModifier(extraModifiers map[string]ModifierFunc) (o Option) {
 	}
 	return
 }
+
+// Codec sets the codec used to encode non-primitive values. default is MsgpackCodec
+func (i Option) Codec(codec Codec) (o Option) {
+	i.cp(&o)
+	o.ValueCodec = codec
+	return
+}
+func WithCodec(codec Codec) (o Option) {
+	Opt.cp(&o)
+	o.ValueCodec = codec
+	return
+}
+
+// Compress compresses serialized values whose size >= threshold bytes. threshold <= 0 means DefaultCompressThreshold
+func (i Option) Compress(algo Compression, threshold int) (o Option) {
+	i.cp(&o)
+	o.Compression, o.CompressThreshold = algo, threshold
+	return
+}
+func WithCompression(algo Compression, threshold int) (o Option) {
+	Opt.cp(&o)
+	o.Compression, o.CompressThreshold = algo, threshold
+	return
+}
+
+// KeyProvider sets the keys used by `encrypt` tagged fields. default is DefaultKeyProvider
+func (i Option) KeyProvider(keys KeyProvider) (o Option) {
+	i.cp(&o)
+	o.KeyRing = keys
+	return
+}
+func WithKeyProvider(keys KeyProvider) (o Option) {
+	Opt.cp(&o)
+	o.KeyRing = keys
+	return
+}
+
+// ReadRds sends the read commands of the key to the data sources dataSources, round robin; writes still go to Rds.
+// a replica that is not available is skipped, and the primary is read if none is
+func (i Option) ReadRds(dataSources ...string) (o Option) {
+	i.cp(&o)
+	o.ReadDataSources = dataSources
+	return
+}
+func WithReadRds(dataSources ...string) (o Option) {
+	Opt.cp(&o)
+	o.ReadDataSources = dataSources
+	return
+}
+
+// LocalCache keeps up to size values of HashKey.HGet / StringKey.Get in process, each for at most ttl.
+// entries are invalidated by CLIENT TRACKING when the data source supports it, and by writes through the key.
+// values are decoded on every read, misses are read from the primary even with ReadRds.
+// without CLIENT TRACKING (cluster, ring) nothing is cached unless ttl > 0
+func (i Option) LocalCache(size int, ttl time.Duration) (o Option) {
+	i.cp(&o)
+	o.CacheSize, o.CacheTTL = size, ttl
+	return
+}
+func WithLocalCache(size int, ttl time.Duration) (o Option) {
+	Opt.cp(&o)
+	o.CacheSize, o.CacheTTL = size, ttl
+	return
+}
+
+// NegativeCache makes GetOrLoad / HGetOrLoad remember for ttl that the loader returned ErrNotFound,
+// so a missing row isn't looked up again on every read
+func (i Option) NegativeCache(ttl time.Duration) (o Option) {
+	i.cp(&o)
+	o.NegativeTTL = ttl
+	return
+}
+func WithNegativeCache(ttl time.Duration) (o Option) {
+	Opt.cp(&o)
+	o.NegativeTTL = ttl
+	return
+}
+
+// Jitter spreads the ttl of values stored by GetOrLoad / HGetOrLoad by ±fraction (0.1 = ±10%), so keys loaded together don't expire together
+func (i Option) Jitter(fraction float64) (o Option) {
+	i.cp(&o)
+	o.TTLJitter = fraction
+	return
+}
+func WithJitter(fraction float64) (o Option) {
+	Opt.cp(&o)
+	o.TTLJitter = fraction
+	return
+}
+
+// StaleWhileRevalidate lets GetOrLoad / HGetOrLoad return a value up to window after its ttl,
+// while one background load refreshes it
+func (i Option) StaleWhileRevalidate(window time.Duration) (o Option) {
+	i.cp(&o)
+	o.StaleTTL = window
+	return
+}
+func WithStaleWhileRevalidate(window time.Duration) (o Option) {
+	Opt.cp(&o)
+	o.StaleTTL = window
+	return
+}
+
+// TTL expires the key ttl after each write that adds or changes values (SET without expiration, HSET, RPUSH, SADD, ZADD, XADD...).
+// for StringKey it applies to each string key
+func (i Option) TTL(ttl time.Duration) (o Option) {
+	i.cp(&o)
+	o.DefaultTTL = ttl
+	return
+}
+func WithTTL(ttl time.Duration) (o Option) {
+	Opt.cp(&o)
+	o.DefaultTTL = ttl
+	return
+}
+
+// SlidingTTL is TTL, with the ttl also restarted by reads, so keys in use don't expire
+func (i Option) SlidingTTL(ttl time.Duration) (o Option) {
+	i.cp(&o)
+	o.DefaultTTL, o.SlidingExpiry = ttl, true
+	return
+}
+func WithSlidingTTL(ttl time.Duration) (o Option) {
+	Opt.cp(&o)
+	o.DefaultTTL, o.SlidingExpiry = ttl, true
+	return
+}
+
+// MaxLen caps a StreamKey to about maxLen entries: every XAdd trims it with MAXLEN ~
+func (i Option) MaxLen(maxLen int64) (o Option) {
+	i.cp(&o)
+	o.StreamMaxLen = maxLen
+	return
+}
+func WithMaxLen(maxLen int64) (o Option) {
+	Opt.cp(&o)
+	o.StreamMaxLen = maxLen
+	return
+}
+
+// Retention keeps the entries of a StreamKey for about d: every XAdd trims the older ones with MINID ~, computed from the entry IDs
+func (i Option) Retention(d time.Duration) (o Option) {
+	i.cp(&o)
+	o.StreamRetention = d
+	return
+}
+func WithRetention(d time.Duration) (o Option) {
+	Opt.cp(&o)
+	o.StreamRetention = d
+	return
+}
@@ -1,8 +1,119 @@
 package redisdb
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/doptime/config/cfgredis"
 	cmap "github.com/orcaman/concurrent-map/v2"
 	"github.com/redis/go-redis/v9"
 )
 
-var RdsSources cmap.ConcurrentMap[string, *redis.Client] = cmap.New[*redis.Client]()
+// RdsSources holds data sources that are not (or can't be) declared in config.toml,
+// e.g. redis.NewClusterClient / redis.NewFailoverClient / redis.NewUniversalClient.
+// a name here takes precedence over the same name in cfgredis.Servers
+var RdsSources cmap.ConcurrentMap[string, redis.UniversalClient] = cmap.New[redis.UniversalClient]()
+
+// RegisterDataSource registers client as data source name, replacing any previous one.
+// keys resolve their data source on every command, so keys created earlier (e.g. package-level vars) pick it up
+func RegisterDataSource(name string, client redis.UniversalClient) {
+	if client == nil {
+		UnregisterDataSource(name)
+		return
+	}
+	RdsSources.Set(name, client)
+}
+
+// UnregisterDataSource removes a data source registered with RegisterDataSource. the client is not closed.
+// a data source of the same name in config.toml becomes visible again
+func UnregisterDataSource(name string) {
+	RdsSources.Remove(name)
+}
+
+// getDataSource resolves a data source name, RdsSources first, then cfgredis.Servers
+func getDataSource(name string) (rds redis.UniversalClient, ok bool) {
+	if rds, ok = RdsSources.Get(name); ok && rds != nil {
+		return rds, true
+	}
+	// avoid storing a typed nil *redis.Client into the interface
+	if client, ok := cfgredis.Servers.Get(name); ok && client != nil {
+		return client, true
+	}
+	return nil, false
+}
+
+// unavailableClients caches the clients returned for data sources that are not configured
+var unavailableClients cmap.ConcurrentMap[string, redis.UniversalClient] = cmap.New[redis.UniversalClient]()
+
+// dataSource is getDataSource for commands: a missing data source yields a client whose every command
+// fails with ErrDataSourceUnavailable, so the caller gets an error instead of a nil pointer
+func dataSource(name string) redis.UniversalClient {
+	if rds, ok := getDataSource(name); ok {
+		return rds
+	}
+	return unavailableClients.Upsert(name, nil, func(exist bool, old, _ redis.UniversalClient) redis.UniversalClient {
+		if exist {
+			return old
+		}
+		return newFailingClient(fmt.Errorf("%w: %s", ErrDataSourceUnavailable, name))
+	})
+}
+
+// newFailingClient returns a client that never connects; every command, pipeline and dial fails with err
+func newFailingClient(err error) redis.UniversalClient {
+	client := redis.NewClient(&redis.Options{Addr: "unavailable:0", MaxRetries: -1})
+	client.AddHook(failingHook{err})
+	return client
+}
+
+type failingHook struct{ err error }
+
+func (h failingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(c context.Context, network, addr string) (net.Conn, error) {
+		return nil, h.err
+	}
+}
+func (h failingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(c context.Context, cmd redis.Cmder) error {
+		cmd.SetErr(h.err)
+		return h.err
+	}
+}
+func (h failingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(c context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			cmd.SetErr(h.err)
+		}
+		return h.err
+	}
+}
+
+// forEachMaster runs fn on every master node of a cluster (or every shard of a ring), concurrently.
+// for a single node / sentinel client fn is run once on the client itself.
+func forEachMaster(c context.Context, rds redis.UniversalClient, fn func(c context.Context, node redis.UniversalClient) error) error {
+	switch client := rds.(type) {
+	case *redis.ClusterClient:
+		return client.ForEachMaster(c, func(c context.Context, node *redis.Client) error { return fn(c, node) })
+	case *redis.Ring:
+		return client.ForEachShard(c, func(c context.Context, node *redis.Client) error { return fn(c, node) })
+	}
+	return fn(c, rds)
+}
+
+// collectFromMasters runs fn on every master and concatenates the returned strings
+func collectFromMasters(c context.Context, rds redis.UniversalClient, fn func(c context.Context, node redis.UniversalClient) ([]string, error)) (all []string, err error) {
+	var mu sync.Mutex
+	err = forEachMaster(c, rds, func(c context.Context, node redis.UniversalClient) error {
+		vals, err := fn(c, node)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		all = append(all, vals...)
+		mu.Unlock()
+		return nil
+	})
+	return all, err
+}
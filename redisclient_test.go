@@ -0,0 +1,60 @@
+package redisdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/doptime/redisdb"
+	"github.com/doptime/redisdb/redistest"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestKeyResolvesDataSourceLazily(t *testing.T) {
+	name := t.Name()
+	// declared before the data source exists, like a package-level var
+	users := redisdb.NewHashKey[string, *User](redisdb.WithKey("users"), redisdb.WithRds(name))
+	if users == nil {
+		t.Fatal("NewHashKey returned nil for a data source registered later")
+	}
+	if _, err := users.HSet("u1", &User{ID: "u1"}); !errors.Is(err, redisdb.ErrDataSourceUnavailable) {
+		t.Fatalf("HSet before registration: %v, want ErrDataSourceUnavailable", err)
+	}
+	if err := redisdb.Tx(name, func(tx *redisdb.TxCtx) error { return nil }); !errors.Is(err, redisdb.ErrDataSourceUnavailable) {
+		t.Fatalf("Tx before registration: %v, want ErrDataSourceUnavailable", err)
+	}
+
+	m1, m2 := miniredis.RunT(t), miniredis.RunT(t)
+	redisdb.RegisterDataSource(name, redis.NewClient(&redis.Options{Addr: m1.Addr()}))
+	defer redisdb.UnregisterDataSource(name)
+	if _, err := users.HSet("u1", &User{ID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if !m1.Exists("users") {
+		t.Fatal("value not written to the registered data source")
+	}
+
+	// replacing the data source switches existing keys over
+	redisdb.RegisterDataSource(name, redis.NewClient(&redis.Options{Addr: m2.Addr()}))
+	if _, err := users.HGet("u1"); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("HGet on the new data source: %v, want ErrNotFound", err)
+	}
+
+	redisdb.UnregisterDataSource(name)
+	if _, err := users.HGet("u1"); !errors.Is(err, redisdb.ErrDataSourceUnavailable) {
+		t.Fatalf("HGet after unregistration: %v, want ErrDataSourceUnavailable", err)
+	}
+}
+
+func TestDuplicateSwitchesDataSource(t *testing.T) {
+	srv1, rds := newServer(t)
+	srv2 := redistest.Run(t, t.Name()+"-2")
+	users := redisdb.NewStringKey[string, string](rds.Key("users"))
+	other := users.Duplicate("users", srv2.Name)
+	if err := other.Set("u1", "x", 0); err != nil {
+		t.Fatal(err)
+	}
+	if srv1.Exists("users:u1") || !srv2.Exists("users:u1") {
+		t.Fatal("Duplicate kept writing to the original data source")
+	}
+}
@@ -0,0 +1,52 @@
+// Package redistest runs an in-process redis server registered as a redisdb data source,
+// so code using NewHashKey, NewZSetKey ... can be tested without a redis server.
+//
+// strings, hashes, lists, sets, sorted sets, streams, TTLs, MULTI/EXEC, WATCH and lua scripts are supported.
+// time doesn't pass by itself on the server: use FastForward to expire keys
+package redistest
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/doptime/redisdb"
+	"github.com/redis/go-redis/v9"
+)
+
+type Server struct {
+	*miniredis.Miniredis
+	// Name is the data source name, pass it with redisdb.WithRds
+	Name   string
+	Client *redis.Client
+}
+
+// NewServer starts a server and registers it as data source name. keys created before, e.g. package-level vars, use it too
+func NewServer(name string) (*Server, error) {
+	m, err := miniredis.Run()
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{Miniredis: m, Name: name, Client: redis.NewClient(&redis.Options{Addr: m.Addr()})}
+	redisdb.RegisterDataSource(name, s.Client)
+	return s, nil
+}
+
+// Run is NewServer for tests: it fails t on error and closes the server when t ends
+func Run(t testing.TB, name string) *Server {
+	t.Helper()
+	s, err := NewServer(name)
+	if err != nil {
+		t.Fatalf("redistest: %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Close unregisters the data source and stops the server
+func (s *Server) Close() {
+	if rds, ok := redisdb.RdsSources.Get(s.Name); ok && rds == redis.UniversalClient(s.Client) {
+		redisdb.UnregisterDataSource(s.Name)
+	}
+	s.Client.Close()
+	s.Miniredis.Close()
+}
@@ -0,0 +1,29 @@
+package redistest_test
+
+import (
+	"testing"
+
+	"github.com/doptime/redisdb"
+	"github.com/doptime/redisdb/redistest"
+)
+
+func TestRunRegistersDataSource(t *testing.T) {
+	srv := redistest.Run(t, "redistest")
+	if _, ok := redisdb.RdsSources.Get(srv.Name); !ok {
+		t.Fatal("data source not registered")
+	}
+	key := redisdb.NewStringKey[string, string](redisdb.WithKey("k"), redisdb.WithRds(srv.Name))
+	if key == nil {
+		t.Fatal("NewStringKey returned nil")
+	}
+	if err := key.Set("a", "b", 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := srv.Get("k:a"); got != "b" {
+		t.Fatalf("stored %q, want %q", got, "b")
+	}
+	srv.Close()
+	if _, ok := redisdb.RdsSources.Get(srv.Name); ok {
+		t.Fatal("data source still registered after Close")
+	}
+}
@@ -0,0 +1,204 @@
+package redisdb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/doptime/logger"
+	cmap "github.com/orcaman/concurrent-map/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// ScriptKey is any typed key passed to a script as KEYS. its Key is used as the redis key
+type ScriptKey interface {
+	GetKey() string
+}
+
+// GetKey returns the redis key (the prefix for StringKey)
+func (ctx *RedisKey[k, v]) GetKey() string {
+	return ctx.Key
+}
+
+// KeyName passes a plain redis key to a script
+type KeyName string
+
+func (k KeyName) GetKey() string { return string(k) }
+
+// ScriptValues is a typed key whose value encoding a script uses for its ARGV or its reply: any key with value type T.
+// the script runs on the data source of its args key
+type ScriptValues[T any] interface {
+	ScriptKey
+	scriptValues() scriptValues[T]
+}
+
+// scriptValues is what a script takes from a typed key: its data source and value encoding
+type scriptValues[T any] struct {
+	rdsName string
+	err     error
+	rds     func() redis.UniversalClient
+	encode  func(value interface{}) (string, error)
+	decode  func(field string, data []byte) (T, error)
+}
+
+func (ctx *RedisKey[k, v]) scriptValues() scriptValues[v] {
+	return scriptValues[v]{rdsName: ctx.RdsName, err: ctx.Err(), rds: ctx.rds, encode: ctx.SerializeValue, decode: ctx.decodeValue}
+}
+
+// IScript is the untyped view of a Script kept in ScriptMap
+type IScript interface {
+	Name() string
+	Hash() string
+	Load() error
+	rdsName() string
+}
+
+// ScriptMap holds every script created by NewScript, by "name:rdsName"
+var ScriptMap cmap.ConcurrentMap[string, IScript] = cmap.New[IScript]()
+
+// Script is a lua script whose ARGV are values of type Args and whose reply decodes to Result.
+// args are encoded with the SerializeValue of the args key and replies decoded with the DeserializeToValue of the result key
+// (codec, compression, encryption), so a script stores / returns the same bytes the keys read / write
+type Script[Args any, Result any] struct {
+	name   string
+	script *redis.Script
+	ctx    context.Context
+	err    error
+	args   scriptValues[Args]
+	result scriptValues[Result]
+}
+
+// NewScript registers a lua script under name, encoding ARGV like args and decoding the reply like result.
+// a script of the same name and data source with a different src is an error, see Err
+func NewScript[Args any, Result any](name string, src string, args ScriptValues[Args], result ScriptValues[Result]) *Script[Args, Result] {
+	s := &Script[Args, Result]{name: name, script: redis.NewScript(src), ctx: context.Background(),
+		args: args.scriptValues(), result: result.scriptValues()}
+	if s.err = s.args.err; s.err == nil {
+		s.err = s.result.err
+	}
+	if s.err == nil && !ScriptMap.SetIfAbsent(name+":"+s.args.rdsName, s) {
+		if existing, ok := ScriptMap.Get(name + ":" + s.args.rdsName); ok && existing.Hash() != s.Hash() {
+			s.err = fmt.Errorf("redisdb.NewScript: script %s is already registered on %s with a different source", name, s.args.rdsName)
+		}
+	}
+	if s.err != nil {
+		logger.Error().Err(s.err).Str("script", name).Msg("redisdb.NewScript failed")
+	}
+	return s
+}
+
+func (s *Script[Args, Result]) Name() string {
+	return s.name
+}
+
+// Err returns the configuration error the script was created with, including those of its keys; Run fails with it too
+func (s *Script[Args, Result]) Err() error {
+	return s.err
+}
+
+// Hash returns the SHA1 of the script
+func (s *Script[Args, Result]) Hash() string {
+	return s.script.Hash()
+}
+
+// WithCtx returns a copy of s whose commands run under c
+func (s *Script[Args, Result]) WithCtx(c context.Context) *Script[Args, Result] {
+	if c == nil {
+		c = context.Background()
+	}
+	ret := *s
+	ret.ctx = c
+	return &ret
+}
+
+// Load runs SCRIPT LOAD. on a cluster the script is loaded on every master
+func (s *Script[Args, Result]) Load() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.script.Load(s.ctx, s.args.rds()).Err()
+}
+
+// LoadScripts runs SCRIPT LOAD for every script created on the data source rdsName, e.g. after a failover
+func LoadScripts(rdsName string) (err error) {
+	for _, script := range ScriptMap.Items() {
+		if script.rdsName() != rdsName {
+			continue
+		}
+		if err = script.Load(); err != nil {
+			return fmt.Errorf("redisdb: load script %s: %w", script.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (s *Script[Args, Result]) rdsName() string {
+	return s.args.rdsName
+}
+
+// eval runs EVALSHA, loading the script and retrying once if redis answers NOSCRIPT
+func (s *Script[Args, Result]) eval(keys []ScriptKey, args []Args) (reply interface{}, err error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	keyStrs := make([]string, len(keys))
+	for i, key := range keys {
+		keyStrs[i] = key.GetKey()
+	}
+	argv := make([]interface{}, len(args))
+	for i, arg := range args {
+		if argv[i], err = s.args.encode(arg); err != nil {
+			return nil, err
+		}
+	}
+	c, rds := s.ctx, s.args.rds()
+	reply, err = s.script.EvalSha(c, rds, keyStrs, argv...).Result()
+	if redis.HasErrorPrefix(err, "NOSCRIPT") {
+		if err = s.script.Load(c, rds).Err(); err != nil {
+			return nil, err
+		}
+		reply, err = s.script.EvalSha(c, rds, keyStrs, argv...).Result()
+	}
+	return reply, asNotFound(err)
+}
+
+// decode converts a single (non array) reply to Result
+func (s *Script[Args, Result]) decode(reply interface{}) (ret Result, err error) {
+	switch val := reply.(type) {
+	case nil:
+		return ret, ErrNotFound
+	case string:
+		return s.result.decode(s.name, []byte(val))
+	case int64:
+		return s.result.decode(s.name, []byte(strconv.FormatInt(val, 10)))
+	}
+	return ret, fmt.Errorf("redisdb: script %s: unexpected reply type %T", s.name, reply)
+}
+
+// Run evaluates the script. a nil reply is returned as ErrNotFound; use RunValues for scripts returning an array
+func (s *Script[Args, Result]) Run(keys []ScriptKey, args ...Args) (ret Result, err error) {
+	reply, err := s.eval(keys, args)
+	if err != nil {
+		return ret, err
+	}
+	return s.decode(reply)
+}
+
+// RunValues evaluates a script that returns an array, decoding every element to Result
+func (s *Script[Args, Result]) RunValues(keys []ScriptKey, args ...Args) (rets []Result, err error) {
+	reply, err := s.eval(keys, args)
+	if err != nil {
+		return nil, err
+	}
+	vals, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("redisdb: script %s: reply is %T, not an array", s.name, reply)
+	}
+	rets = make([]Result, len(vals))
+	for i, val := range vals {
+		if rets[i], err = s.decode(val); err != nil {
+			return nil, err
+		}
+	}
+	return rets, nil
+}
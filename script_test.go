@@ -0,0 +1,64 @@
+package redisdb_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/doptime/redisdb"
+)
+
+func TestScriptRoundTrip(t *testing.T) {
+	srv, rds := newServer(t)
+	users := redisdb.NewHashKey[string, *User](rds.Key("users"))
+	put := redisdb.NewScript("put", `
+redis.call('HSET', KEYS[1], 'u1', ARGV[1])
+return redis.call('HGET', KEYS[1], 'u1')`, users, users)
+	u, err := put.Run([]redisdb.ScriptKey{users}, &User{ID: "u1", Name: "bob"})
+	if err != nil || u.Name != "bob" {
+		t.Fatalf("Run = %+v, %v", u, err)
+	}
+	// the value written by the script reads back through the typed key
+	if u, err = users.HGet("u1"); err != nil || u.Name != "bob" {
+		t.Fatalf("HGet = %+v, %v", u, err)
+	}
+
+	// NOSCRIPT after a flush is recovered by loading the script again
+	counters := redisdb.NewStringKey[string, int](rds.Key("n"))
+	incr := redisdb.NewScript("incr", `return redis.call('INCRBY', KEYS[1], ARGV[1])`, counters, counters)
+	if n, err := incr.Run([]redisdb.ScriptKey{redisdb.KeyName("n")}, 5); err != nil || n != 5 {
+		t.Fatalf("Run = %d, %v", n, err)
+	}
+	srv.Client.ScriptFlush(context.Background())
+	if n, err := incr.Run([]redisdb.ScriptKey{redisdb.KeyName("n")}, 5); err != nil || n != 10 {
+		t.Fatalf("Run after SCRIPT FLUSH = %d, %v", n, err)
+	}
+	none := redisdb.NewScript("none", `return false`, counters, counters)
+	if _, err = none.Run(nil); !errors.Is(err, redisdb.ErrNotFound) {
+		t.Fatalf("nil reply: %v, want ErrNotFound", err)
+	}
+}
+
+func TestScriptKeys(t *testing.T) {
+	srv, rds := newServer(t)
+	// args and replies use the encoding of the bound keys
+	docs := redisdb.NewHashKey[string, []byte](rds.Key("docs").Codec(redisdb.RawCodec).Compress(redisdb.CompressionZstd, 8))
+	echo := redisdb.NewScript("echo", `return ARGV[1]`, docs, docs)
+	if got, err := echo.Run(nil, []byte("compressed compressed compressed")); err != nil || string(got) != "compressed compressed compressed" {
+		t.Fatalf("Run = %q, %v", got, err)
+	}
+
+	// a name is bound to one source per data source
+	if dup := redisdb.NewScript("echo", `return ARGV[2]`, docs, docs); dup.Err() == nil {
+		t.Fatal("a second script named echo with another source: no error")
+	}
+	if again := redisdb.NewScript("echo", `return ARGV[1]`, docs, docs); again.Err() != nil {
+		t.Fatalf("the same script again: %v", again.Err())
+	}
+
+	// a script fails with the error of its keys
+	invalid := redisdb.NewHashKey[string, string](redisdb.Opt.Rds(srv.Name))
+	if _, err := redisdb.NewScript("invalid", `return 1`, invalid, docs).Run(nil, "x"); err == nil || !errors.Is(err, invalid.Err()) {
+		t.Fatalf("Run with an invalid key = %v, want %v", err, invalid.Err())
+	}
+}
@@ -3,11 +3,9 @@ package redisdb
 import (
 	"reflect"
 	"strconv"
-
-	"github.com/vmihailenco/msgpack/v5"
 )
 
-func (ctx *RedisKey[k, v]) getSerializeFun(typeofv reflect.Kind) func(val interface{}) (valueStr string, err error) {
+func (ctx *RedisKey[k, v]) getSerializeFun(typeofv reflect.Kind, codec Codec) func(val interface{}) (valueStr string, err error) {
 	//var typeofv = reflect.TypeOf((*v)(nil)).Elem().Kind()
 	switch typeofv {
 	//type string
@@ -78,7 +76,7 @@ func (ctx *RedisKey[k, v]) getSerializeFun(typeofv reflect.Kind) func(val interf
 		}
 	default:
 		return func(value interface{}) (valueStr string, err error) {
-			bytes, err := msgpack.Marshal(value)
+			bytes, err := codec.Marshal(value)
 			if err == nil {
 				return string(bytes), nil
 			}
@@ -0,0 +1,283 @@
+package redisdb
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// KeyProvider supplies the data keys used by `encrypt` tagged fields.
+// the key id is stored in every ciphertext, so old values stay readable after the current key is rotated.
+type KeyProvider interface {
+	// CurrentKey returns the key used to encrypt new values
+	CurrentKey() (keyID string, key []byte, err error)
+	// Key returns the key with the given id, used to decrypt
+	Key(keyID string) (key []byte, err error)
+}
+
+// StaticKeyProvider is a KeyProvider backed by an in-memory key ring. keys must be 16, 24 or 32 bytes (AES-128/192/256)
+type StaticKeyProvider struct {
+	CurrentID string
+	Keys      map[string][]byte
+}
+
+func (p *StaticKeyProvider) CurrentKey() (keyID string, key []byte, err error) {
+	key, err = p.Key(p.CurrentID)
+	return p.CurrentID, key, err
+}
+func (p *StaticKeyProvider) Key(keyID string) (key []byte, err error) {
+	if key, ok := p.Keys[keyID]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("encryption key not found: %s", keyID)
+}
+
+// DefaultKeyProvider is used by keys that are not created with WithKeyProvider
+var DefaultKeyProvider KeyProvider
+
+// ciphertext layout: encryptedPrefix + keyID + "$" + base64(nonce | sealed)
+const encryptedPrefix = "$enc$"
+
+// FieldEncryptor stores metadata for a struct field tagged with `encrypt:"aes-gcm"`.
+// Name is the stored name of the field (see storedFieldName), bound to its ciphertext as additional data
+type FieldEncryptor struct {
+	FieldIndex int
+	FieldName  string
+	Name       string
+	Algorithm  string
+}
+
+// newFieldEncryptor returns the encryptor of field, nil if it has no `encrypt` tag.
+// a tag that can't be honoured, an unknown algorithm or a field that isn't a string or []byte, is an ErrInvalidEncryptTag:
+// the field would otherwise be stored in plaintext.
+// called by newStructModifiers, the `encrypt` tags are registered along with the `mod` tags
+func newFieldEncryptor(index int, field reflect.StructField) (*FieldEncryptor, error) {
+	tag, ok := field.Tag.Lookup("encrypt")
+	if !ok {
+		return nil, nil
+	}
+	algo := strings.ToLower(tag)
+	if algo != "aes-gcm" {
+		return nil, fmt.Errorf("%w: field %s: algorithm %q, only aes-gcm is supported", ErrInvalidEncryptTag, field.Name, tag)
+	}
+	if field.Type.Kind() != reflect.String && !(field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Uint8) {
+		return nil, fmt.Errorf("%w: field %s: type %s, only string and []byte fields can be encrypted", ErrInvalidEncryptTag, field.Name, field.Type)
+	}
+	name, _ := storedFieldName(field)
+	return &FieldEncryptor{FieldIndex: index, FieldName: field.Name, Name: name, Algorithm: algo}, nil
+}
+
+func lookupStructEncryptors(val interface{}) (structValue reflect.Value, modifiers *StructModifiers, err error) {
+	if val == nil {
+		return structValue, nil, fmt.Errorf("nil value passed to field encryption")
+	}
+	if structValue, modifiers = lookupStructModifiers(val); modifiers == nil || len(modifiers.fieldEncryptors) == 0 {
+		return structValue, nil, nil
+	}
+	if !structValue.CanSet() {
+		return structValue, nil, fmt.Errorf("field encryption expects a pointer to struct, got %T", val)
+	}
+	return structValue, modifiers, nil
+}
+
+// EncryptFields encrypts the `encrypt` tagged fields of val in place. val must be a pointer to struct.
+// every non-empty field is encrypted, a plaintext that looks like a ciphertext included
+func EncryptFields(val interface{}, keys KeyProvider) error {
+	structValue, modifiers, err := lookupStructEncryptors(val)
+	if err != nil || modifiers == nil {
+		return err
+	}
+	if keys == nil {
+		return fmt.Errorf("no KeyProvider configured to encrypt %s", modifiers.ValType)
+	}
+	for _, fieldEncryptor := range modifiers.fieldEncryptors {
+		field := structValue.Field(fieldEncryptor.FieldIndex)
+		sealed, err := encryptText(keys, fieldEncryptor.Name, fieldBytes(field))
+		if err != nil {
+			return fmt.Errorf("encrypt field %s: %w", fieldEncryptor.FieldName, err)
+		}
+		setFieldBytes(field, sealed)
+	}
+	return nil
+}
+
+// DecryptFields decrypts the `encrypt` tagged fields of val in place. val must be a pointer to struct.
+// fields without the ciphertext prefix are treated as plaintext written before encryption was enabled.
+func DecryptFields(val interface{}, keys KeyProvider) error {
+	structValue, modifiers, err := lookupStructEncryptors(val)
+	if err != nil || modifiers == nil {
+		return err
+	}
+	for _, fieldEncryptor := range modifiers.fieldEncryptors {
+		field := structValue.Field(fieldEncryptor.FieldIndex)
+		plain, err := decryptText(keys, fieldEncryptor.Name, fieldBytes(field))
+		if err != nil {
+			return fmt.Errorf("decrypt field %s: %w", fieldEncryptor.FieldName, err)
+		}
+		setFieldBytes(field, plain)
+	}
+	return nil
+}
+
+// encryptText seals plain with the current key, name as additional data. empty values are left empty
+func encryptText(keys KeyProvider, name string, plain []byte) ([]byte, error) {
+	if len(plain) == 0 {
+		return plain, nil
+	}
+	if keys == nil {
+		return nil, fmt.Errorf("no KeyProvider configured")
+	}
+	keyID, key, err := keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := sealAESGCM(key, plain, []byte(name))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(encryptedPrefix + keyID + "$" + base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// decryptText opens a ciphertext written by encryptText. data without the ciphertext prefix is returned as is
+func decryptText(keys KeyProvider, name string, data []byte) ([]byte, error) {
+	if !strings.HasPrefix(string(data), encryptedPrefix) {
+		return data, nil
+	}
+	if keys == nil {
+		return nil, fmt.Errorf("no KeyProvider configured")
+	}
+	keyID, encoded, found := strings.Cut(string(data[len(encryptedPrefix):]), "$")
+	if !found {
+		return nil, fmt.Errorf("malformed ciphertext")
+	}
+	key, err := keys.Key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return openAESGCM(key, sealed, []byte(name))
+}
+
+func fieldBytes(field reflect.Value) []byte {
+	if field.Kind() == reflect.String {
+		return []byte(field.String())
+	}
+	return field.Bytes()
+}
+
+func setFieldBytes(field reflect.Value, data []byte) {
+	if field.Kind() == reflect.String {
+		field.SetString(string(data))
+		return
+	}
+	field.SetBytes(data)
+}
+
+// sealAESGCM returns nonce | ciphertext. the stored field name is used as additional data, so ciphertexts can't be swapped between
+// fields, while renaming the Go field (keeping its tag) leaves them readable
+func sealAESGCM(key, plain, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plain)+gcm.Overhead())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, additionalData), nil
+}
+
+func openAESGCM(key, sealed, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], additionalData)
+}
+
+func (ctx *RedisKey[k, v]) keyProvider() KeyProvider {
+	if ctx.KeyProvider != nil {
+		return ctx.KeyProvider
+	}
+	return DefaultKeyProvider
+}
+
+// checkMatchableMembers rejects `encrypt` tagged values on the keys that match members byte by byte (SRem, LRem, ZScore...)
+func (ctx *RedisKey[k, v]) checkMatchableMembers() error {
+	if ctx.UseEncryptor {
+		return fmt.Errorf("%w: %s", ErrEncryptedMember, reflect.TypeOf((*v)(nil)).Elem())
+	}
+	return nil
+}
+
+// wrapEncryption encrypts a copy of the value before serialization, so the caller's struct is never modified,
+// and decrypts values after deserialization.
+func (ctx *RedisKey[k, v]) wrapEncryption() {
+	if !ctx.UseEncryptor {
+		return
+	}
+	serialize, deserialize, deserializes := ctx.SerializeValue, ctx.DeserializeToValue, ctx.DeserializeToValues
+	ctx.SerializeValue = func(value interface{}) (string, error) {
+		in, ok := value.(v)
+		if !ok {
+			return serialize(value)
+		}
+		encrypted := copyStruct(in)
+		if err := EncryptFields(encrypted.Addr().Interface(), ctx.keyProvider()); err != nil {
+			return "", err
+		}
+		return serialize(encrypted.Interface())
+	}
+	ctx.DeserializeToValue = func(data []byte) (value v, err error) {
+		if value, err = deserialize(data); err != nil {
+			return value, err
+		}
+		if err = DecryptFields(&value, ctx.keyProvider()); err != nil {
+			var zero v
+			return zero, err
+		}
+		return value, nil
+	}
+	ctx.DeserializeToValues = func(strs []string) (values []v, err error) {
+		if values, err = deserializes(strs); err != nil {
+			return values, err
+		}
+		for i := range values {
+			if err = DecryptFields(&values[i], ctx.keyProvider()); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+}
+
+// copyStruct returns an addressable copy of in; if in is a pointer, the pointed struct is copied too
+func copyStruct[v any](in v) reflect.Value {
+	src := reflect.ValueOf(&in).Elem()
+	out := reflect.New(src.Type()).Elem()
+	out.Set(src)
+	if src.Kind() == reflect.Pointer && !src.IsNil() {
+		elem := reflect.New(src.Type().Elem())
+		elem.Elem().Set(src.Elem())
+		out.Set(elem)
+	}
+	return out
+}
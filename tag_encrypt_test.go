@@ -0,0 +1,79 @@
+package redisdb_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/doptime/redisdb"
+)
+
+type Patient struct {
+	Name string `msgpack:"name"`
+	SSN  string `msgpack:"ssn" encrypt:"aes-gcm"`
+}
+
+// PatientV2 is Patient with its encrypted field renamed, the stored name kept
+type PatientV2 struct {
+	Name   string `msgpack:"name"`
+	Social string `msgpack:"ssn" encrypt:"aes-gcm"`
+}
+
+var testKeys = &redisdb.StaticKeyProvider{CurrentID: "k1", Keys: map[string][]byte{"k1": []byte("0123456789abcdef0123456789abcdef")}}
+
+func TestEncryptedFields(t *testing.T) {
+	srv, rds := newServer(t)
+	patients := redisdb.NewHashKey[string, *Patient](rds.Key("patients").KeyProvider(testKeys))
+	in := &Patient{Name: "alice", SSN: "123-45-6789"}
+	if _, err := patients.HSet("p1", in); err != nil {
+		t.Fatal(err)
+	}
+	if in.SSN != "123-45-6789" {
+		t.Fatalf("caller's value modified: %q", in.SSN)
+	}
+	if raw := srv.HGet("patients", "p1"); strings.Contains(raw, "123-45-6789") {
+		t.Fatal("ssn stored in plaintext")
+	}
+	if p, err := patients.HGet("p1"); err != nil || p.SSN != "123-45-6789" {
+		t.Fatalf("HGet = %+v, %v", p, err)
+	}
+
+	// a plaintext shaped like a ciphertext is encrypted too
+	if _, err := patients.HSet("p2", &Patient{SSN: "$enc$k1$bm90IGEgY2lwaGVydGV4dA=="}); err != nil {
+		t.Fatal(err)
+	}
+	if raw := srv.HGet("patients", "p2"); strings.Contains(raw, "bm90IGEgY2lwaGVydGV4dA==") {
+		t.Fatal("ciphertext-like plaintext stored as is")
+	}
+	if p, err := patients.HGet("p2"); err != nil || p.SSN != "$enc$k1$bm90IGEgY2lwaGVydGV4dA==" {
+		t.Fatalf("HGet = %+v, %v", p, err)
+	}
+
+	// renaming the Go field keeps the values readable
+	renamed := redisdb.NewHashKey[string, *PatientV2](rds.Key("patients").KeyProvider(testKeys))
+	if p, err := renamed.HGet("p1"); err != nil || p.Social != "123-45-6789" {
+		t.Fatalf("HGet after rename = %+v, %v", p, err)
+	}
+}
+
+func TestInvalidEncryptTags(t *testing.T) {
+	_, rds := newServer(t)
+	type Unknown struct {
+		SSN string `encrypt:"aes"`
+	}
+	type NotText struct {
+		Born time.Time `encrypt:"aes-gcm"`
+	}
+	unknown := redisdb.NewHashKey[string, *Unknown](rds.Key("unknown").KeyProvider(testKeys))
+	notText := redisdb.NewHashKey[string, *NotText](rds.Key("nottext").KeyProvider(testKeys))
+	for _, err := range []error{unknown.Err(), notText.Err()} {
+		if !errors.Is(err, redisdb.ErrInvalidEncryptTag) {
+			t.Fatalf("Err = %v, want ErrInvalidEncryptTag", err)
+		}
+	}
+	// nothing is stored in plaintext
+	if _, err := unknown.HSet("p1", &Unknown{SSN: "123-45-6789"}); !errors.Is(err, redisdb.ErrInvalidEncryptTag) {
+		t.Fatalf("HSet = %v, want ErrInvalidEncryptTag", err)
+	}
+}
@@ -48,9 +48,11 @@ type FieldModifier struct {
 }
 
 // StructModifiers holds a collection of registered modifiers for a specific struct type and cached tag info.
+// the `encrypt` tagged fields are collected in the same pass, see EncryptFields
 type StructModifiers struct {
 	modifierRegistry map[string]ModifierFunc
 	fieldModifiers   []*FieldModifier
+	fieldEncryptors  []*FieldEncryptor
 	ValType          reflect.Type
 }
 
@@ -117,17 +119,77 @@ func ApplyCounter(fieldValue interface{}, tagParam string) (interface{}, error)
 
 var ModerMap = cmap.New[*StructModifiers]()
 
+// structTypeKey is the ModerMap key of a struct type: the package path keeps same named types of different packages apart
+func structTypeKey(structType reflect.Type) string {
+	if structType.Name() == "" {
+		return structType.String()
+	}
+	return structType.PkgPath() + "." + structType.Name()
+}
+
+// lookupStructModifiers returns the registered tags of the struct val holds or points to, nil if there are none
+func lookupStructModifiers(val interface{}) (structValue reflect.Value, modifiers *StructModifiers) {
+	structValue = reflect.ValueOf(val)
+	for structValue.Kind() == reflect.Pointer || structValue.Kind() == reflect.Interface {
+		if structValue.IsNil() {
+			return structValue, nil
+		}
+		structValue = structValue.Elem()
+	}
+	if structValue.Kind() != reflect.Struct {
+		return structValue, nil
+	}
+	if modifiers, ok := ModerMap.Get(structTypeKey(structValue.Type())); ok && modifiers.ValType == structValue.Type() {
+		return structValue, modifiers
+	}
+	return structValue, nil
+}
+
 // RegisterStructModifiers initializes the StructModifiers for a specific struct type with optional extra modifiers.
+// returns false if no field has a `mod` tag
 func RegisterStructModifiers(extraModifiers map[string]ModifierFunc, structType reflect.Type) bool {
-	if structType == nil {
+	// an invalid `encrypt` tag is reported by RegisterStructEncryptors, which every key calls
+	modifiers, _ := newStructModifiers(extraModifiers, structType)
+	if modifiers == nil {
 		return false
 	}
+	if len(modifiers.fieldModifiers) > 0 || len(modifiers.fieldEncryptors) > 0 {
+		ModerMap.Set(structTypeKey(modifiers.ValType), modifiers)
+	}
+	return len(modifiers.fieldModifiers) > 0
+}
+
+// RegisterStructEncryptors registers the `encrypt` tagged fields of structType, keeping the modifiers registered for it if any.
+// returns false if there is none, and an ErrInvalidEncryptTag if a tag can't be honoured: keys with such a value fail to create
+func RegisterStructEncryptors(structType reflect.Type) (bool, error) {
+	modifiers, err := newStructModifiers(nil, structType)
+	if err != nil {
+		return false, err
+	}
+	if modifiers == nil || len(modifiers.fieldEncryptors) == 0 {
+		return false, nil
+	}
+	ModerMap.Upsert(structTypeKey(modifiers.ValType), modifiers, func(exist bool, registered, fresh *StructModifiers) *StructModifiers {
+		if exist && registered.ValType == fresh.ValType {
+			return registered
+		}
+		return fresh
+	})
+	return true, nil
+}
+
+// newStructModifiers collects the `mod` and `encrypt` tags of structType in one pass. returns nil if it isn't a struct.
+// err is the first invalid `encrypt` tag, its field is left out of the encryptors
+func newStructModifiers(extraModifiers map[string]ModifierFunc, structType reflect.Type) (_ *StructModifiers, err error) {
+	if structType == nil {
+		return nil, nil
+	}
 	for k := structType.Kind(); k == reflect.Pointer; k = structType.Kind() {
 		structType = structType.Elem()
 	}
 	// Ensure we have a struct type.
 	if structType.Kind() != reflect.Struct {
-		return false
+		return nil, nil
 	}
 
 	modifiers := &StructModifiers{
@@ -150,6 +212,11 @@ func RegisterStructModifiers(extraModifiers map[string]ModifierFunc, structType
 
 	for i := 0; i < structType.NumField(); i++ {
 		field := structType.Field(i)
+		if fieldEncryptor, encryptErr := newFieldEncryptor(i, field); fieldEncryptor != nil {
+			modifiers.fieldEncryptors = append(modifiers.fieldEncryptors, fieldEncryptor)
+		} else if encryptErr != nil && err == nil {
+			err = encryptErr
+		}
 		tag := field.Tag.Get("mod")
 		if tag != "" {
 			forceApply := false
@@ -177,13 +244,19 @@ func RegisterStructModifiers(extraModifiers map[string]ModifierFunc, structType
 			modifiers.fieldModifiers = append(modifiers.fieldModifiers, fieldModifier)
 		}
 	}
-	if len(modifiers.fieldModifiers) == 0 {
-		return false
-	}
+	return modifiers, err
+}
 
-	_typeName := structType.String()
-	ModerMap.Set(_typeName, modifiers)
-	return true
+// storedFieldName is the name a field is encoded under: the msgpack tag, then the json tag, then the field name
+func storedFieldName(field reflect.StructField) (name, tagOptions string) {
+	tag := field.Tag.Get("msgpack")
+	if tag == "" {
+		tag = field.Tag.Get("json")
+	}
+	if name, tagOptions, _ = strings.Cut(tag, ","); name == "" {
+		name = field.Name
+	}
+	return name, tagOptions
 }
 
 func ApplyModifiers(val interface{}) error {
@@ -200,8 +273,7 @@ func ApplyModifiers(val interface{}) error {
 		structValue = structValue.Elem()
 		structType = structValue.Type()
 	}
-	_typeName := structType.String()
-	modifiers, ok := ModerMap.Get(_typeName)
+	modifiers, ok := ModerMap.Get(structTypeKey(structType))
 	if !ok || modifiers == nil {
 		return nil // If no modifiers are found, simply return
 	}
@@ -0,0 +1,477 @@
+package redisdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TxCtx queues commands of typed keys bound with key.In(tx). the commands are sent with one MULTI/EXEC (Tx)
+// or in one round trip without atomicity (Pipeline) when fn returns; their Results are resolved afterwards.
+type TxCtx struct {
+	Context context.Context
+	RdsName string
+
+	pipe      redis.Pipeliner
+	resolvers []func()
+	err       error
+}
+
+// Result is the typed future of a command queued in a TxCtx. it holds ErrResultPending until the transaction is executed
+type Result[T any] struct {
+	val T
+	err error
+}
+
+func (r *Result[T]) Val() T                     { return r.val }
+func (r *Result[T]) Err() error                 { return r.err }
+func (r *Result[T]) Result() (val T, err error) { return r.val, r.err }
+
+// Tx runs fn and executes the queued commands atomically with MULTI/EXEC on data source rdsName.
+// if fn returns an error, or a command can't be queued (e.g. serialization fails), nothing is sent.
+// on a cluster all keys must share a hash slot (use hash tags) to be atomic
+func Tx(rdsName string, fn func(tx *TxCtx) error) error {
+	return TxWithCtx(context.Background(), rdsName, fn)
+}
+func TxWithCtx(c context.Context, rdsName string, fn func(tx *TxCtx) error) error {
+	return runTx(c, rdsName, true, fn)
+}
+
+// Pipeline is Tx without MULTI/EXEC: the commands are sent in one round trip but other clients may interleave
+func Pipeline(rdsName string, fn func(tx *TxCtx) error) error {
+	return PipelineWithCtx(context.Background(), rdsName, fn)
+}
+func PipelineWithCtx(c context.Context, rdsName string, fn func(tx *TxCtx) error) error {
+	return runTx(c, rdsName, false, fn)
+}
+
+func runTx(c context.Context, rdsName string, multi bool, fn func(tx *TxCtx) error) (err error) {
+	rds, ok := getDataSource(rdsName)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrDataSourceUnavailable, rdsName)
+	}
+	tx := &TxCtx{Context: c, RdsName: rdsName}
+	if multi {
+		tx.pipe = rds.TxPipeline()
+	} else {
+		tx.pipe = rds.Pipeline()
+	}
+	if err = fn(tx); err == nil {
+		err = tx.err
+	}
+	if err != nil {
+		tx.pipe.Discard()
+		return err
+	}
+	cmds, err := tx.pipe.Exec(c)
+	for _, resolve := range tx.resolvers {
+		resolve()
+	}
+	// a read of a missing key is reported by its own Result, not as a failed transaction
+	if err == redis.Nil {
+		err = nil
+		for _, cmd := range cmds {
+			if e := cmd.Err(); e != nil && e != redis.Nil {
+				return e
+			}
+		}
+	}
+	return err
+}
+
+// fail marks the transaction as failed, so nothing is sent
+func (tx *TxCtx) fail(err error) {
+	if tx.err == nil {
+		tx.err = err
+	}
+}
+
+// bind checks a key bound with In: a key that failed to construct (see Err) fails the transaction with its error,
+// so its commands, on an empty key name, are never sent
+func (tx *TxCtx) bind(key string, rdsName string, keyErr error) {
+	if keyErr != nil {
+		tx.fail(keyErr)
+	} else if rdsName != tx.RdsName {
+		tx.fail(fmt.Errorf("redisdb: key %s uses data source %s, transaction uses %s", key, rdsName, tx.RdsName))
+	}
+}
+
+// expire queues the default ttl of WithTTL for key, after a write to it
+func (tx *TxCtx) expire(key string, ttl time.Duration) {
+	if ttl > 0 {
+		tx.pipe.PExpire(tx.Context, key, ttl)
+	}
+}
+
+// queue registers resolve to run after the transaction is executed. once tx failed, nothing will be: the Result holds the error
+func queue[T any](tx *TxCtx, resolve func() (T, error)) *Result[T] {
+	if tx.err != nil {
+		return &Result[T]{err: tx.err}
+	}
+	r := &Result[T]{err: ErrResultPending}
+	tx.resolvers = append(tx.resolvers, func() { r.val, r.err = resolve() })
+	return r
+}
+
+// failed returns a Result holding err, and aborts the transaction
+func failed[T any](tx *TxCtx, err error) *Result[T] {
+	tx.fail(err)
+	return &Result[T]{err: err}
+}
+
+// --- HashKey ---
+
+type TxHashKey[k comparable, v any] struct {
+	key *HashKey[k, v]
+	tx  *TxCtx
+}
+
+// In binds the key to tx: its commands are queued in tx instead of being sent
+func (ctx *HashKey[k, v]) In(tx *TxCtx) *TxHashKey[k, v] {
+	tx.bind(ctx.Key, ctx.RdsName, ctx.Err())
+	if ctx.cache != nil {
+		tx.resolvers = append(tx.resolvers, func() { ctx.uncache(ctx.Key) })
+	}
+	return &TxHashKey[k, v]{key: ctx, tx: tx}
+}
+
+func (b *TxHashKey[k, v]) HGet(field k) *Result[v] {
+	fieldStr, err := b.key.SerializeKey(field)
+	if err != nil {
+		return failed[v](b.tx, err)
+	}
+	cmd := b.tx.pipe.HGet(b.tx.Context, b.key.Key, fieldStr)
+	return queue(b.tx, func() (value v, err error) {
+		data, err := cmd.Bytes()
+		if err != nil {
+			return value, asNotFound(err)
+		}
+		return b.key.decodeValue(fieldStr, data)
+	})
+}
+
+func (b *TxHashKey[k, v]) HSet(field k, value v) *Result[int64] {
+	return b.HMSet(map[k]v{field: value})
+}
+
+func (b *TxHashKey[k, v]) HMSet(kvMap map[k]v) *Result[int64] {
+	fieldValues := make([]interface{}, 0, len(kvMap)*2)
+	for field, value := range kvMap {
+		if b.key.UseModer {
+			ApplyModifiers(&value)
+		}
+		fieldStr, err := b.key.SerializeKey(field)
+		if err != nil {
+			return failed[int64](b.tx, err)
+		}
+		valStr, err := b.key.SerializeValue(value)
+		if err != nil {
+			return failed[int64](b.tx, err)
+		}
+		fieldValues = append(fieldValues, fieldStr, valStr)
+	}
+	cmd := b.tx.pipe.HSet(b.tx.Context, b.key.Key, fieldValues...)
+	b.tx.expire(b.key.Key, b.key.DefaultTTL)
+	return queue(b.tx, cmd.Result)
+}
+
+func (b *TxHashKey[k, v]) HDel(fields ...k) *Result[int64] {
+	fieldStrs := make([]string, len(fields))
+	for i, field := range fields {
+		fieldStr, err := b.key.SerializeKey(field)
+		if err != nil {
+			return failed[int64](b.tx, err)
+		}
+		fieldStrs[i] = fieldStr
+	}
+	cmd := b.tx.pipe.HDel(b.tx.Context, b.key.Key, fieldStrs...)
+	return queue(b.tx, cmd.Result)
+}
+
+func (b *TxHashKey[k, v]) HExists(field k) *Result[bool] {
+	fieldStr, err := b.key.SerializeKey(field)
+	if err != nil {
+		return failed[bool](b.tx, err)
+	}
+	cmd := b.tx.pipe.HExists(b.tx.Context, b.key.Key, fieldStr)
+	return queue(b.tx, cmd.Result)
+}
+
+func (b *TxHashKey[k, v]) HIncrBy(field k, increment int64) *Result[int64] {
+	fieldStr, err := b.key.SerializeKey(field)
+	if err != nil {
+		return failed[int64](b.tx, err)
+	}
+	cmd := b.tx.pipe.HIncrBy(b.tx.Context, b.key.Key, fieldStr, increment)
+	b.tx.expire(b.key.Key, b.key.DefaultTTL)
+	return queue(b.tx, cmd.Result)
+}
+
+func (b *TxHashKey[k, v]) HGetAll() *Result[map[k]v] {
+	cmd := b.tx.pipe.HGetAll(b.tx.Context, b.key.Key)
+	return queue(b.tx, func() (map[k]v, error) {
+		if err := cmd.Err(); err != nil {
+			return nil, err
+		}
+		mapOut := make(map[k]v, len(cmd.Val()))
+		for fieldStr, valStr := range cmd.Val() {
+			field, err := b.key.toKey([]byte(fieldStr))
+			if err != nil {
+				return mapOut, &DecodeError{Key: b.key.Key, Field: fieldStr, Raw: []byte(fieldStr), Err: err}
+			}
+			if mapOut[field], err = b.key.decodeValue(fieldStr, []byte(valStr)); err != nil {
+				return mapOut, err
+			}
+		}
+		return mapOut, nil
+	})
+}
+
+// --- StringKey ---
+
+type TxStringKey[k comparable, v any] struct {
+	key *StringKey[k, v]
+	tx  *TxCtx
+}
+
+// In binds the key to tx: its commands are queued in tx instead of being sent
+func (ctx *StringKey[k, v]) In(tx *TxCtx) *TxStringKey[k, v] {
+	tx.bind(ctx.Key, ctx.RdsName, ctx.Err())
+	return &TxStringKey[k, v]{key: ctx, tx: tx}
+}
+
+func (b *TxStringKey[k, v]) fullKey(key k) (keyStr string, fullKey string, err error) {
+	if keyStr, err = b.key.SerializeKey(key); err != nil {
+		return "", "", err
+	}
+	return keyStr, b.key.Key + ":" + keyStr, nil
+}
+
+func (b *TxStringKey[k, v]) Get(key k) *Result[v] {
+	keyStr, fullKey, err := b.fullKey(key)
+	if err != nil {
+		return failed[v](b.tx, err)
+	}
+	cmd := b.tx.pipe.Get(b.tx.Context, fullKey)
+	return queue(b.tx, func() (value v, err error) {
+		data, err := cmd.Bytes()
+		if err != nil {
+			return value, asNotFound(err)
+		}
+		return b.key.decodeValue(keyStr, data)
+	})
+}
+
+func (b *TxStringKey[k, v]) Set(key k, value v, expiration time.Duration) *Result[string] {
+	_, fullKey, err := b.fullKey(key)
+	if err != nil {
+		return failed[string](b.tx, err)
+	}
+	valStr, err := b.key.SerializeValue(value)
+	if err != nil {
+		return failed[string](b.tx, err)
+	}
+	cmd := b.tx.pipe.Set(b.tx.Context, fullKey, valStr, b.key.expiration(expiration))
+	b.tx.resolvers = append(b.tx.resolvers, func() { b.key.uncache(fullKey) })
+	return queue(b.tx, cmd.Result)
+}
+
+func (b *TxStringKey[k, v]) Del(keys ...k) *Result[int64] {
+	fullKeys := make([]string, len(keys))
+	for i, key := range keys {
+		_, fullKey, err := b.fullKey(key)
+		if err != nil {
+			return failed[int64](b.tx, err)
+		}
+		fullKeys[i] = fullKey
+	}
+	cmd := b.tx.pipe.Del(b.tx.Context, fullKeys...)
+	b.tx.resolvers = append(b.tx.resolvers, func() { b.key.uncache(fullKeys...) })
+	return queue(b.tx, cmd.Result)
+}
+
+// --- ListKey ---
+
+type TxListKey[v any] struct {
+	key *ListKey[v]
+	tx  *TxCtx
+}
+
+// In binds the key to tx: its commands are queued in tx instead of being sent
+func (ctx *ListKey[v]) In(tx *TxCtx) *TxListKey[v] {
+	tx.bind(ctx.Key, ctx.RdsName, ctx.Err())
+	return &TxListKey[v]{key: ctx, tx: tx}
+}
+
+func (b *TxListKey[v]) RPush(values ...v) *Result[int64] {
+	vals, err := b.key.toValueStrsSlice(values...)
+	if err != nil {
+		return failed[int64](b.tx, err)
+	}
+	cmd := b.tx.pipe.RPush(b.tx.Context, b.key.Key, vals...)
+	b.tx.expire(b.key.Key, b.key.DefaultTTL)
+	return queue(b.tx, cmd.Result)
+}
+
+func (b *TxListKey[v]) LPush(values ...v) *Result[int64] {
+	vals, err := b.key.toValueStrsSlice(values...)
+	if err != nil {
+		return failed[int64](b.tx, err)
+	}
+	cmd := b.tx.pipe.LPush(b.tx.Context, b.key.Key, vals...)
+	b.tx.expire(b.key.Key, b.key.DefaultTTL)
+	return queue(b.tx, cmd.Result)
+}
+
+func (b *TxListKey[v]) pop(cmd *redis.StringCmd) *Result[v] {
+	return queue(b.tx, func() (value v, err error) {
+		data, err := cmd.Bytes()
+		if err != nil {
+			return value, asNotFound(err)
+		}
+		return b.key.decodeValue("", data)
+	})
+}
+func (b *TxListKey[v]) LPop() *Result[v] { return b.pop(b.tx.pipe.LPop(b.tx.Context, b.key.Key)) }
+func (b *TxListKey[v]) RPop() *Result[v] { return b.pop(b.tx.pipe.RPop(b.tx.Context, b.key.Key)) }
+
+func (b *TxListKey[v]) LRange(start, stop int64) *Result[[]v] {
+	cmd := b.tx.pipe.LRange(b.tx.Context, b.key.Key, start, stop)
+	return queue(b.tx, func() ([]v, error) {
+		if err := cmd.Err(); err != nil {
+			return nil, err
+		}
+		return b.key.DeserializeToValues(cmd.Val())
+	})
+}
+
+func (b *TxListKey[v]) LLen() *Result[int64] {
+	return queue(b.tx, b.tx.pipe.LLen(b.tx.Context, b.key.Key).Result)
+}
+
+// --- SetKey ---
+
+type TxSetKey[k comparable, v any] struct {
+	key *SetKey[k, v]
+	tx  *TxCtx
+}
+
+// In binds the key to tx: its commands are queued in tx instead of being sent
+func (ctx *SetKey[k, v]) In(tx *TxCtx) *TxSetKey[k, v] {
+	tx.bind(ctx.Key, ctx.RdsName, ctx.Err())
+	return &TxSetKey[k, v]{key: ctx, tx: tx}
+}
+
+func (b *TxSetKey[k, v]) SAdd(members ...v) *Result[int64] {
+	vals, err := b.key.toValueStrsSlice(members...)
+	if err != nil {
+		return failed[int64](b.tx, err)
+	}
+	cmd := b.tx.pipe.SAdd(b.tx.Context, b.key.Key, vals...)
+	b.tx.expire(b.key.Key, b.key.DefaultTTL)
+	return queue(b.tx, cmd.Result)
+}
+
+func (b *TxSetKey[k, v]) SRem(members ...v) *Result[int64] {
+	vals, err := b.key.toValueStrsSlice(members...)
+	if err != nil {
+		return failed[int64](b.tx, err)
+	}
+	return queue(b.tx, b.tx.pipe.SRem(b.tx.Context, b.key.Key, vals...).Result)
+}
+
+func (b *TxSetKey[k, v]) SIsMember(member v) *Result[bool] {
+	valStr, err := b.key.SerializeValue(member)
+	if err != nil {
+		return failed[bool](b.tx, err)
+	}
+	return queue(b.tx, b.tx.pipe.SIsMember(b.tx.Context, b.key.Key, valStr).Result)
+}
+
+func (b *TxSetKey[k, v]) SMembers() *Result[[]v] {
+	cmd := b.tx.pipe.SMembers(b.tx.Context, b.key.Key)
+	return queue(b.tx, func() ([]v, error) {
+		if err := cmd.Err(); err != nil {
+			return nil, err
+		}
+		return b.key.DeserializeToValues(cmd.Val())
+	})
+}
+
+// --- ZSetKey ---
+
+type TxZSetKey[k comparable, v any] struct {
+	key *ZSetKey[k, v]
+	tx  *TxCtx
+}
+
+// In binds the key to tx: its commands are queued in tx instead of being sent
+func (ctx *ZSetKey[k, v]) In(tx *TxCtx) *TxZSetKey[k, v] {
+	tx.bind(ctx.Key, ctx.RdsName, ctx.Err())
+	return &TxZSetKey[k, v]{key: ctx, tx: tx}
+}
+
+// ZAdd encodes the members like ZSetKey.ZAdd, without modifying the passed slice
+func (b *TxZSetKey[k, v]) ZAdd(members ...redis.Z) *Result[int64] {
+	encoded := make([]redis.Z, len(members))
+	for i, member := range members {
+		memberStr, err := b.key.serializeInterface(member.Member)
+		if err != nil {
+			return failed[int64](b.tx, err)
+		}
+		encoded[i] = redis.Z{Score: member.Score, Member: memberStr}
+	}
+	cmd := b.tx.pipe.ZAdd(b.tx.Context, b.key.Key, encoded...)
+	b.tx.expire(b.key.Key, b.key.DefaultTTL)
+	return queue(b.tx, cmd.Result)
+}
+
+func (b *TxZSetKey[k, v]) ZRem(members ...interface{}) *Result[int64] {
+	encoded := make([]interface{}, len(members))
+	for i, member := range members {
+		memberStr, err := b.key.serializeInterface(member)
+		if err != nil {
+			return failed[int64](b.tx, err)
+		}
+		encoded[i] = memberStr
+	}
+	return queue(b.tx, b.tx.pipe.ZRem(b.tx.Context, b.key.Key, encoded...).Result)
+}
+
+func (b *TxZSetKey[k, v]) ZIncrBy(increment float64, member interface{}) *Result[float64] {
+	memberStr, err := b.key.serializeInterface(member)
+	if err != nil {
+		return failed[float64](b.tx, err)
+	}
+	cmd := b.tx.pipe.ZIncrBy(b.tx.Context, b.key.Key, increment, memberStr)
+	b.tx.expire(b.key.Key, b.key.DefaultTTL)
+	return queue(b.tx, cmd.Result)
+}
+
+func (b *TxZSetKey[k, v]) ZScore(member interface{}) *Result[float64] {
+	memberStr, err := b.key.serializeInterface(member)
+	if err != nil {
+		return failed[float64](b.tx, err)
+	}
+	cmd := b.tx.pipe.ZScore(b.tx.Context, b.key.Key, memberStr)
+	return queue(b.tx, func() (float64, error) {
+		score, err := cmd.Result()
+		return score, asNotFound(err)
+	})
+}
+
+func (b *TxZSetKey[k, v]) ZRange(start, stop int64) *Result[[]v] {
+	cmd := b.tx.pipe.ZRange(b.tx.Context, b.key.Key, start, stop)
+	return queue(b.tx, func() ([]v, error) {
+		if err := cmd.Err(); err != nil {
+			return nil, err
+		}
+		return b.key.UnmarshalToSlice(cmd.Val())
+	})
+}
+
+func (b *TxZSetKey[k, v]) ZCard() *Result[int64] {
+	return queue(b.tx, b.tx.pipe.ZCard(b.tx.Context, b.key.Key).Result)
+}
@@ -0,0 +1,79 @@
+package redisdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/doptime/redisdb"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestTxAcrossKeys(t *testing.T) {
+	srv, rds := newServer(t)
+	users := redisdb.NewHashKey[string, *User](rds.Key("users"))
+	rank := redisdb.NewZSetKey[string, string](rds.Key("rank"))
+	var (
+		score   *redisdb.Result[float64]
+		user    *redisdb.Result[*User]
+		missing *redisdb.Result[*User]
+	)
+	err := redisdb.Tx(srv.Name, func(tx *redisdb.TxCtx) error {
+		users.In(tx).HSet("u1", &User{ID: "u1", Name: "alice"})
+		score = rank.In(tx).ZIncrBy(5, "u1")
+		user = users.In(tx).HGet("u1")
+		missing = users.In(tx).HGet("u2")
+		if !errors.Is(user.Err(), redisdb.ErrResultPending) {
+			t.Errorf("result before EXEC: %v, want ErrResultPending", user.Err())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err := score.Result(); err != nil || s != 5 {
+		t.Fatalf("score = %v, %v", s, err)
+	}
+	if u, err := user.Result(); err != nil || u.Name != "alice" {
+		t.Fatalf("user = %+v, %v", u, err)
+	}
+	if !errors.Is(missing.Err(), redisdb.ErrNotFound) {
+		t.Fatalf("missing = %v, want ErrNotFound", missing.Err())
+	}
+}
+
+func TestTxAbort(t *testing.T) {
+	srv, rds := newServer(t)
+	rank := redisdb.NewZSetKey[string, string](rds.Key("rank"))
+	abort := errors.New("abort")
+	err := redisdb.Tx(srv.Name, func(tx *redisdb.TxCtx) error {
+		rank.In(tx).ZAdd(redis.Z{Score: 1, Member: "a"})
+		return abort
+	})
+	if !errors.Is(err, abort) {
+		t.Fatalf("Tx = %v, want abort", err)
+	}
+	if srv.Exists("rank") {
+		t.Fatal("aborted transaction was executed")
+	}
+}
+
+func TestTxInvalidKey(t *testing.T) {
+	srv, rds := newServer(t)
+	users := redisdb.NewHashKey[string, string](rds.Key("users"))
+	invalid := redisdb.NewSetKey[string, *Patient](rds.Key("patients").KeyProvider(testKeys))
+	var added *redisdb.Result[int64]
+	err := redisdb.Tx(srv.Name, func(tx *redisdb.TxCtx) error {
+		users.In(tx).HSet("u1", "alice")
+		added = invalid.In(tx).SAdd(&Patient{Name: "bob"})
+		return nil
+	})
+	if !errors.Is(err, redisdb.ErrEncryptedMember) {
+		t.Fatalf("Tx = %v, want the error of the invalid key", err)
+	}
+	if !errors.Is(added.Err(), redisdb.ErrEncryptedMember) {
+		t.Fatalf("SAdd result = %v, want the error of the invalid key", added.Err())
+	}
+	if srv.Exists("users") {
+		t.Fatal("transaction with an invalid key was executed")
+	}
+}